@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Access log sampling. ACCESS_LOG_SAMPLE ("/healthz=100,GET /api/kv/{key}=10",
+// keyed by mux pattern, with "*" for every other route) logs only 1 in N
+// successful requests on a route, so load tests don't flood stdout. Errors
+// (status 400 and above) are always logged. Skipped lines are counted in
+// access_logs_sampled_out_total.
+var accessLogSampler = newLogSampler(mustParseAccessLogSample(getenv("ACCESS_LOG_SAMPLE", "")))
+
+// Probe logging. Kubelet probes make up most access log volume, so
+// ACCESS_LOG_PROBES limits logging of probe paths (see probePaths): "all"
+// logs them like any other route, "errors" only logs failed probes, and
+// "none" drops them. The default is "errors" when APP_ENV is production and
+// "all" otherwise. Dropped lines are counted like sampled ones.
+var accessLogProbes = mustAccessLogProbes(getenv("ACCESS_LOG_PROBES", defaultAccessLogProbes()))
+
+func defaultAccessLogProbes() string {
+	if env == "production" {
+		return "errors"
+	}
+	return "all"
+}
+
+func mustAccessLogProbes(v string) string {
+	switch v {
+	case "all", "errors", "none":
+		return v
+	}
+	invalidConfig("ACCESS_LOG_PROBES", fmt.Errorf("%q is not all, errors or none", v))
+	return ""
+}
+
+// keepProbeLog reports whether a probe response with status is logged.
+func keepProbeLog(status int) bool {
+	switch accessLogProbes {
+	case "errors":
+		return status >= 400
+	case "none":
+		return false
+	}
+	return true
+}
+
+type logSampler struct {
+	every map[string]int
+	seen  sync.Map // route -> *atomic.Uint64
+}
+
+func newLogSampler(every map[string]int) *logSampler {
+	return &logSampler{every: every}
+}
+
+func parseAccessLogSample(spec string) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid sample rate %q, want pattern=N", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(pair[i+1:]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid sample rate %q, want a positive integer", pair)
+		}
+		out[strings.TrimSpace(pair[:i])] = n
+	}
+	return out, nil
+}
+
+func mustParseAccessLogSample(spec string) map[string]int {
+	m, err := parseAccessLogSample(spec)
+	if err != nil {
+		invalidConfig("ACCESS_LOG_SAMPLE", err)
+	}
+	return m
+}
+
+// keep reports whether a request on route with status should be logged.
+func (s *logSampler) keep(route string, status int) bool {
+	n, ok := s.every[route]
+	if !ok {
+		n = s.every["*"]
+	}
+	if n <= 1 || status >= 400 {
+		return true
+	}
+	c, _ := s.seen.LoadOrStore(route, new(atomic.Uint64))
+	return c.(*atomic.Uint64).Add(1)%uint64(n) == 1
+}
+
+// Access log format. ACCESS_LOG_FORMAT selects how request lines are
+// written:
+//
+//	slog:     a "request" record on the default slog logger (the default)
+//	common:   Apache common log format on the log output (stdout and/or
+//	          LOG_FILE)
+//	combined: Apache combined log format (common plus referer and user
+//	          agent) on the log output
+//
+// ACCESS_LOG_FIELDS ("method,path,status,dur_ms") chooses the fields of
+// slog records, from those in accessLogFieldFuncs.
+var (
+	accessLogFormat = mustAccessLogFormat(getenv("ACCESS_LOG_FORMAT", "slog"))
+	accessLogFields = mustParseAccessLogFields(getenv("ACCESS_LOG_FIELDS", ""))
+	accessLogOut    io.Writer // logOutput, set in logging.go
+)
+
+// Header logging. ACCESS_LOG_REQUEST_HEADERS and
+// ACCESS_LOG_RESPONSE_HEADERS ("X-Forwarded-For,Via") add the named headers
+// to slog request records, under req_headers and resp_headers, for
+// debugging proxies without tcpdump. Values of headers listed in
+// ACCESS_LOG_REDACT_HEADERS (by default credentials and cookies) are
+// replaced with "[REDACTED]" even if named.
+var (
+	accessLogReqHeaders  = parseHeaderNames(getenv("ACCESS_LOG_REQUEST_HEADERS", ""))
+	accessLogRespHeaders = parseHeaderNames(getenv("ACCESS_LOG_RESPONSE_HEADERS", ""))
+	accessLogRedact      = parseHeaderNames(getenv("ACCESS_LOG_REDACT_HEADERS", "Authorization,Proxy-Authorization,Cookie,Set-Cookie,X-Api-Key"))
+)
+
+func parseHeaderNames(spec string) []string {
+	var out []string
+	for _, h := range strings.Split(spec, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			out = append(out, http.CanonicalHeaderKey(h))
+		}
+	}
+	return out
+}
+
+// headerGroup returns the named headers present in h as a slog group,
+// redacting sensitive values.
+func headerGroup(key string, h http.Header, names []string) slog.Attr {
+	var attrs []any
+	for _, name := range names {
+		vs, ok := h[name]
+		if !ok {
+			continue
+		}
+		v := strings.Join(vs, ", ")
+		if slices.Contains(accessLogRedact, name) {
+			v = "[REDACTED]"
+		}
+		attrs = append(attrs, slog.String(name, v))
+	}
+	return slog.Group(key, attrs...)
+}
+
+var defaultAccessLogFields = []string{"method", "path", "status", "remote", "client_ip", "request_id", "dur_ms"}
+
+// accessEntry is one completed request.
+type accessEntry struct {
+	r      *http.Request
+	start  time.Time
+	dur    time.Duration
+	status int
+	bytes  int64
+	header http.Header // response headers
+}
+
+var accessLogFieldFuncs = map[string]func(e *accessEntry) any{
+	"method":     func(e *accessEntry) any { return e.r.Method },
+	"path":       func(e *accessEntry) any { return e.r.URL.Path },
+	"query":      func(e *accessEntry) any { return e.r.URL.RawQuery },
+	"route":      func(e *accessEntry) any { return routeLabel(e.r) },
+	"host":       func(e *accessEntry) any { return e.r.Host },
+	"proto":      func(e *accessEntry) any { return e.r.Proto },
+	"status":     func(e *accessEntry) any { return e.status },
+	"bytes":      func(e *accessEntry) any { return e.bytes },
+	"remote":     func(e *accessEntry) any { return e.r.RemoteAddr },
+	"client_ip":  func(e *accessEntry) any { return clientIP(e.r) },
+	"request_id": func(e *accessEntry) any { return requestIDFrom(e.r.Context()) },
+	"user_agent": func(e *accessEntry) any { return e.r.UserAgent() },
+	"referer":    func(e *accessEntry) any { return e.r.Referer() },
+	"dur_ms":     func(e *accessEntry) any { return e.dur.Milliseconds() },
+}
+
+func mustAccessLogFormat(v string) string {
+	switch v {
+	case "slog", "common", "combined":
+		return v
+	}
+	invalidConfig("ACCESS_LOG_FORMAT", fmt.Errorf("%q is not slog, common or combined", v))
+	return ""
+}
+
+func parseAccessLogFields(spec string) ([]string, error) {
+	var out []string
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if _, ok := accessLogFieldFuncs[f]; !ok {
+			return nil, fmt.Errorf("unknown access log field %q", f)
+		}
+		out = append(out, f)
+	}
+	if len(out) == 0 {
+		return defaultAccessLogFields, nil
+	}
+	return out, nil
+}
+
+func mustParseAccessLogFields(spec string) []string {
+	fields, err := parseAccessLogFields(spec)
+	if err != nil {
+		invalidConfig("ACCESS_LOG_FIELDS", err)
+	}
+	return fields
+}
+
+func withLogging() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r = withRequestLogger(r)
+			cw := &rwCapture{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			e := &accessEntry{r: r, start: start, dur: time.Since(start), status: cw.status, bytes: cw.bytes, header: cw.Header()}
+			if e.status == 0 {
+				e.status = http.StatusOK
+			}
+			if route := routeLabel(r); (isProbePath(r.URL.Path) && !keepProbeLog(e.status)) || !accessLogSampler.keep(route, e.status) {
+				accessLogsSampledOut.WithLabelValues(route).Inc()
+				return
+			}
+			writeAccessLog(e)
+		})
+	}
+}
+
+func writeAccessLog(e *accessEntry) {
+	if accessLogFormat == "slog" {
+		args := make([]any, 0, 2*len(accessLogFields))
+		for _, f := range accessLogFields {
+			args = append(args, f, accessLogFieldFuncs[f](e))
+		}
+		if len(accessLogReqHeaders) > 0 {
+			args = append(args, headerGroup("req_headers", e.r.Header, accessLogReqHeaders))
+		}
+		if len(accessLogRespHeaders) > 0 {
+			args = append(args, headerGroup("resp_headers", e.header, accessLogRespHeaders))
+		}
+		slog.Default().InfoContext(e.r.Context(), "request", args...)
+		return
+	}
+	io.WriteString(accessLogOut, apacheLogLine(e, accessLogFormat == "combined")+"\n")
+}
+
+// apacheLogLine formats e in Apache common log format, or combined format
+// if combined is set.
+func apacheLogLine(e *accessEntry, combined bool) string {
+	host := clientIP(e.r)
+	user := "-"
+	if u, _, ok := e.r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if e.bytes > 0 {
+		size = strconv.FormatInt(e.bytes, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] %q %d %s", host, user, e.start.Format("02/Jan/2006:15:04:05 -0700"),
+		e.r.Method+" "+e.r.URL.RequestURI()+" "+e.r.Proto, e.status, size)
+	if combined {
+		line += fmt.Sprintf(" %q %q", dashIfEmpty(e.r.Referer()), dashIfEmpty(e.r.UserAgent()))
+	}
+	return line
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAccessLogSample(t *testing.T) {
+	got, err := parseAccessLogSample("/healthz=100, GET /api/kv/{key} = 10,*=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["/healthz"] != 100 || got["GET /api/kv/{key}"] != 10 || got["*"] != 2 {
+		t.Errorf("got %v", got)
+	}
+	for _, bad := range []string{"/healthz", "/healthz=0", "/healthz=x"} {
+		if _, err := parseAccessLogSample(bad); err == nil {
+			t.Errorf("%q: want an error", bad)
+		}
+	}
+}
+
+func TestWithLoggingSampling(t *testing.T) {
+	prevSampler, prevDefault := accessLogSampler, slog.Default()
+	t.Cleanup(func() { accessLogSampler = prevSampler; slog.SetDefault(prevDefault) })
+	accessLogSampler = newLogSampler(map[string]int{"/healthz": 10})
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	status := http.StatusOK
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}), withLogging()))
+	mux.Handle("/api/info", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withLogging()))
+	serve := func(path string, n int) int {
+		buf.Reset()
+		for range n {
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+		return strings.Count(buf.String(), `"msg":"request"`)
+	}
+
+	if got := serve("/healthz", 25); got != 3 {
+		t.Errorf("sampled route logged %d of 25, want 3", got)
+	}
+	if got := serve("/api/info", 5); got != 5 {
+		t.Errorf("unsampled route logged %d of 5, want 5", got)
+	}
+	status = http.StatusServiceUnavailable
+	if got := serve("/healthz", 4); got != 4 {
+		t.Errorf("errors logged %d of 4, want all", got)
+	}
+	if !strings.Contains(buf.String(), `"status":503`) {
+		t.Errorf("log line missing status: %s", buf.String())
+	}
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	prevFormat, prevFields, prevOut, prevDefault := accessLogFormat, accessLogFields, accessLogOut, slog.Default()
+	t.Cleanup(func() {
+		accessLogFormat, accessLogFields, accessLogOut = prevFormat, prevFields, prevOut
+		slog.SetDefault(prevDefault)
+	})
+	var buf bytes.Buffer
+	accessLogOut = &buf
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}), withLogging())
+	serve := func() string {
+		buf.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/api/info?x=1", nil)
+		r.RemoteAddr = "10.0.0.7:5555"
+		r.Header.Set("User-Agent", "curl/8.7.1")
+		r.SetBasicAuth("ops", "pw")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		return buf.String()
+	}
+
+	accessLogFormat = "slog"
+	accessLogFields, _ = parseAccessLogFields("method,query,bytes,user_agent")
+	out := serve()
+	for _, want := range []string{`"method":"GET"`, `"query":"x=1"`, `"bytes":5`, `"user_agent":"curl/8.7.1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("slog line %s missing %s", out, want)
+		}
+	}
+	if strings.Contains(out, `"path"`) {
+		t.Errorf("slog line %s has an unselected field", out)
+	}
+
+	accessLogFormat = "common"
+	if out := serve(); !strings.HasPrefix(out, `10.0.0.7 - ops [`) || !strings.HasSuffix(out, `] "GET /api/info?x=1 HTTP/1.1" 200 5`+"\n") {
+		t.Errorf("common line = %q", out)
+	}
+	accessLogFormat = "combined"
+	if out := serve(); !strings.HasSuffix(out, `200 5 "-" "curl/8.7.1"`+"\n") {
+		t.Errorf("combined line = %q", out)
+	}
+
+	if _, err := parseAccessLogFields("method,colour"); err == nil {
+		t.Error("unknown field: want an error")
+	}
+}
+
+func TestAccessLogProbes(t *testing.T) {
+	prevProbes, prevDefault := accessLogProbes, slog.Default()
+	t.Cleanup(func() { accessLogProbes = prevProbes; slog.SetDefault(prevDefault) })
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	status := http.StatusOK
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(status) }), withLogging())
+	logged := func(path string) bool {
+		buf.Reset()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		return buf.Len() > 0
+	}
+
+	for _, tc := range []struct {
+		mode             string
+		probeOK, probe5x bool
+	}{
+		{"all", true, true},
+		{"errors", false, true},
+		{"none", false, false},
+	} {
+		accessLogProbes = tc.mode
+		status = http.StatusOK
+		if got := logged("/readyz"); got != tc.probeOK {
+			t.Errorf("%s: healthy probe logged = %v", tc.mode, got)
+		}
+		if !logged("/api/info") {
+			t.Errorf("%s: non-probe request not logged", tc.mode)
+		}
+		status = http.StatusServiceUnavailable
+		if got := logged("/readyz"); got != tc.probe5x {
+			t.Errorf("%s: failing probe logged = %v", tc.mode, got)
+		}
+	}
+}
+
+func TestAccessLogHeaders(t *testing.T) {
+	prevReq, prevResp, prevDefault := accessLogReqHeaders, accessLogRespHeaders, slog.Default()
+	t.Cleanup(func() { accessLogReqHeaders, accessLogRespHeaders = prevReq, prevResp; slog.SetDefault(prevDefault) })
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	accessLogReqHeaders = parseHeaderNames("via, authorization,X-Missing")
+	accessLogRespHeaders = parseHeaderNames("set-cookie,Content-Type")
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Set-Cookie", "session=abc")
+	}), withLogging())
+	r := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	r.Header.Add("Via", "1.1 lb-a")
+	r.Header.Add("Via", "1.1 lb-b")
+	r.Header.Set("Authorization", "Bearer s3cret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var rec struct {
+		Req  map[string]string `json:"req_headers"`
+		Resp map[string]string `json:"resp_headers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Via": "1.1 lb-a, 1.1 lb-b", "Authorization": "[REDACTED]"}
+	if len(rec.Req) != len(want) || rec.Req["Via"] != want["Via"] || rec.Req["Authorization"] != want["Authorization"] {
+		t.Errorf("req_headers = %v, want %v", rec.Req, want)
+	}
+	if rec.Resp["Set-Cookie"] != "[REDACTED]" || rec.Resp["Content-Type"] != "text/plain" {
+		t.Errorf("resp_headers = %v", rec.Resp)
+	}
+	if strings.Contains(buf.String(), "s3cret") || strings.Contains(buf.String(), "session=abc") {
+		t.Errorf("secret leaked into the log: %s", buf.String())
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingSecret holds a secret plus, for a grace window after rotation, the
+// value it replaced. Both are accepted during the window so clients can roll
+// over to the new value without downtime.
+type rotatingSecret struct {
+	mu        sync.RWMutex
+	current   string
+	previous  string
+	prevUntil time.Time
+	rotatedAt time.Time
+}
+
+func newRotatingSecret(v string) *rotatingSecret {
+	return &rotatingSecret{current: v}
+}
+
+func (s *rotatingSecret) value() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// matches reports whether v is the current value or a still-valid previous
+// one. Comparisons are constant-time.
+func (s *rotatingSecret) matches(v string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.current != "" && subtle.ConstantTimeCompare([]byte(v), []byte(s.current)) == 1 {
+		return true
+	}
+	return s.previous != "" && time.Now().Before(s.prevUntil) &&
+		subtle.ConstantTimeCompare([]byte(v), []byte(s.previous)) == 1
+}
+
+// accepted returns every value currently accepted: the current one plus the
+// previous one while its grace window lasts.
+func (s *rotatingSecret) accepted() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []string{s.current}
+	if s.previous != "" && time.Now().Before(s.prevUntil) {
+		out = append(out, s.previous)
+	}
+	return out
+}
+
+func (s *rotatingSecret) rotate(next string, grace time.Duration) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.previous, s.current = s.current, next
+	s.rotatedAt = time.Now()
+	s.prevUntil = s.rotatedAt.Add(grace)
+	return s.prevUntil
+}
+
+var (
+	adminToken    = newRotatingSecret(getenv("ADMIN_TOKEN", ""))
+	rotationGrace = getenvDuration("SECRET_ROTATION_GRACE", 5*time.Minute)
+
+	// secrets lists everything that can be rotated through the admin API.
+	secrets = map[string]*rotatingSecret{
+		"admin-token": adminToken,
+	}
+)
+
+type principalKey struct{}
+
+const principalAdminToken = "admin-token"
+
+// adminPrincipal returns who authenticated an admin request: "admin-token"
+// or "session:<id>". It is empty outside withAdminAuth.
+func adminPrincipal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// withAdminAuth guards admin endpoints with a bearer token: either the admin
+// token or a session token minted from it. When ADMIN_TOKEN is unset the
+// admin API is disabled outright rather than left open. Refused requests
+// and changes are audited.
+func withAdminAuth() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if adminToken.value() == "" {
+				writeError(w, http.StatusForbidden, "admin API disabled; set ADMIN_TOKEN to enable it")
+				auditRequest(r, "", http.StatusForbidden)
+				return
+			}
+			tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			var principal string
+			switch {
+			case !ok:
+			case adminToken.matches(tok):
+				principal = principalAdminToken
+			default:
+				if c, err := verifySession(tok, time.Now()); err == nil {
+					principal = "session:" + c.ID
+				}
+			}
+			if principal == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeError(w, http.StatusUnauthorized, "missing, invalid or expired admin token")
+				auditRequest(r, "", http.StatusUnauthorized)
+				return
+			}
+			cw := &rwCapture{ResponseWriter: w}
+			next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				status := cw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				auditRequest(r, principal, status)
+			}
+		})
+	}
+}
+
+// withAdminAuthForWrites applies withAdminAuth to everything but GET, HEAD
+// and OPTIONS, for endpoints that anyone may read but only admins change.
+func withAdminAuthForWrites() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		guarded := withAdminAuth()(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+			default:
+				guarded.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+type secretStatus struct {
+	Name               string     `json:"name"`
+	RotatedAt          *time.Time `json:"rotatedAt,omitempty"`
+	PreviousValidUntil *time.Time `json:"previousValidUntil,omitempty"`
+}
+
+// secretsHandler lists rotatable secrets and their rotation state, never
+// their values.
+func secretsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	out := make([]secretStatus, 0, len(names))
+	for _, name := range names {
+		s := secrets[name]
+		s.mu.RLock()
+		st := secretStatus{Name: name}
+		if !s.rotatedAt.IsZero() {
+			rotatedAt, prevUntil := s.rotatedAt, s.prevUntil
+			st.RotatedAt, st.PreviousValidUntil = &rotatedAt, &prevUntil
+		}
+		s.mu.RUnlock()
+		out = append(out, st)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// rotateSecretHandler replaces the named secret. Only the admin token may
+// do this, so a session can't promote itself to admin by rotating the
+// token or the session key. The body may supply the new value and a grace
+// window; otherwise a random value is generated and the configured
+// SECRET_ROTATION_GRACE applies. New values are never returned, so the
+// admin token and webhook secret, which someone else has to know, must be
+// supplied.
+func rotateSecretHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if adminPrincipal(r.Context()) != principalAdminToken {
+		writeError(w, http.StatusForbidden, "secrets can only be rotated with the admin token")
+		return
+	}
+	name := r.PathValue("name")
+	s, ok := secrets[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown secret "+name)
+		return
+	}
+
+	var req struct {
+		Value string `json:"value"`
+		Grace string `json:"grace"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	grace := rotationGrace
+	if req.Grace != "" {
+		d, err := time.ParseDuration(req.Grace)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "grace must be a non-negative duration")
+			return
+		}
+		grace = d
+	}
+	generated := req.Value == ""
+	if generated {
+		if s == adminToken || s == webhookSecret {
+			writeError(w, http.StatusBadRequest, name+" must be given a value; it is never generated or returned")
+			return
+		}
+		req.Value = randomSecret()
+	}
+
+	prevUntil := s.rotate(req.Value, grace)
+	loggerFrom(r.Context()).Info("secret rotated", "name", name, "grace", grace.String(), "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"name":               name,
+		"generated":          generated,
+		"previousValidUntil": prevUntil.UTC().Format(time.RFC3339),
+	})
+}
+
+func randomSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
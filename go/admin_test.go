@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingSecretGraceWindow(t *testing.T) {
+	s := newRotatingSecret("old")
+	s.rotate("new", time.Hour)
+	if !s.matches("new") || !s.matches("old") {
+		t.Error("expected both values to be accepted during the grace window")
+	}
+
+	s.rotate("newer", 0)
+	if s.matches("new") {
+		t.Error("expected previous value to be rejected once the grace window has passed")
+	}
+	if s.matches("") {
+		t.Error("expected empty value to be rejected")
+	}
+}
+
+func TestRotateSecretHandler(t *testing.T) {
+	prev := adminToken.value()
+	t.Cleanup(func() { adminToken.rotate(prev, 0) })
+	adminToken.rotate("s3cret", 0)
+
+	h := chain(http.HandlerFunc(rotateSecretHandler), withAdminAuth())
+	rotate := func(name, token, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/api/admin/secrets/"+name+"/rotate", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetPathValue("name", name)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := rotate("admin-token", "wrong", `{"grace":"1m"}`); rr.Code != http.StatusUnauthorized {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := rotate("admin-token", "s3cret", `{"grace":"1m"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("rotating admin-token without a value: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	rr := rotate("admin-token", "s3cret", `{"value":"n3w","grace":"1m"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if strings.Contains(rr.Body.String(), "n3w") {
+		t.Errorf("response %s echoes the new token", rr.Body)
+	}
+	if !adminToken.matches("n3w") || !adminToken.matches("s3cret") {
+		t.Error("expected new and old admin tokens to be accepted after rotation")
+	}
+}
+
+func TestRotateSecretRequiresAdminToken(t *testing.T) {
+	prev := adminToken.value()
+	t.Cleanup(func() { adminToken.rotate(prev, 0) })
+	adminToken.rotate("s3cret", 0)
+	session := signSession(sessionClaims{ID: "s1", ExpiresAt: time.Now().Add(time.Hour).Unix()}, sessionKey.value())
+
+	h := chain(http.HandlerFunc(rotateSecretHandler), withAdminAuth())
+	for _, name := range []string{"admin-token", "session-key"} {
+		req := httptest.NewRequest("POST", "/api/admin/secrets/"+name+"/rotate", strings.NewReader(`{"value":"mine"}`))
+		req.SetPathValue("name", name)
+		req.Header.Set("Authorization", "Bearer "+session)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("session rotating %s: status %d, want 403", name, rr.Code)
+		}
+	}
+	if adminToken.matches("mine") {
+		t.Error("a session replaced the admin token")
+	}
+}
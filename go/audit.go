@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// Audit log. Every request that may change state (any method but GET, HEAD
+// and OPTIONS), refused admin requests and readiness toggles are written as
+// "audit" records to a stream of their own, separate from access and
+// application logs: AUDIT_LOG_FILE if set, otherwise the app's log output
+// (stdout and/or LOG_FILE) with log_type=audit so a pipeline can route them.
+// Each record says who (principal and client IP), what (method, route, path,
+// status) and when. Requests outside the admin API are recorded with
+// principal "anonymous".
+var auditLogger *slog.Logger // set in logging.go once logOutput is known
+
+func newAuditLogger(path string) *slog.Logger {
+	w := logOutput
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			logger.Error("cannot open AUDIT_LOG_FILE, auditing to the log output", "path", path, "err", err)
+		} else {
+			w = f
+		}
+	}
+	return slog.New(slog.NewJSONHandler(w, nil)).With("log_type", "audit")
+}
+
+// auditMarkKey carries an *auditMark from withAudit to auditRequest so a
+// request already audited by withAdminAuth isn't recorded twice.
+type auditMarkKey struct{}
+
+type auditMark struct{ done bool }
+
+// withAudit records every non-safe request that nothing further in has
+// audited.
+func withAudit() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			mark := &auditMark{}
+			r = r.WithContext(context.WithValue(r.Context(), auditMarkKey{}, mark))
+			cw := &rwCapture{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			if mark.done {
+				return
+			}
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			auditRequest(r, "anonymous", status)
+		})
+	}
+}
+
+// auditRequest records a request made by principal ("" if it was refused)
+// that finished with status.
+func auditRequest(r *http.Request, principal string, status int) {
+	if mark, ok := r.Context().Value(auditMarkKey{}).(*auditMark); ok {
+		mark.done = true
+	}
+	outcome := "ok"
+	switch {
+	case principal == "":
+		outcome = "denied"
+	case status >= 400:
+		outcome = "failed"
+	}
+	ip := clientIP(r)
+	auditLogger.Info("audit",
+		"principal", principal,
+		"client_ip", ip,
+		"method", r.Method,
+		"route", routeLabel(r),
+		"path", r.URL.Path,
+		"status", status,
+		"outcome", outcome,
+		"request_id", requestIDFrom(r.Context()),
+	)
+}
+
+// auditAction records a change made outside HTTP, such as a signal.
+func auditAction(principal, action string, attrs ...any) {
+	auditLogger.Info("audit", append([]any{"principal", principal, "action", action, "outcome", "ok"}, attrs...)...)
+}
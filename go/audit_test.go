@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminAudit(t *testing.T) {
+	prevLogger, prevToken := auditLogger, adminToken.value()
+	t.Cleanup(func() { auditLogger = prevLogger; adminToken.rotate(prevToken, 0) })
+	var buf bytes.Buffer
+	auditLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	adminToken.rotate("s3cret", 0)
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}), withAdminAuth())
+	call := func(method, token string) map[string]any {
+		buf.Reset()
+		r := httptest.NewRequest(method, "/api/admin/loglevel", nil)
+		r.RemoteAddr = "10.1.2.3:4444"
+		r.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if buf.Len() == 0 {
+			return nil
+		}
+		var rec map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatalf("audit record %q: %v", buf.String(), err)
+		}
+		return rec
+	}
+
+	if rec := call(http.MethodGet, "s3cret"); rec != nil {
+		t.Errorf("read was audited: %v", rec)
+	}
+	rec := call(http.MethodPut, "s3cret")
+	if rec["principal"] != principalAdminToken || rec["client_ip"] != "10.1.2.3" || rec["status"] != float64(http.StatusAccepted) || rec["outcome"] != "ok" {
+		t.Errorf("change audit = %v", rec)
+	}
+	rec = call(http.MethodGet, "wrong")
+	if rec["principal"] != "" || rec["outcome"] != "denied" || rec["status"] != float64(http.StatusUnauthorized) {
+		t.Errorf("refusal audit = %v", rec)
+	}
+}
+
+func TestAuditMutatingRequests(t *testing.T) {
+	prevLogger, prevToken := auditLogger, adminToken.value()
+	t.Cleanup(func() { auditLogger = prevLogger; adminToken.rotate(prevToken, 0) })
+	var buf bytes.Buffer
+	auditLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	adminToken.rotate("s3cret", 0)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	plain := chain(ok, withAudit())
+	admin := chain(ok, withAudit(), withAdminAuth())
+	records := func(h http.Handler, method, token string) []map[string]any {
+		buf.Reset()
+		r := httptest.NewRequest(method, "/api/kv/k", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		var out []map[string]any
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var rec map[string]any
+			if err := dec.Decode(&rec); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, rec)
+		}
+		return out
+	}
+
+	if recs := records(plain, http.MethodGet, ""); len(recs) != 0 {
+		t.Errorf("read was audited: %v", recs)
+	}
+	recs := records(plain, http.MethodDelete, "")
+	if len(recs) != 1 || recs[0]["principal"] != "anonymous" || recs[0]["method"] != "DELETE" || recs[0]["status"] != float64(http.StatusNoContent) {
+		t.Errorf("unauthenticated delete audit = %v", recs)
+	}
+	if recs := records(admin, http.MethodPut, "s3cret"); len(recs) != 1 || recs[0]["principal"] != principalAdminToken {
+		t.Errorf("admin change audit = %v, want one record", recs)
+	}
+}
+
+func TestAuditLoggerUsesLogOutput(t *testing.T) {
+	prev := logOutput
+	t.Cleanup(func() { logOutput = prev })
+	var buf bytes.Buffer
+	logOutput = &buf
+
+	newAuditLogger("").Info("audit", "action", "test")
+	if !bytes.Contains(buf.Bytes(), []byte(`"log_type":"audit"`)) {
+		t.Errorf("audit record not written to the log output: %q", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Request body limit. Every route's request body is capped at
+// MAX_BODY_BYTES (default 1 MiB; 0 disables the cap) with
+// http.MaxBytesReader. A request whose Content-Length is already over the
+// cap is refused with 413 before its handler runs; one that only turns out
+// to be too big while being read fails the read, and handlers report that
+// as 413 through writeBodyError. Routes with a tighter limit of their own,
+// such as KV_MAX_VALUE_BYTES, still apply it.
+var maxBodyBytes = int64(getenvInt("MAX_BODY_BYTES", 1<<20))
+
+func withBodyLimit() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := maxBodyBytes
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				w.Header().Set("Connection", "close")
+				writeBodyError(w, &http.MaxBytesError{Limit: limit})
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeBodyError reports a failure to read or decode a JSON request body:
+// 413 if the body was over the limit, 400 otherwise.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body exceeds "+strconv.FormatInt(mbe.Limit, 10)+" bytes")
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+}
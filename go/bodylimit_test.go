@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	prev := maxBodyBytes
+	maxBodyBytes = 16
+	t.Cleanup(func() { maxBodyBytes = prev })
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		if err := decodeJSON(r, &v); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), withBodyLimit())
+
+	cases := []struct {
+		name   string
+		body   string
+		length int64
+		want   int
+	}{
+		{"small", `{"a":1}`, -1, http.StatusNoContent},
+		{"declared too big", `{"a":"0123456789abcdef"}`, 24, http.StatusRequestEntityTooLarge},
+		{"chunked too big", `{"a":"0123456789abcdef"}`, -1, http.StatusRequestEntityTooLarge},
+		{"malformed", `{"a":`, -1, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/echo", strings.NewReader(tc.body))
+			r.ContentLength = tc.length
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if rr.Code != tc.want {
+				t.Errorf("status %d, want %d: %s", rr.Code, tc.want, rr.Body)
+			}
+			if tc.want == http.StatusRequestEntityTooLarge && !strings.Contains(rr.Body.String(), "exceeds 16 bytes") {
+				t.Errorf("body %s doesn't name the limit", rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dependency latency budgets. DEPENDENCY_BUDGETS assigns a per-request
+// latency budget to named dependencies, e.g. "payments=200ms,db=50ms".
+// Every downstream call made while serving a request is reported in that
+// response's Server-Timing header along with how much of its budget it
+// consumed, and aggregated for /api/budgets.
+var budgets = newBudgetTracker(mustParseBudgets(getenv("DEPENDENCY_BUDGETS", "")))
+
+type budgetTracker struct {
+	budgets map[string]time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*budgetStats
+}
+
+type budgetStats struct {
+	Dependency string  `json:"dependency"`
+	BudgetMs   float64 `json:"budgetMs,omitempty"`
+	Calls      int64   `json:"calls"`
+	OverBudget int64   `json:"overBudget"`
+	AvgMs      float64 `json:"avgMs"`
+	MaxMs      float64 `json:"maxMs"`
+	LastMs     float64 `json:"lastMs"`
+	totalMs    float64
+}
+
+func parseBudgets(spec string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(pair, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if !ok || err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid budget %q, want name=duration", pair)
+		}
+		out[strings.TrimSpace(name)] = d
+	}
+	return out, nil
+}
+
+func mustParseBudgets(spec string) map[string]time.Duration {
+	b, err := parseBudgets(spec)
+	if err != nil {
+		invalidConfig("DEPENDENCY_BUDGETS", err)
+	}
+	return b
+}
+
+func newBudgetTracker(b map[string]time.Duration) *budgetTracker {
+	return &budgetTracker{budgets: b, stats: make(map[string]*budgetStats)}
+}
+
+func (t *budgetTracker) record(name string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	budget, hasBudget := t.budgets[name]
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st, ok := t.stats[name]
+	if !ok {
+		st = &budgetStats{Dependency: name}
+		if hasBudget {
+			st.BudgetMs = float64(budget) / float64(time.Millisecond)
+		}
+		t.stats[name] = st
+	}
+	st.Calls++
+	st.totalMs += ms
+	st.AvgMs = st.totalMs / float64(st.Calls)
+	st.MaxMs = max(st.MaxMs, ms)
+	st.LastMs = ms
+	if hasBudget && d > budget {
+		st.OverBudget++
+		budgetExceeded.WithLabelValues(name).Inc()
+	}
+}
+
+func (t *budgetTracker) summary() []budgetStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]budgetStats, 0, len(t.stats))
+	for _, st := range t.stats {
+		out = append(out, *st)
+	}
+	slices.SortFunc(out, func(a, b budgetStats) int { return strings.Compare(a.Dependency, b.Dependency) })
+	return out
+}
+
+// requestTimings collects the downstream calls made while serving one
+// request.
+type requestTimings struct {
+	mu    sync.Mutex
+	calls []dependencyCall
+}
+
+type dependencyCall struct {
+	name string
+	dur  time.Duration
+}
+
+type timingsKey struct{}
+
+// trackDependencyCall records a downstream call against its budget and, if
+// ctx belongs to a request, against that request's Server-Timing header.
+func trackDependencyCall(ctx context.Context, name string, d time.Duration) {
+	budgets.record(name, d)
+	if rt, ok := ctx.Value(timingsKey{}).(*requestTimings); ok {
+		rt.mu.Lock()
+		rt.calls = append(rt.calls, dependencyCall{name: name, dur: d})
+		rt.mu.Unlock()
+	}
+}
+
+// serverTiming renders the calls as a Server-Timing header value, with the
+// share of each dependency's budget used in the description.
+func (rt *requestTimings) serverTiming() string {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	parts := make([]string, 0, len(rt.calls))
+	for _, c := range rt.calls {
+		ms := float64(c.dur) / float64(time.Millisecond)
+		part := fmt.Sprintf("%s;dur=%.1f", serverTimingToken(c.name), ms)
+		if b, ok := budgets.budgets[c.name]; ok {
+			part += fmt.Sprintf(`;desc="%.0f%% of %s budget"`, 100*float64(c.dur)/float64(b), b)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// serverTimingToken maps a dependency name onto the token characters
+// Server-Timing allows.
+func serverTimingToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('0' <= r && r <= '9') || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// withBudget attaches a per-request timing collector, unless
+// withSlowRequests already has, and emits the Server-Timing header just
+// before the response headers are sent.
+func withBudget() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rt, ok := r.Context().Value(timingsKey{}).(*requestTimings)
+			if !ok {
+				rt = &requestTimings{}
+				r = r.WithContext(context.WithValue(r.Context(), timingsKey{}, rt))
+			}
+			cw := &rwCapture{ResponseWriter: w, onHeader: func(h http.Header) {
+				if v := rt.serverTiming(); v != "" {
+					h.Add("Server-Timing", v)
+				}
+			}}
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+func budgetsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, budgets.summary())
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithBudgetEmitsServerTiming(t *testing.T) {
+	prev := budgets
+	t.Cleanup(func() { budgets = prev })
+	budgets = newBudgetTracker(map[string]time.Duration{"payments": 200 * time.Millisecond})
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trackDependencyCall(r.Context(), "payments", 300*time.Millisecond)
+		trackDependencyCall(r.Context(), "inventory svc", 10*time.Millisecond)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}), withBudget())
+
+	req, err := http.NewRequest("GET", "/readyz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	got := rr.Header().Get("Server-Timing")
+	want := `payments;dur=300.0;desc="150% of 200ms budget", inventory_svc;dur=10.0`
+	if got != want {
+		t.Errorf("Server-Timing header: got %q want %q", got, want)
+	}
+
+	sum := budgets.summary()
+	if len(sum) != 2 || sum[1].Dependency != "payments" || sum[1].OverBudget != 1 {
+		t.Errorf("unexpected budget summary: %+v", sum)
+	}
+}
+
+func TestTrackDependencyCallOutsideRequest(t *testing.T) {
+	prev := budgets
+	t.Cleanup(func() { budgets = prev })
+	budgets = newBudgetTracker(nil)
+
+	trackDependencyCall(context.Background(), "db", time.Millisecond)
+	if sum := budgets.summary(); len(sum) != 1 || sum[0].Calls != 1 {
+		t.Errorf("unexpected budget summary: %+v", sum)
+	}
+}
+
+func TestParseBudgets(t *testing.T) {
+	if _, err := parseBudgets("payments=fast"); err == nil || !strings.Contains(err.Error(), "payments") {
+		t.Errorf("expected an error naming the bad entry, got %v", err)
+	}
+}
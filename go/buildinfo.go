@@ -0,0 +1,25 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// commit is the source revision: GIT_COMMIT if set, otherwise the VCS
+// revision the Go toolchain stamped into the binary.
+var commit = getenv("GIT_COMMIT", vcsRevision())
+
+func vcsRevision() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && s.Value != "" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+func init() {
+	appBuildInfo.WithLabelValues(version, commit, buildTime, runtime.Version()).Set(1)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBuildInfoMetric(t *testing.T) {
+	if got := testutil.ToFloat64(appBuildInfo.WithLabelValues(version, commit, buildTime, runtime.Version())); got != 1 {
+		t.Errorf("app_build_info = %v, want 1", got)
+	}
+	if commit == "" {
+		t.Error("commit should fall back to a VCS revision or \"unknown\"")
+	}
+}
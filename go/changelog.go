@@ -0,0 +1,125 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The changelog is embedded from changelog.yaml (CHANGELOG_FILE overrides
+// it) and served at /api/changelog together with what the running version
+// shipped compared with the version deployed before it. The previous
+// version comes from "deploy" entries in the ledger, so it survives
+// restarts when STORE_BACKEND persists the ledger; a deploy of a version
+// that is older in the changelog than the previous one is a rollback.
+
+//go:embed changelog.yaml
+var embeddedChangelog []byte
+
+type changelog struct {
+	Releases []release `yaml:"releases" json:"releases"`
+}
+
+type release struct {
+	Version string   `yaml:"version" json:"version"`
+	Date    string   `yaml:"date" json:"date,omitempty"`
+	Changes []change `yaml:"changes" json:"changes"`
+}
+
+type change struct {
+	Type string `yaml:"type" json:"type"`
+	Text string `yaml:"text" json:"text"`
+}
+
+type changelogResponse struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous,omitempty"`
+	Rollback bool   `json:"rollback"`
+	// Shipped lists the releases the running version added on top of the
+	// previous one, or on a rollback, the releases it took away.
+	Shipped  []release `json:"shipped"`
+	Releases []release `json:"releases"`
+}
+
+var (
+	appChangelog    *changelog
+	previousVersion string
+)
+
+func loadChangelog(path string) (*changelog, error) {
+	b := embeddedChangelog
+	if path != "" {
+		var err error
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var c changelog
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse changelog: %w", err)
+	}
+	for i, r := range c.Releases {
+		if r.Version == "" {
+			return nil, fmt.Errorf("release %d has no version", i+1)
+		}
+	}
+	return &c, nil
+}
+
+// recordDeploy finds the most recent other version in the ledger's deploy
+// history and records a deploy entry if the version changed since the last
+// one.
+func recordDeploy(current string) string {
+	var last, previous string
+	for _, e := range ledger.list("deploy", 0) {
+		last = e.Subject
+		if e.Subject != current {
+			previous = e.Subject
+		}
+	}
+	if last != current {
+		ledger.record("deploy", current, "version "+current+" started", map[string]string{"previous": previous})
+	}
+	return previous
+}
+
+func (c *changelog) index(version string) int {
+	for i, r := range c.Releases {
+		if r.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// diff compares two versions by their position in the changelog (newest
+// first). Versions the changelog does not know fall back to the current
+// version's own notes.
+func (c *changelog) diff(current, previous string) (shipped []release, rollback bool) {
+	cur, prev := c.index(current), c.index(previous)
+	switch {
+	case cur < 0:
+		return []release{}, false
+	case prev < 0:
+		return c.Releases[cur : cur+1], false
+	case cur > prev:
+		return c.Releases[prev:cur], true
+	default:
+		return c.Releases[cur:prev], false
+	}
+}
+
+func changelogHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	resp := changelogResponse{Current: version, Previous: previousVersion, Shipped: []release{}, Releases: []release{}}
+	if appChangelog != nil {
+		resp.Releases = appChangelog.Releases
+		resp.Shipped, resp.Rollback = appChangelog.diff(version, previousVersion)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedChangelogParses(t *testing.T) {
+	c, err := loadChangelog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Releases) == 0 || c.Releases[0].Version == "" {
+		t.Errorf("embedded changelog has no releases: %+v", c)
+	}
+}
+
+func testChangelog() *changelog {
+	return &changelog{Releases: []release{{Version: "1.2.0"}, {Version: "1.1.0"}, {Version: "1.0.0"}}}
+}
+
+func TestChangelogDiff(t *testing.T) {
+	c := testChangelog()
+	versions := func(rs []release) (out []string) {
+		for _, r := range rs {
+			out = append(out, r.Version)
+		}
+		return out
+	}
+
+	shipped, rollback := c.diff("1.2.0", "1.0.0")
+	if got := versions(shipped); rollback || len(got) != 2 || got[0] != "1.2.0" || got[1] != "1.1.0" {
+		t.Errorf("upgrade 1.0.0 -> 1.2.0: %v rollback=%v", got, rollback)
+	}
+	shipped, rollback = c.diff("1.1.0", "1.2.0")
+	if got := versions(shipped); !rollback || len(got) != 1 || got[0] != "1.2.0" {
+		t.Errorf("rollback 1.2.0 -> 1.1.0: %v rollback=%v", got, rollback)
+	}
+	if shipped, _ := c.diff("1.1.0", ""); len(shipped) != 1 || shipped[0].Version != "1.1.0" {
+		t.Errorf("first deploy: %v", versions(shipped))
+	}
+	if shipped, _ := c.diff("9.9.9", "1.0.0"); len(shipped) != 0 {
+		t.Errorf("unknown version: %v", versions(shipped))
+	}
+}
+
+func TestRecordDeployTracksPreviousVersion(t *testing.T) {
+	withTestLedger(t, 10)
+	if prev := recordDeploy("1.0.0"); prev != "" {
+		t.Errorf("first deploy previous = %q", prev)
+	}
+	if prev := recordDeploy("1.0.0"); prev != "" {
+		t.Errorf("restart previous = %q", prev)
+	}
+	if prev := recordDeploy("1.1.0"); prev != "1.0.0" {
+		t.Errorf("upgrade previous = %q, want 1.0.0", prev)
+	}
+	if prev := recordDeploy("1.1.0"); prev != "1.0.0" {
+		t.Errorf("restart after upgrade previous = %q, want 1.0.0", prev)
+	}
+	if n := len(ledger.list("deploy", 0)); n != 2 {
+		t.Errorf("deploy entries = %d, want 2 (restarts are not deploys)", n)
+	}
+}
+
+func TestChangelogHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changelog.yaml")
+	if err := os.WriteFile(path, []byte("releases:\n  - version: "+version+"\n    changes:\n      - {type: added, text: widgets}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadChangelog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := appChangelog
+	appChangelog = c
+	t.Cleanup(func() { appChangelog = prev })
+
+	rr := httptest.NewRecorder()
+	changelogHandler(rr, httptest.NewRequest("GET", "/api/changelog", nil))
+	var resp changelogResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Current != version || len(resp.Shipped) != 1 || resp.Shipped[0].Changes[0].Text != "widgets" {
+		t.Errorf("response = %+v", resp)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Client IP resolution. By default the client is the connection's peer.
+// TRUSTED_PROXIES lists the CIDRs of load balancers and proxies in front of
+// the app ("private" stands for the private ranges, as in
+// IP_ACCESS_RULES); a request arriving from one of them is attributed to
+// the address it was forwarded for, taken from Forwarded, else
+// X-Forwarded-For, else X-Real-IP. Forwarding chains are read right to
+// left, skipping trusted hops, so a client can't pose as another by adding
+// addresses of its own. Access logs, rate limiting, IP access rules, debug
+// access, client stats, audit records and /api/ip all use the result.
+var trustedProxies = mustParseTrustedProxies(getenv("TRUSTED_PROXIES", ""))
+
+func parseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range strings.Split(spec, ",") {
+		if strings.TrimSpace(s) == "private" {
+			out = append(out, privateRanges...)
+			continue
+		}
+		p, err := parseCIDRList(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+func mustParseTrustedProxies(spec string) []netip.Prefix {
+	p, err := parseTrustedProxies(spec)
+	if err != nil {
+		invalidConfig("TRUSTED_PROXIES", err)
+	}
+	return p
+}
+
+func isTrustedProxy(a netip.Addr) bool {
+	for _, p := range trustedProxies {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerAddr returns the address of the connection r arrived on.
+func peerAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return parseForwardedAddr(host)
+}
+
+// clientAddr returns the address of the client r came from.
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	peer, ok := peerAddr(r)
+	if !ok || !isTrustedProxy(peer) {
+		return peer, ok
+	}
+	var hops []string
+	if v := r.Header.Values("Forwarded"); len(v) > 0 {
+		hops = forwardedFor(v)
+	} else if v := r.Header.Values("X-Forwarded-For"); len(v) > 0 {
+		for _, line := range v {
+			hops = append(hops, strings.Split(line, ",")...)
+		}
+	} else if v := r.Header.Get("X-Real-IP"); v != "" {
+		hops = []string{v}
+	}
+	// Walk back from the proxy nearest to us; the first hop we don't
+	// trust is the client.
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		a, ok := parseForwardedAddr(hops[i])
+		if !ok {
+			break
+		}
+		client = a
+		if !isTrustedProxy(a) {
+			break
+		}
+	}
+	return client, true
+}
+
+// clientIP returns clientAddr as a string, or the raw peer address if it
+// doesn't parse.
+func clientIP(r *http.Request) string {
+	if a, ok := clientAddr(r); ok {
+		return a.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor extracts the for= values of RFC 7239 Forwarded headers.
+func forwardedFor(values []string) []string {
+	var out []string
+	for _, line := range values {
+		for _, elem := range strings.Split(line, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(k, "for") {
+					out = append(out, strings.Trim(v, `"`))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// parseForwardedAddr parses an address as it appears in forwarding
+// headers: bare, with a port, or as a bracketed IPv6 address.
+func parseForwardedAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	a, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return a.Unmap(), true
+}
+
+// ipHandler reports the client address the app resolved for the caller
+// and how it got there.
+func ipHandler(w http.ResponseWriter, r *http.Request) {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	resp := map[string]any{"ip": clientIP(r), "peer": peer}
+	if a, ok := peerAddr(r); ok {
+		resp["peerTrusted"] = isTrustedProxy(a)
+	}
+	fwd := map[string]string{}
+	for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"} {
+		if v := r.Header.Values(h); len(v) > 0 {
+			fwd[h] = strings.Join(v, ", ")
+		}
+	}
+	if len(fwd) > 0 {
+		resp["forwarded"] = fwd
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withTrustedProxies(t *testing.T, spec string) {
+	t.Helper()
+	p, err := parseTrustedProxies(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := trustedProxies
+	trustedProxies = p
+	t.Cleanup(func() { trustedProxies = prev })
+}
+
+func TestClientIP(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8")
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "198.51.100.1:4000", nil, "198.51.100.1"},
+		{"untrusted peer's headers are ignored", "198.51.100.1:4000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "198.51.100.1"},
+		{"X-Forwarded-For", "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "203.0.113.9"}, "203.0.113.9"},
+		{"spoofed hops are skipped", "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "6.6.6.6, 203.0.113.9, 10.1.1.1"}, "203.0.113.9"},
+		{"Forwarded wins", "10.0.0.5:4000", map[string]string{"Forwarded": `for="[2001:db8::7]:1234";proto=https`, "X-Forwarded-For": "203.0.113.9"}, "2001:db8::7"},
+		{"X-Real-IP", "10.0.0.5:4000", map[string]string{"X-Real-IP": "203.0.113.10"}, "203.0.113.10"},
+		{"all hops trusted", "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "10.2.2.2"}, "10.2.2.2"},
+		{"garbage stops the walk", "10.0.0.5:4000", map[string]string{"X-Forwarded-For": "203.0.113.9, unknown"}, "10.0.0.5"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/api/ip", nil)
+		r.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			r.Header.Set(k, v)
+		}
+		if got := clientIP(r); got != tc.want {
+			t.Errorf("%s: clientIP = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIPHandler(t *testing.T) {
+	withTrustedProxies(t, "private")
+
+	r := httptest.NewRequest("GET", "/api/ip", nil)
+	r.RemoteAddr = "192.168.1.1:4000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	rr := httptest.NewRecorder()
+	ipHandler(rr, r)
+	var resp struct {
+		IP          string            `json:"ip"`
+		Peer        string            `json:"peer"`
+		PeerTrusted bool              `json:"peerTrusted"`
+		Forwarded   map[string]string `json:"forwarded"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || resp.IP != "203.0.113.9" || resp.Peer != "192.168.1.1" || !resp.PeerTrusted || resp.Forwarded["X-Forwarded-For"] != "203.0.113.9" {
+		t.Errorf("status %d response %+v", rr.Code, resp)
+	}
+}
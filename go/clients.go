@@ -0,0 +1,152 @@
+package main
+
+import (
+	"container/list"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Per-client request counts, for checking how a traffic shift landed on
+// each caller. A client is identified by its CLIENT_ID_HEADER header
+// (default X-Client-Id) or, without one, by its connection's IP address.
+// Only the CLIENT_TRACK_MAX (default 100) most recently seen clients are
+// kept; when a new client pushes the least recently seen one out, its
+// counts move to client="other". http_client_requests_total therefore has
+// at most CLIENT_TRACK_MAX+1 series, and the sum over all clients never
+// decreases. A client that is evicted and later returns starts again from
+// zero. Probe requests are not counted.
+var clients = newClientTracker(getenvInt("CLIENT_TRACK_MAX", 100), getenv("CLIENT_ID_HEADER", "X-Client-Id"))
+
+const (
+	clientOther    = "other"
+	clientIDMaxLen = 64
+)
+
+var (
+	clientRequestsDesc = prometheus.NewDesc("http_client_requests_total",
+		"Requests per client (X-Client-Id or IP), for the most recently seen clients; the rest are folded into client=\"other\".",
+		[]string{"client"}, nil)
+	clientErrorsDesc = prometheus.NewDesc("http_client_errors_total",
+		"Requests per client that ended in a 5xx, tracked like http_client_requests_total.",
+		[]string{"client"}, nil)
+)
+
+type clientTracker struct {
+	header string
+	max    int
+
+	mu    sync.Mutex
+	lru   *list.List // of *clientStats, most recent first
+	byKey map[string]*list.Element
+	other clientStats
+}
+
+type clientStats struct {
+	Client    string    `json:"client"`
+	Requests  uint64    `json:"requests"`
+	Errors    uint64    `json:"errors"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+func newClientTracker(size int, header string) *clientTracker {
+	return &clientTracker{header: header, max: max(size, 1), lru: list.New(), byKey: make(map[string]*list.Element), other: clientStats{Client: clientOther}}
+}
+
+// key identifies the client behind r.
+func (t *clientTracker) key(r *http.Request) string {
+	if id := r.Header.Get(t.header); id != "" {
+		// Label values must be valid UTF-8, so replace invalid bytes and
+		// cut on a character boundary.
+		id = strings.ToValidUTF8(id, "\uFFFD")
+		for len(id) > clientIDMaxLen {
+			_, size := utf8.DecodeLastRuneInString(id)
+			id = id[:len(id)-size]
+		}
+		return "id:" + id
+	}
+	return "ip:" + clientIP(r)
+}
+
+func (t *clientTracker) record(client string, status int, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var s *clientStats
+	if el, ok := t.byKey[client]; ok {
+		t.lru.MoveToFront(el)
+		s = el.Value.(*clientStats)
+	} else {
+		if t.lru.Len() >= t.max {
+			oldest := t.lru.Back()
+			evicted := t.lru.Remove(oldest).(*clientStats)
+			delete(t.byKey, evicted.Client)
+			t.other.Requests += evicted.Requests
+			t.other.Errors += evicted.Errors
+			if evicted.LastSeen.After(t.other.LastSeen) {
+				t.other.LastSeen = evicted.LastSeen
+			}
+			if t.other.FirstSeen.IsZero() || evicted.FirstSeen.Before(t.other.FirstSeen) {
+				t.other.FirstSeen = evicted.FirstSeen
+			}
+		}
+		s = &clientStats{Client: client, FirstSeen: now}
+		t.byKey[client] = t.lru.PushFront(s)
+	}
+	s.Requests++
+	if status >= 500 {
+		s.Errors++
+	}
+	s.LastSeen = now
+}
+
+// snapshot returns every tracked client plus "other" (if anything has been
+// folded into it), busiest first.
+func (t *clientTracker) snapshot() []clientStats {
+	t.mu.Lock()
+	out := make([]clientStats, 0, t.lru.Len()+1)
+	for el := t.lru.Front(); el != nil; el = el.Next() {
+		out = append(out, *el.Value.(*clientStats))
+	}
+	if t.other.Requests > 0 {
+		out = append(out, t.other)
+	}
+	t.mu.Unlock()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Requests > out[j].Requests })
+	return out
+}
+
+func (t *clientTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clientRequestsDesc
+	ch <- clientErrorsDesc
+}
+
+func (t *clientTracker) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range t.snapshot() {
+		// A series that can't be built is skipped rather than allowed to
+		// panic the scrape.
+		if m, err := prometheus.NewConstMetric(clientRequestsDesc, prometheus.CounterValue, float64(s.Requests), s.Client); err == nil {
+			ch <- m
+		}
+		if m, err := prometheus.NewConstMetric(clientErrorsDesc, prometheus.CounterValue, float64(s.Errors), s.Client); err == nil {
+			ch <- m
+		}
+	}
+}
+
+// clientStatsHandler serves GET /api/stats/clients.
+func clientStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"header":  clients.header,
+		"max":     clients.max,
+		"clients": clients.snapshot(),
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClientTrackerKey(t *testing.T) {
+	tr := newClientTracker(10, "X-Client-Id")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.1.2.3:4567"
+	if got := tr.key(r); got != "ip:10.1.2.3" {
+		t.Errorf("key = %q, want ip:10.1.2.3", got)
+	}
+	r.Header.Set("X-Client-Id", strings.Repeat("a", 100))
+	if got := tr.key(r); got != "id:"+strings.Repeat("a", clientIDMaxLen) {
+		t.Errorf("long client IDs should be truncated, got %q", got)
+	}
+}
+
+func TestClientTrackerInvalidUTF8(t *testing.T) {
+	tr := newClientTracker(10, "X-Client-Id")
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Client-Id", "caf\xc3")
+	tr.record(tr.key(r), 200, time.Now())
+	r.Header.Set("X-Client-Id", strings.Repeat("a", clientIDMaxLen-1)+"é")
+	long := tr.key(r)
+	if !utf8.ValidString(long) || len(long) > len("id:")+clientIDMaxLen {
+		t.Errorf("truncated key %q split a character", long)
+	}
+	tr.record(long, 200, time.Now())
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(tr)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+}
+
+func TestClientTrackerEvictsIntoOther(t *testing.T) {
+	tr := newClientTracker(2, "X-Client-Id")
+	now := time.Now()
+	tr.record("id:a", 200, now)
+	tr.record("id:a", 503, now)
+	tr.record("id:b", 200, now)
+	tr.record("id:a", 200, now) // a is now the most recent
+	tr.record("id:c", 200, now) // evicts b
+
+	got := map[string]clientStats{}
+	for _, s := range tr.snapshot() {
+		got[s.Client] = s
+	}
+	if len(got) != 3 {
+		t.Fatalf("snapshot = %+v, want a, c and other", got)
+	}
+	if a := got["id:a"]; a.Requests != 3 || a.Errors != 1 {
+		t.Errorf("a = %+v", a)
+	}
+	if _, ok := got["id:b"]; ok {
+		t.Error("b should have been evicted")
+	}
+	if o := got[clientOther]; o.Requests != 1 {
+		t.Errorf("other = %+v, want b's request", o)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(tr)
+	if n := testutil.CollectAndCount(tr, "http_client_requests_total"); n != 3 {
+		t.Errorf("http_client_requests_total has %d series, want 3", n)
+	}
+	want := `
+# HELP http_client_errors_total Requests per client that ended in a 5xx, tracked like http_client_requests_total.
+# TYPE http_client_errors_total counter
+http_client_errors_total{client="id:a"} 1
+http_client_errors_total{client="id:c"} 0
+http_client_errors_total{client="other"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "http_client_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Response compression. With COMPRESSION=true, responses are compressed for
+// clients that accept one of COMPRESS_ENCODINGS (default "zstd,br,gzip"; the
+// client's q-values win, then this order), once the body reaches
+// COMPRESS_MIN_BYTES (default 1024) and its Content-Type matches
+// COMPRESS_TYPES (a list of types, or prefixes ending in "/"; default text/,
+// JSON, JavaScript and SVG). Compression sits outside the route middleware,
+// so access logs and response size metrics count uncompressed bytes.
+// Content-Length is dropped from compressed responses, strong ETags are
+// weakened, and responses that are already encoded, partial or bodiless are
+// left alone.
+//
+// Levels are set per encoding: COMPRESS_GZIP_LEVEL (1-9, default 6),
+// COMPRESS_BROTLI_QUALITY (0-11, default 4) and COMPRESS_ZSTD_LEVEL (zstd
+// levels, default 3). The defaults favour speed over ratio, since most
+// responses are compressed on the fly.
+var compression = newCompressor(
+	getenvBool("COMPRESSION", false),
+	getenvInt("COMPRESS_MIN_BYTES", 1024),
+	getenv("COMPRESS_TYPES", "text/,application/json,application/javascript,image/svg+xml"),
+	getenv("COMPRESS_ENCODINGS", "zstd,br,gzip"),
+	compressLevels{
+		gzip:   getenvInt("COMPRESS_GZIP_LEVEL", gzip.DefaultCompression),
+		brotli: getenvInt("COMPRESS_BROTLI_QUALITY", 4),
+		zstd:   getenvInt("COMPRESS_ZSTD_LEVEL", 3),
+	},
+)
+
+type compressor struct {
+	enabled  bool
+	minBytes int
+	types    []string
+	// encoders in order of preference.
+	encoders []*encoder
+}
+
+type compressLevels struct{ gzip, brotli, zstd int }
+
+// encoder is one content coding, with a pool of its writers.
+type encoder struct {
+	name    string
+	writers sync.Pool
+}
+
+// encodingWriter is what gzip.Writer, brotli.Writer and zstd.Encoder have
+// in common.
+type encodingWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func newCompressor(enabled bool, minBytes int, types, encodings string, levels compressLevels) *compressor {
+	c := &compressor{enabled: enabled, minBytes: minBytes}
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(strings.ToLower(t)); t != "" {
+			c.types = append(c.types, t)
+		}
+	}
+	for _, name := range strings.Split(encodings, ",") {
+		e := &encoder{name: strings.TrimSpace(strings.ToLower(name))}
+		switch e.name {
+		case "gzip":
+			level := levels.gzip
+			if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+				level = gzip.DefaultCompression
+			}
+			e.writers.New = func() any {
+				zw, _ := gzip.NewWriterLevel(io.Discard, level)
+				return zw
+			}
+		case "br":
+			quality := min(max(levels.brotli, brotli.BestSpeed), brotli.BestCompression)
+			e.writers.New = func() any { return brotli.NewWriterLevel(io.Discard, quality) }
+		case "zstd":
+			level := zstd.EncoderLevelFromZstd(levels.zstd)
+			e.writers.New = func() any {
+				zw, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+				return zw
+			}
+		case "":
+			continue
+		default:
+			invalidConfig("COMPRESS_ENCODINGS", fmt.Errorf("unknown encoding %q", e.name))
+			continue
+		}
+		c.encoders = append(c.encoders, e)
+	}
+	return c
+}
+
+// compressible reports whether contentType is on the allowlist.
+func (c *compressor) compressible(contentType string) bool {
+	mt, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mt = strings.TrimSpace(mt)
+	for _, t := range c.types {
+		if mt == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mt, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate picks the encoder for an Accept-Encoding header value: the
+// highest q-value wins, ties go to the server's order, and q=0 and the
+// "*" wildcard are honoured. It returns nil if none is acceptable.
+func (c *compressor) negotiate(header string) *encoder {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			qs[name] = q
+		}
+	}
+	var best *encoder
+	bestQ := 0.0
+	for _, e := range c.encoders {
+		q, ok := qs[e.name]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+	return best
+}
+
+func withCompression(c *compressor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !c.enabled || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			enc := c.negotiate(r.Header.Get("Accept-Encoding"))
+			if enc == nil {
+				next.ServeHTTP(&varyWriter{ResponseWriter: w, c: c}, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, c: c, enc: enc}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// varyWriter adds Vary: Accept-Encoding to compressible responses sent
+// uncompressed, so caches don't serve them to clients that accept an
+// encoding.
+type varyWriter struct {
+	http.ResponseWriter
+	c           *compressor
+	wroteHeader bool
+}
+
+func (v *varyWriter) WriteHeader(code int) {
+	if !v.wroteHeader {
+		v.wroteHeader = true
+		if v.c.compressible(v.Header().Get("Content-Type")) {
+			v.Header().Add("Vary", "Accept-Encoding")
+		}
+	}
+	v.ResponseWriter.WriteHeader(code)
+}
+
+func (v *varyWriter) Write(b []byte) (int, error) {
+	if !v.wroteHeader {
+		if v.Header().Get("Content-Type") == "" {
+			v.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		v.WriteHeader(http.StatusOK)
+	}
+	return v.ResponseWriter.Write(b)
+}
+
+func (v *varyWriter) Unwrap() http.ResponseWriter { return v.ResponseWriter }
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it: when the buffer reaches minBytes, on Flush, or when the
+// handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	c       *compressor
+	enc     *encoder
+	status  int
+	buf     []byte
+	decided bool
+	zw      encodingWriter
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	if code < 200 {
+		// Informational responses (103 Early Hints) pass straight through.
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.status = code
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(append(cw.buf, b...)))
+		}
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.c.minBytes {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// decide sends the headers, compressing if big is set and the response
+// qualifies, then writes out the buffered body.
+func (cw *compressWriter) decide(big bool) error {
+	cw.decided = true
+	h := cw.Header()
+	compressible := cw.c.compressible(h.Get("Content-Type"))
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if big && compressible && h.Get("Content-Encoding") == "" && cw.status != http.StatusPartialContent {
+		if cl, err := strconv.Atoi(h.Get("Content-Length")); err != nil || cl >= cw.c.minBytes {
+			h.Del("Content-Length")
+			h.Set("Content-Encoding", cw.enc.name)
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			cw.zw = cw.enc.writers.Get().(encodingWriter)
+			cw.zw.Reset(cw.ResponseWriter)
+		}
+	}
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what has been written so far; a response flushed before it
+// reaches minBytes is streamed compressed if its type qualifies.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.zw != nil {
+		cw.zw.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			// Nothing was written; let net/http send its default response.
+			return
+		}
+		cw.decide(len(cw.buf) >= cw.c.minBytes)
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+		cw.enc.writers.Put(cw.zw)
+		cw.zw = nil
+	}
+}
+
+// Hijack hands over the connection for WebSocket upgrades, which are never
+// compressed.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter { return cw.ResponseWriter }
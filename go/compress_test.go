@@ -0,0 +1,115 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	c := newCompressor(true, 0, "text/", "zstd,br,gzip", compressLevels{})
+	for header, want := range map[string]string{
+		"gzip, deflate, br":        "br",
+		"gzip, deflate, br, zstd":  "zstd",
+		"br;q=0.5, gzip;q=1.0":     "gzip",
+		"gzip;q=0":                 "",
+		"*":                        "zstd",
+		"*;q=0, gzip":              "gzip",
+		"identity":                 "",
+		"":                         "",
+		"zstd;q=0, br;q=0, *;q=.1": "gzip",
+	} {
+		got := ""
+		if e := c.negotiate(header); e != nil {
+			got = e.name
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	c := newCompressor(true, 100, "text/,application/json", "zstd,br,gzip", compressLevels{gzip: gzip.BestSpeed, brotli: 4, zstd: 3})
+	big := strings.Repeat(`{"k":"v"},`, 50)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(big)))
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, big[:40])
+		io.WriteString(w, big[40:])
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "tiny")
+	})
+	mux.HandleFunc("/png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		io.WriteString(w, big)
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := chain(mux, withCompression(c))
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", accept)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := get("/json", "gzip")
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, encoding %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+	if rr.Header().Get("Content-Length") != "" || rr.Header().Get("ETag") != `W/"v1"` || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("headers = %v", rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != big {
+		t.Errorf("decompressed body = %q", body)
+	}
+
+	for enc, reader := range map[string]func(io.Reader) (io.Reader, error){
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	} {
+		rr := get("/json", "gzip;q=0.5, "+enc)
+		if rr.Header().Get("Content-Encoding") != enc {
+			t.Errorf("%s: Content-Encoding = %q", enc, rr.Header().Get("Content-Encoding"))
+			continue
+		}
+		zr, err := reader(rr.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != big {
+			t.Errorf("%s: decompressed body = %q", enc, body)
+		}
+	}
+
+	if rr := get("/json", "identity"); rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != big || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("without gzip: encoding %q, vary %q", rr.Header().Get("Content-Encoding"), rr.Header().Get("Vary"))
+	}
+	if rr := get("/small", "gzip"); rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != "tiny" {
+		t.Errorf("small body: encoding %q, body %q", rr.Header().Get("Content-Encoding"), rr.Body)
+	}
+	if rr := get("/png", "gzip"); rr.Header().Get("Content-Encoding") != "" || rr.Body.Len() != len(big) {
+		t.Errorf("disallowed type: encoding %q", rr.Header().Get("Content-Encoding"))
+	}
+	if rr := get("/none", "gzip"); rr.Code != http.StatusNoContent || rr.Header().Get("Content-Encoding") != "" {
+		t.Errorf("204: status %d, encoding %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Connection lifecycle, via http.Server.ConnState. http_connections tracks
+// open connections by state; when a connection ends,
+// http_connections_closed_total records how it ended and which state it
+// left from, and its lifetime and request count are observed. That makes
+// load-balancer keep-alive behaviour visible. Connections closed from
+// "new" never sent a request, which is typical of TCP health checks.
+// Connections closed from "idle" were reused until a keep-alive timeout on
+// one side.
+var conns = newConnTracker()
+
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]*connInfo
+}
+
+type connInfo struct {
+	state    http.ConnState
+	opened   time.Time
+	requests int
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]*connInfo)}
+}
+
+// track is the http.Server.ConnState hook.
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, ok := t.conns[c]
+	if !ok {
+		if state != http.StateNew {
+			// Connections opened before tracking started.
+			return
+		}
+		t.conns[c] = &connInfo{state: state, opened: time.Now()}
+		httpConnectionsOpened.Inc()
+		httpConnections.WithLabelValues(state.String()).Inc()
+		return
+	}
+
+	httpConnections.WithLabelValues(info.state.String()).Dec()
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+		httpConnectionsClosed.WithLabelValues(state.String(), info.state.String()).Inc()
+		httpConnectionDuration.Observe(time.Since(info.opened).Seconds())
+		httpConnectionRequests.Observe(float64(info.requests))
+		return
+	case http.StateActive:
+		info.requests++
+	}
+	info.state = state
+	httpConnections.WithLabelValues(state.String()).Inc()
+}
+
+// open returns the number of tracked connections in each state.
+func (t *connTracker) open() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := map[string]int{}
+	for _, info := range t.conns {
+		out[info.state.String()]++
+	}
+	return out
+}
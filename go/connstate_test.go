@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestConnTrackerKeepAlive(t *testing.T) {
+	tracker := newConnTracker()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = tracker.track
+	srv.Start()
+	defer srv.Close()
+
+	opened := testutil.ToFloat64(httpConnectionsOpened)
+	closedIdle := testutil.ToFloat64(httpConnectionsClosed.WithLabelValues("closed", "idle"))
+
+	// Two requests over one keep-alive connection.
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 1}}
+	for range 2 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	waitUntil(t, func() bool { return tracker.open()["idle"] == 1 })
+	if got := testutil.ToFloat64(httpConnectionsOpened) - opened; got != 1 {
+		t.Errorf("opened %v connections, want 1", got)
+	}
+
+	client.CloseIdleConnections()
+	waitUntil(t, func() bool { return len(tracker.open()) == 0 })
+	if got := testutil.ToFloat64(httpConnectionsClosed.WithLabelValues("closed", "idle")) - closedIdle; got != 1 {
+		t.Errorf("closed from idle = %v, want 1", got)
+	}
+}
+
+func TestConnTrackerNeverActive(t *testing.T) {
+	tracker := newConnTracker()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = tracker.track
+	srv.Start()
+	defer srv.Close()
+
+	before := testutil.ToFloat64(httpConnectionsClosed.WithLabelValues("closed", "new"))
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitUntil(t, func() bool { return tracker.open()["new"] == 1 })
+	c.Close()
+	waitUntil(t, func() bool { return len(tracker.open()) == 0 })
+	if got := testutil.ToFloat64(httpConnectionsClosed.WithLabelValues("closed", "new")) - before; got != 1 {
+		t.Errorf("closed from new = %v, want 1", got)
+	}
+}
+
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 2s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS. CORS_ALLOWED_ORIGINS ("https://ui.example.com,https://*.example.com",
+// or "*" for any; empty, the default, disables CORS) lists the origins
+// whose browser scripts may call the API. Preflight requests are answered
+// from the route registry alongside OPTIONS, allowing the route's methods,
+// the request headers in CORS_ALLOWED_HEADERS and caching for
+// CORS_MAX_AGE. Responses to allowed origins carry
+// Access-Control-Allow-Origin and expose the request ID header.
+// CORS_ALLOW_CREDENTIALS=true lets them send cookies and Authorization,
+// echoing the origin instead of "*". It can't be combined with "*": any
+// site could then read credentialed responses, CSRF tokens included, so
+// startup fails instead.
+var cors = mustCORSPolicy(
+	getenv("CORS_ALLOWED_ORIGINS", ""),
+	getenv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-CSRF-Token,"+requestIDHeader),
+	getenvDuration("CORS_MAX_AGE", 10*time.Minute),
+	getenvBool("CORS_ALLOW_CREDENTIALS", false),
+)
+
+type corsPolicy struct {
+	origins     []string
+	anyOrigin   bool
+	headers     string
+	maxAge      string
+	credentials bool
+}
+
+func newCORSPolicy(origins, headers string, maxAge time.Duration, credentials bool) (*corsPolicy, error) {
+	p := &corsPolicy{credentials: credentials, maxAge: strconv.Itoa(int(maxAge.Seconds()))}
+	for _, o := range strings.Split(origins, ",") {
+		switch o = strings.TrimRight(strings.TrimSpace(strings.ToLower(o)), "/"); o {
+		case "":
+		case "*":
+			p.anyOrigin = true
+		default:
+			p.origins = append(p.origins, o)
+		}
+	}
+	var hs []string
+	for _, h := range strings.Split(headers, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hs = append(hs, http.CanonicalHeaderKey(h))
+		}
+	}
+	p.headers = strings.Join(hs, ", ")
+	if p.anyOrigin && p.credentials {
+		return nil, errors.New(`"*" can't be combined with CORS_ALLOW_CREDENTIALS; list the origins instead`)
+	}
+	return p, nil
+}
+
+func mustCORSPolicy(origins, headers string, maxAge time.Duration, credentials bool) *corsPolicy {
+	p, err := newCORSPolicy(origins, headers, maxAge, credentials)
+	if err != nil {
+		invalidConfig("CORS_ALLOWED_ORIGINS", err)
+	}
+	return p
+}
+
+func (p *corsPolicy) enabled() bool { return p.anyOrigin || len(p.origins) > 0 }
+
+// allows reports whether origin may make cross-origin requests. An entry
+// "https://*.example.com" matches any subdomain of example.com.
+func (p *corsPolicy) allows(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	return slices.ContainsFunc(p.origins, func(o string) bool {
+		if scheme, domain, ok := strings.Cut(o, "://*."); ok {
+			rest, ok := strings.CutPrefix(origin, scheme+"://")
+			return ok && strings.HasSuffix(rest, "."+domain)
+		}
+		return o == origin
+	})
+}
+
+// annotate adds the headers every response to an allowed origin needs,
+// and reports whether the origin was allowed.
+func (p *corsPolicy) annotate(w http.ResponseWriter, r *http.Request) bool {
+	if !p.enabled() {
+		return false
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if !p.allows(origin) {
+		return false
+	}
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		// Only listed origins get here; newCORSPolicy refuses "*" with
+		// credentials.
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Expose-Headers", requestIDHeader)
+	return true
+}
+
+// preflight answers a CORS preflight for a route serving methods.
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request, methods []string) {
+	if !p.annotate(w, r) {
+		return
+	}
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if p.headers != "" {
+		h.Set("Access-Control-Allow-Headers", p.headers)
+	}
+	h.Set("Access-Control-Max-Age", p.maxAge)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicyAllows(t *testing.T) {
+	p := mustCORSPolicy("https://ui.example.com/, https://*.demo.test", "", time.Minute, false)
+	for origin, want := range map[string]bool{
+		"https://ui.example.com":   true,
+		"https://UI.example.com":   true,
+		"http://ui.example.com":    false,
+		"https://a.b.demo.test":    true,
+		"https://demo.test":        false,
+		"https://evil-demo.test":   false,
+		"https://ui.example.com.x": false,
+		"":                         false,
+	} {
+		if got := p.allows(origin); got != want {
+			t.Errorf("allows(%q) = %v, want %v", origin, got, want)
+		}
+	}
+	if mustCORSPolicy("", "", 0, false).enabled() {
+		t.Error("an empty origin list enables CORS")
+	}
+	if _, err := newCORSPolicy("*", "", 0, true); err == nil {
+		t.Error(`expected "*" with credentials to be rejected`)
+	}
+}
+
+func withTestCORS(t *testing.T, p *corsPolicy) {
+	t.Helper()
+	prev := cors
+	cors = p
+	t.Cleanup(func() { cors = prev })
+}
+
+func TestOptionsAndPreflight(t *testing.T) {
+	withTestCORS(t, mustCORSPolicy("https://ui.example.com", "content-type,x-csrf-token", 10*time.Minute, false))
+
+	called := false
+	mux := newRouteMux()
+	mux.HandleMethods("/api/kv/{key}", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), withAllowedMethods(mux)), http.MethodGet, http.MethodPut)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("OPTIONS", "/api/kv/a", nil))
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Allow") != "GET, HEAD, PUT, OPTIONS" {
+		t.Errorf("OPTIONS: status %d Allow %q", rr.Code, rr.Header().Get("Allow"))
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("plain OPTIONS got CORS headers")
+	}
+
+	r := httptest.NewRequest("OPTIONS", "/api/kv/a", nil)
+	r.Header.Set("Origin", "https://ui.example.com")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	h := rr.Header()
+	if rr.Code != http.StatusNoContent ||
+		h.Get("Access-Control-Allow-Origin") != "https://ui.example.com" ||
+		h.Get("Access-Control-Allow-Methods") != "GET, HEAD, PUT, OPTIONS" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type, X-Csrf-Token" ||
+		h.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: status %d headers %v", rr.Code, h)
+	}
+
+	r = httptest.NewRequest("OPTIONS", "/api/kv/a", nil)
+	r.Header.Set("Origin", "https://evil.test")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" || rr.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("preflight from a disallowed origin got %v", rr.Header())
+	}
+	if called {
+		t.Error("OPTIONS reached the handler")
+	}
+
+	r = httptest.NewRequest("GET", "/api/kv/a", nil)
+	r.Header.Set("Origin", "https://ui.example.com")
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+	if !called || rr.Header().Get("Access-Control-Allow-Origin") != "https://ui.example.com" || rr.Header().Get("Access-Control-Expose-Headers") != requestIDHeader {
+		t.Errorf("GET from an allowed origin: headers %v", rr.Header())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// CSRF protection, using the double-submit cookie pattern. With
+// CSRF_PROTECTION on (the default when APP_ENV is production), a
+// state-changing request (anything but GET, HEAD, OPTIONS and TRACE) must
+// repeat the csrf_token cookie's value in an X-CSRF-Token header, or it is
+// refused with 403. A page on another origin can make the browser send the
+// cookie but can't read it to set the header. GET /api/csrf issues the
+// cookie and returns its value for scripts. Requests carrying an
+// Authorization or X-Webhook-Signature header are exempt, since a browser
+// never attaches either to a cross-site request on its own.
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+var csrfProtection = getenvBool("CSRF_PROTECTION", env == "production")
+
+func withCSRF() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !csrfProtection || csrfSafe(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c, err := r.Cookie(csrfCookie)
+			sent := r.Header.Get(csrfHeader)
+			if err != nil || c.Value == "" || subtle.ConstantTimeCompare([]byte(c.Value), []byte(sent)) != 1 {
+				csrfRejections.WithLabelValues(routeLabel(r)).Inc()
+				writeError(w, http.StatusForbidden, "missing or invalid CSRF token; send the "+csrfCookie+" cookie's value in "+csrfHeader)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// csrfSafe reports whether r can't be a forged state change.
+func csrfSafe(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return r.Header.Get("Authorization") != "" || r.Header.Get("X-Webhook-Signature") != ""
+}
+
+// csrfHandler issues a CSRF token, reusing the caller's cookie if it has
+// one, and returns it along with the header to send it in.
+func csrfHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	token := randomSecret()
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		token = c.Value
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"token": token, "header": csrfHeader, "enabled": csrfProtection})
+}
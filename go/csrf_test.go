@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	prev := csrfProtection
+	csrfProtection = true
+	t.Cleanup(func() { csrfProtection = prev })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/csrf", csrfHandler)
+	mux.Handle("/api/kv/{key}", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), withCSRF()))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/csrf", nil))
+	var resp struct {
+		Token  string `json:"token"`
+		Header string `json:"header"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Token == "" || resp.Header != csrfHeader {
+		t.Fatalf("token response %s (%v)", rr.Body, err)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != resp.Token || cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Fatalf("cookies %v", cookies)
+	}
+
+	put := func(cookie, header, auth string) int {
+		r := httptest.NewRequest("PUT", "/api/kv/a", nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+		}
+		if header != "" {
+			r.Header.Set(csrfHeader, header)
+		}
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	before := testutil.ToFloat64(csrfRejections.WithLabelValues("/api/kv/{key}"))
+	if code := put(resp.Token, resp.Token, ""); code != http.StatusNoContent {
+		t.Errorf("matching token: status %d", code)
+	}
+	if code := put(resp.Token, "", ""); code != http.StatusForbidden {
+		t.Errorf("no header: status %d, want 403", code)
+	}
+	if code := put(resp.Token, "forged", ""); code != http.StatusForbidden {
+		t.Errorf("mismatched header: status %d, want 403", code)
+	}
+	if code := put("", "", "Bearer x"); code != http.StatusNoContent {
+		t.Errorf("bearer request: status %d, want it exempt", code)
+	}
+	if got := testutil.ToFloat64(csrfRejections.WithLabelValues("/api/kv/{key}")); got != before+2 {
+		t.Errorf("csrf_rejections_total grew by %v, want 2", got-before)
+	}
+
+	csrfProtection = false
+	if code := put("", "", ""); code != http.StatusNoContent {
+		t.Errorf("protection off: status %d", code)
+	}
+}
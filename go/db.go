@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Optional database dependency. When DB_DSN is set the app opens a small
+// pool, adds a ping-based "db" readiness check, and exports pool stats in
+// /api/info and as go_sql_* metrics. DB_DRIVER (pgx or mysql) is inferred
+// from the DSN when unset.
+var (
+	appDB       *sql.DB
+	appDBDriver string
+	dbPingWait  = getenvDuration("DB_PING_TIMEOUT", 2*time.Second)
+)
+
+type dbInfo struct {
+	Driver         string `json:"driver"`
+	MaxOpen        int    `json:"maxOpen"`
+	Open           int    `json:"open"`
+	InUse          int    `json:"inUse"`
+	Idle           int    `json:"idle"`
+	WaitCount      int64  `json:"waitCount"`
+	WaitDurationMs int64  `json:"waitDurationMs"`
+}
+
+// dbDriverFor guesses the driver from the DSN: URLs and key=value strings
+// are Postgres, anything else is treated as a go-sql-driver/mysql DSN.
+func dbDriverFor(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"), strings.Contains(dsn, "host="):
+		return "pgx"
+	default:
+		return "mysql"
+	}
+}
+
+func openDB(dsn string, reg prometheus.Registerer) error {
+	driver := getenv("DB_DRIVER", dbDriverFor(dsn))
+	if driver != "pgx" && driver != "mysql" {
+		return fmt.Errorf("unsupported DB_DRIVER %q (want pgx or mysql)", driver)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(getenvInt("DB_MAX_OPEN_CONNS", 5))
+	db.SetMaxIdleConns(getenvInt("DB_MAX_IDLE_CONNS", 2))
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	if err := reg.Register(collectors.NewDBStatsCollector(db, driver)); err != nil {
+		db.Close()
+		return fmt.Errorf("register DB stats collector: %w", err)
+	}
+	appDB, appDBDriver = db, driver
+	health.register("db", probeReady, pingDB)
+	logger.Info("database configured", "driver", driver)
+	return nil
+}
+
+func pingDB(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dbPingWait)
+	defer cancel()
+	start := time.Now()
+	err := appDB.PingContext(ctx)
+	trackDependencyCall(ctx, "db", time.Since(start))
+	return err
+}
+
+func currentDBInfo() *dbInfo {
+	if appDB == nil {
+		return nil
+	}
+	st := appDB.Stats()
+	return &dbInfo{
+		Driver:         appDBDriver,
+		MaxOpen:        st.MaxOpenConnections,
+		Open:           st.OpenConnections,
+		InUse:          st.InUse,
+		Idle:           st.Idle,
+		WaitCount:      st.WaitCount,
+		WaitDurationMs: st.WaitDuration.Milliseconds(),
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestDBDriverFor(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"postgres://app:secret@db:5432/app?sslmode=disable", "pgx"},
+		{"postgresql://db/app", "pgx"},
+		{"host=db user=app dbname=app", "pgx"},
+		{"app:secret@tcp(db:3306)/app?parseTime=true", "mysql"},
+	}
+	for _, tt := range tests {
+		if got := dbDriverFor(tt.dsn); got != tt.want {
+			t.Errorf("dbDriverFor(%q) = %q want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentDBInfoWithoutDatabase(t *testing.T) {
+	if info := currentDBInfo(); info != nil {
+		t.Errorf("expected no database info when DB_DSN is unset, got %+v", info)
+	}
+}
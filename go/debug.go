@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Debug endpoints. ENABLE_PPROF=true mounts net/http/pprof under
+// /debug/pprof/ so a misbehaving pod can be profiled without rebuilding the
+// image. Everything under /debug/ is subject to the debug access policy
+// (DEBUG_ALLOWED_CIDRS, DEBUG_USERNAME/DEBUG_PASSWORD). When DEBUG_ADDR is
+// set (e.g. "127.0.0.1:6060") the debug endpoints are served only on that
+// separate listener, which keeps them off the public port entirely;
+// otherwise they share the main port.
+//
+// With pprof enabled, POST /debug/dump?type=heap|goroutine|block also
+// writes a profile to a file in DUMP_DIR (default the system temp dir) and
+// returns its path, for capturing state during an incident without an
+// interactive pprof session; add stream=true to download it instead.
+// Block profiles are only populated when BLOCK_PROFILE_RATE (nanoseconds,
+// see runtime.SetBlockProfileRate) is set.
+//
+// GET /debug/profile?seconds=30 streams a CPU profile. Only one capture
+// runs at a time (a second gets 409), and seconds may not exceed
+// CPU_PROFILE_MAX (default 2m). /debug/pprof/profile is served by the same
+// handler, so `go tool pprof` gets the same limits.
+var (
+	pprofEnabled     = getenvBool("ENABLE_PPROF", false)
+	debugAddr        = getenv("DEBUG_ADDR", "")
+	dumpDir          = getenv("DUMP_DIR", os.TempDir())
+	blockProfileRate = getenvInt("BLOCK_PROFILE_RATE", 0)
+	cpuProfileMax    = getenvDuration("CPU_PROFILE_MAX", 2*time.Minute)
+
+	cpuProfiling atomic.Bool
+)
+
+func init() {
+	if blockProfileRate > 0 {
+		runtime.SetBlockProfileRate(blockProfileRate)
+	}
+}
+
+// newDebugMux returns the handler for everything under /debug/.
+func newDebugMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	if pprofEnabled {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", cpuProfileHandler)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.HandleFunc("/debug/dump", dumpHandler)
+		mux.HandleFunc("/debug/profile", cpuProfileHandler)
+	}
+	return mux
+}
+
+// newDebugHandler wraps the debug mux in auditing, the debug access policy
+// and READ_ONLY. The mux doesn't go through routeMiddleware, and may be
+// served on the DEBUG_ADDR listener, so they are applied here.
+func newDebugHandler() http.Handler {
+	return chain(newDebugMux(), withAudit(), withDebugAccess(debugAccess), withReadOnly())
+}
+
+// startDebugServer serves h on DEBUG_ADDR and returns a function that
+// shuts it down.
+func startDebugServer(h http.Handler) func(context.Context) error {
+	srv := &http.Server{Addr: debugAddr, Handler: h, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("debug listener failed", "addr", debugAddr, "err", err)
+		}
+	}()
+	logger.Info("debug listener starting", "addr", debugAddr, "pprof", pprofEnabled)
+	return srv.Shutdown
+}
+
+var dumpTypes = []string{"heap", "goroutine", "block"}
+
+type dumpResult struct {
+	Type      string    `json:"type"`
+	Path      string    `json:"path"`
+	Bytes     int64     `json:"bytes"`
+	CreatedAt time.Time `json:"createdAt"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// dumpHandler serves POST /debug/dump?type=heap|goroutine|block[&stream=true].
+func dumpHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	typ := r.URL.Query().Get("type")
+	p := rpprof.Lookup(typ)
+	if p == nil || !slices.Contains(dumpTypes, typ) {
+		writeError(w, http.StatusBadRequest, "type must be one of heap, goroutine, block")
+		return
+	}
+	now := time.Now().UTC()
+	name := fmt.Sprintf("dump-%s-%s-%s.pb.gz", typ, replica.ID, now.Format("20060102T150405Z"))
+	if r.URL.Query().Get("stream") == "true" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		if err := p.WriteTo(w, 0); err != nil {
+			loggerFrom(r.Context()).Warn("streaming profile failed", "type", typ, "err", err)
+		}
+		return
+	}
+
+	path := filepath.Join(dumpDir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "create dump file: "+err.Error())
+		return
+	}
+	err = p.WriteTo(f, 0)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		writeError(w, http.StatusInternalServerError, "write profile: "+err.Error())
+		return
+	}
+	res := dumpResult{Type: typ, Path: path, CreatedAt: now}
+	if fi, err := os.Stat(path); err == nil {
+		res.Bytes = fi.Size()
+	}
+	if typ == "block" && blockProfileRate <= 0 {
+		res.Note = "block profiling is off; set BLOCK_PROFILE_RATE to record blocking events"
+	}
+	loggerFrom(r.Context()).Info("profile dumped", "type", typ, "path", path, "bytes", res.Bytes)
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// cpuProfileHandler serves GET /debug/profile?seconds=N (default 30). The
+// capture stops early if the client goes away.
+func cpuProfileHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	d := 30 * time.Second
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "seconds must be a positive integer")
+			return
+		}
+		// Clamp before converting; a huge n overflows the Duration and
+		// would come out negative.
+		d = time.Duration(min(n, int64(cpuProfileMax/time.Second)+1)) * time.Second
+	}
+	if d > cpuProfileMax {
+		writeError(w, http.StatusBadRequest, "seconds exceeds CPU_PROFILE_MAX ("+cpuProfileMax.String()+")")
+		return
+	}
+	if !cpuProfiling.CompareAndSwap(false, true) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(cpuProfileMax.Seconds())))
+		writeError(w, http.StatusConflict, "a CPU profile is already being captured")
+		return
+	}
+	defer cpuProfiling.Store(false)
+
+	name := fmt.Sprintf("cpu-%s-%s.pb.gz", replica.ID, time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if err := rpprof.StartCPUProfile(w); err != nil {
+		// Something else in the process is already profiling.
+		w.Header().Del("Content-Disposition")
+		writeError(w, http.StatusConflict, "could not start CPU profile: "+err.Error())
+		return
+	}
+	loggerFrom(r.Context()).Info("CPU profile started", "duration", d.String())
+	t := time.NewTimer(d)
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+		t.Stop()
+	}
+	rpprof.StopCPUProfile()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugMuxPprof(t *testing.T) {
+	prev := pprofEnabled
+	t.Cleanup(func() { pprofEnabled = prev })
+
+	get := func(h http.Handler, path string, auth bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth {
+			r.SetBasicAuth("ops", "pw")
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	pprofEnabled = false
+	if rr := get(newDebugMux(), "/debug/pprof/", false); rr.Code != http.StatusNotFound {
+		t.Errorf("pprof disabled: status = %d, want 404", rr.Code)
+	}
+
+	pprofEnabled = true
+	h := newDebugMux()
+	if rr := get(h, "/debug/pprof/", false); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "goroutine") {
+		t.Errorf("pprof index: status = %d", rr.Code)
+	}
+	if rr := get(h, "/debug/pprof/goroutine?debug=1", false); rr.Code != http.StatusOK {
+		t.Errorf("goroutine profile: status = %d", rr.Code)
+	}
+
+	guarded := chain(h, withDebugAccess(&debugAccessPolicy{username: "ops", password: "pw"}))
+	if rr := get(guarded, "/debug/pprof/", false); rr.Code != http.StatusUnauthorized {
+		t.Errorf("without credentials: status = %d, want 401", rr.Code)
+	}
+	if rr := get(guarded, "/debug/pprof/", true); rr.Code != http.StatusOK {
+		t.Errorf("with credentials: status = %d, want 200", rr.Code)
+	}
+}
+
+func TestDumpHandler(t *testing.T) {
+	prev := dumpDir
+	dumpDir = t.TempDir()
+	t.Cleanup(func() { dumpDir = prev })
+
+	rr := httptest.NewRecorder()
+	dumpHandler(rr, httptest.NewRequest(http.MethodPost, "/debug/dump?type=goroutine", nil))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	var res dumpResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(res.Path) != dumpDir || res.Bytes == 0 {
+		t.Errorf("result = %+v", res)
+	}
+	if fi, err := os.Stat(res.Path); err != nil || fi.Size() != res.Bytes {
+		t.Errorf("dump file: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	dumpHandler(rr, httptest.NewRequest(http.MethodPost, "/debug/dump?type=heap&stream=true", nil))
+	if rr.Code != http.StatusOK || rr.Body.Len() == 0 || !strings.Contains(rr.Header().Get("Content-Disposition"), "dump-heap-") {
+		t.Errorf("stream: status = %d, %d bytes", rr.Code, rr.Body.Len())
+	}
+
+	for _, target := range []string{"/debug/dump?type=threadcreate", "/debug/dump"} {
+		rr = httptest.NewRecorder()
+		dumpHandler(rr, httptest.NewRequest(http.MethodPost, target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rr.Code)
+		}
+	}
+}
+
+func TestDebugHandlerHonoursReadOnly(t *testing.T) {
+	prevPprof := pprofEnabled
+	pprofEnabled, readOnly = true, true
+	t.Cleanup(func() { pprofEnabled, readOnly = prevPprof, false })
+
+	rr := httptest.NewRecorder()
+	newDebugHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/debug/dump?type=heap", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("POST /debug/dump in read-only mode: status %d, want 403", rr.Code)
+	}
+}
+
+func TestCPUProfileHandler(t *testing.T) {
+	prev := cpuProfileMax
+	cpuProfileMax = 5 * time.Second
+	t.Cleanup(func() { cpuProfileMax = prev })
+
+	for _, target := range []string{"/debug/profile?seconds=0", "/debug/profile?seconds=x", "/debug/profile?seconds=10", "/debug/profile?seconds=9223372036854775807"} {
+		rr := httptest.NewRecorder()
+		cpuProfileHandler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, rr.Code)
+		}
+	}
+
+	cpuProfiling.Store(true)
+	rr := httptest.NewRecorder()
+	cpuProfileHandler(rr, httptest.NewRequest(http.MethodGet, "/debug/profile?seconds=1", nil))
+	cpuProfiling.Store(false)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("concurrent capture: status = %d, want 409", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	cpuProfileHandler(rr, httptest.NewRequest(http.MethodGet, "/debug/profile?seconds=1", nil))
+	if rr.Code != http.StatusOK || rr.Body.Len() == 0 {
+		t.Errorf("capture: status = %d, %d bytes", rr.Code, rr.Body.Len())
+	}
+	if cpuProfiling.Load() {
+		t.Error("capture flag not released")
+	}
+}
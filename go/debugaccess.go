@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Debug endpoint access. /metrics (and any other endpoint that exposes
+// runtime internals) is open by default. DEBUG_ALLOWED_CIDRS
+// ("10.0.0.0/8,127.0.0.1/32") restricts it to clients in those ranges, and
+// DEBUG_USERNAME plus DEBUG_PASSWORD require HTTP basic auth. When both are
+// set a request must pass both. The client address is the connection's
+// peer address, unless that is one of TRUSTED_PROXIES.
+var debugAccess = mustDebugAccessFromEnv()
+
+type debugAccessPolicy struct {
+	allowed  []netip.Prefix
+	username string
+	password string
+}
+
+func parseCIDRList(spec string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		// Bare addresses are accepted as single-host ranges.
+		if !strings.Contains(s, "/") {
+			a, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", s)
+			}
+			out = append(out, netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", s)
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+func mustDebugAccessFromEnv() *debugAccessPolicy {
+	allowed, err := parseCIDRList(getenv("DEBUG_ALLOWED_CIDRS", ""))
+	if err != nil {
+		invalidConfig("DEBUG_ALLOWED_CIDRS", err)
+	}
+	return &debugAccessPolicy{
+		allowed:  allowed,
+		username: getenv("DEBUG_USERNAME", ""),
+		password: getenv("DEBUG_PASSWORD", ""),
+	}
+}
+
+// enabled reports whether any restriction is configured.
+func (p *debugAccessPolicy) enabled() bool {
+	return len(p.allowed) > 0 || p.password != ""
+}
+
+func (p *debugAccessPolicy) allowsAddr(remoteAddr string) bool {
+	if len(p.allowed) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	a, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	a = a.Unmap()
+	for _, pfx := range p.allowed {
+		if pfx.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *debugAccessPolicy) allowsCredentials(r *http.Request) bool {
+	if p.password == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// Compare both so the result doesn't reveal which one was wrong.
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(p.username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(p.password)) == 1
+	return userOK && passOK
+}
+
+// withDebugAccess guards a debug endpoint with the configured policy.
+// Disallowed addresses get a 403 and bad credentials a 401 challenge.
+func withDebugAccess(p *debugAccessPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !p.allowsAddr(clientIP(r)) {
+				debugAccessDenied.WithLabelValues("ip").Inc()
+				writeError(w, http.StatusForbidden, "client address not allowed")
+				return
+			}
+			if !p.allowsCredentials(r) {
+				debugAccessDenied.WithLabelValues("auth").Inc()
+				w.Header().Set("WWW-Authenticate", `Basic realm="debug", charset="UTF-8"`)
+				writeError(w, http.StatusUnauthorized, "missing or invalid credentials")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
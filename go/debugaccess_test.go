@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseCIDRList(t *testing.T) {
+	got, err := parseCIDRList("10.1.2.3/8, 192.168.0.7 ,::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.0.7/32", "::1/128"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if _, err := parseCIDRList("10.0.0.0/33"); err == nil {
+		t.Error("expected an error for an invalid prefix")
+	}
+}
+
+func TestWithDebugAccess(t *testing.T) {
+	allowed, _ := parseCIDRList("10.0.0.0/8")
+	p := &debugAccessPolicy{allowed: allowed, username: "prom", password: "s3cret"}
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), withDebugAccess(p))
+
+	cases := []struct {
+		name       string
+		remote     string
+		user, pass string
+		want       int
+	}{
+		{"allowed with credentials", "10.2.3.4:5555", "prom", "s3cret", http.StatusOK},
+		{"ipv4-mapped address", "[::ffff:10.2.3.4]:5555", "prom", "s3cret", http.StatusOK},
+		{"outside allowlist", "192.168.1.1:5555", "prom", "s3cret", http.StatusForbidden},
+		{"wrong password", "10.2.3.4:5555", "prom", "nope", http.StatusUnauthorized},
+		{"no credentials", "10.2.3.4:5555", "", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req.RemoteAddr = tc.remote
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.pass)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+			if tc.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestDebugAccessOpenByDefault(t *testing.T) {
+	p := &debugAccessPolicy{}
+	if p.enabled() {
+		t.Fatal("empty policy should be disabled")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if !p.allowsAddr(req.RemoteAddr) || !p.allowsCredentials(req) {
+		t.Error("empty policy should allow every request")
+	}
+}
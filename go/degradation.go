@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Degradation matrix. /api/degradation lists each optional subsystem with a
+// state (ok, degraded, disabled or unknown), a stable machine-readable code
+// and a human reason, so demos can show the app running in a partial mode
+// rather than just up or down. Subsystems backed by a health check report
+// that check's most recent result; probes are not re-run for this endpoint.
+type subsystemStatus struct {
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Code   string `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type degradationReport struct {
+	// Mode is "full" when nothing is degraded and "partial" otherwise.
+	Mode       string            `json:"mode"`
+	Subsystems []subsystemStatus `json:"subsystems"`
+}
+
+var (
+	subsystemReportersMu sync.Mutex
+	subsystemReporters   []func() []subsystemStatus
+)
+
+// registerSubsystem adds a reporter to the degradation matrix.
+func registerSubsystem(fn func() []subsystemStatus) {
+	subsystemReportersMu.Lock()
+	defer subsystemReportersMu.Unlock()
+	subsystemReporters = append(subsystemReporters, fn)
+}
+
+func init() {
+	registerSubsystem(checkSubsystem("database", "db", "DB", "DB_DSN is not set"))
+	registerSubsystem(checkSubsystem("cache", "redis", "CACHE", "REDIS_ADDR is not set"))
+	registerSubsystem(checkSubsystem("dns", "dns", "DNS", "DNS_CHECK_HOST is not set"))
+	registerSubsystem(checkSubsystem("disk", "disk", "DISK", "DISK_CHECK_PATH is not set"))
+	registerSubsystem(checkSubsystem("memory", "memory", "MEMORY", ""))
+	registerSubsystem(checkSubsystem("tls", "tls-expiry", "TLS", "TLS_CERT_FILE/TLS_KEY_FILE are not set and no TLS_EXPIRY_PEERS"))
+	registerSubsystem(peerSubsystems)
+	registerSubsystem(func() []subsystemStatus {
+		switch {
+		case pauseRemaining() > 0:
+			return []subsystemStatus{{"traffic", "degraded", "TRAFFIC_PAUSED", "traffic paused for another " + currentPauseStatus().Remaining}}
+		case maintenancePage && inMaintenance():
+			return []subsystemStatus{{"traffic", "degraded", "MAINTENANCE", "maintenance file " + maintenanceFile + " present"}}
+		}
+		return []subsystemStatus{{"traffic", "ok", "TRAFFIC_OK", ""}}
+	})
+	registerSubsystem(func() []subsystemStatus {
+		if activeLatencyModel.Load() != nil {
+			return []subsystemStatus{{"latency-injection", "degraded", "LATENCY_INJECTED", "a latency model is delaying requests"}}
+		}
+		return []subsystemStatus{{"latency-injection", "ok", "LATENCY_NONE", ""}}
+	})
+	registerSubsystem(func() []subsystemStatus {
+		if adminToken.value() == "" {
+			return []subsystemStatus{{"admin-api", "disabled", "ADMIN_NOT_CONFIGURED", "ADMIN_TOKEN is not set"}}
+		}
+		return []subsystemStatus{{"admin-api", "ok", "ADMIN_OK", ""}}
+	})
+}
+
+// checkSubsystem reports a subsystem from the last result of its health
+// check. Codes are prefix plus _OK, _DEGRADED, _CHECK_FAILED, _NOT_CHECKED
+// or _NOT_CONFIGURED.
+func checkSubsystem(name, check, prefix, notConfigured string) func() []subsystemStatus {
+	return func() []subsystemStatus {
+		return []subsystemStatus{subsystemFromCheck(name, check, prefix, notConfigured)}
+	}
+}
+
+func subsystemFromCheck(name, check, prefix, notConfigured string) subsystemStatus {
+	res, registered, ran := health.lastResult(check)
+	switch {
+	case !registered:
+		return subsystemStatus{name, "disabled", prefix + "_NOT_CONFIGURED", notConfigured}
+	case !ran:
+		return subsystemStatus{name, "unknown", prefix + "_NOT_CHECKED", "the " + check + " check has not run yet"}
+	case res.Status == "failed":
+		return subsystemStatus{name, "degraded", prefix + "_CHECK_FAILED", res.Error}
+	case res.Status == "degraded":
+		return subsystemStatus{name, "degraded", prefix + "_DEGRADED", res.Error}
+	}
+	return subsystemStatus{name, "ok", prefix + "_OK", ""}
+}
+
+func peerSubsystems() []subsystemStatus {
+	if len(registeredDependencies) == 0 {
+		return []subsystemStatus{{"peers", "disabled", "PEERS_NOT_CONFIGURED", "DEPENDENCY_URLS is not set"}}
+	}
+	out := make([]subsystemStatus, 0, len(registeredDependencies))
+	for _, d := range registeredDependencies {
+		out = append(out, subsystemFromCheck("peer:"+d.name, d.name, "PEER", ""))
+	}
+	return out
+}
+
+func currentDegradation() degradationReport {
+	subsystemReportersMu.Lock()
+	reporters := subsystemReporters
+	subsystemReportersMu.Unlock()
+
+	rep := degradationReport{Mode: "full", Subsystems: []subsystemStatus{}}
+	for _, fn := range reporters {
+		for _, s := range fn() {
+			if s.State == "degraded" {
+				rep.Mode = "partial"
+			}
+			rep.Subsystems = append(rep.Subsystems, s)
+		}
+	}
+	return rep
+}
+
+func degradationHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	rep := currentDegradation()
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := []subsystemStatus{}
+		for _, s := range rep.Subsystems {
+			if strings.EqualFold(s.State, state) {
+				filtered = append(filtered, s)
+			}
+		}
+		rep.Subsystems = filtered
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSubsystemFromCheck(t *testing.T) {
+	withTestHealth(t)
+	if s := subsystemFromCheck("database", "db", "DB", "DB_DSN is not set"); s.State != "disabled" || s.Code != "DB_NOT_CONFIGURED" {
+		t.Errorf("unregistered check: got %+v", s)
+	}
+
+	fail := errors.New("connection refused")
+	health.register("db", probeReady, func(context.Context) error { return fail })
+	if s := subsystemFromCheck("database", "db", "DB", ""); s.State != "unknown" {
+		t.Errorf("check that never ran: got %+v", s)
+	}
+	health.run(context.Background(), probeReady, nil, nil)
+	if s := subsystemFromCheck("database", "db", "DB", ""); s.State != "degraded" || s.Code != "DB_CHECK_FAILED" || s.Reason != "connection refused" {
+		t.Errorf("failing check: got %+v", s)
+	}
+
+	fail = nil
+	health.run(context.Background(), probeReady, nil, nil)
+	if s := subsystemFromCheck("database", "db", "DB", ""); s.State != "ok" || s.Code != "DB_OK" {
+		t.Errorf("passing check: got %+v", s)
+	}
+}
+
+func TestDegradationHandlerReportsPartialMode(t *testing.T) {
+	t.Cleanup(func() { pausedUntil.Store(0) })
+	pausedUntil.Store(time.Now().Add(time.Minute).UnixNano())
+
+	rr := httptest.NewRecorder()
+	degradationHandler(rr, httptest.NewRequest("GET", "/api/degradation?state=degraded", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var rep degradationReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Mode != "partial" {
+		t.Errorf("mode: got %q want partial", rep.Mode)
+	}
+	found := false
+	for _, s := range rep.Subsystems {
+		if s.State != "degraded" {
+			t.Errorf("?state=degraded returned %+v", s)
+		}
+		found = found || s.Code == "TRAFFIC_PAUSED"
+	}
+	if !found {
+		t.Errorf("expected TRAFFIC_PAUSED in %+v", rep.Subsystems)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// `app demo run <scenario.yaml>` drives a scripted demo against a running
+// instance. Environment variables in the file are expanded first, so tokens
+// can stay out of it:
+//
+//	target: https://demo.example.com
+//	adminToken: ${ADMIN_TOKEN}
+//	steps:
+//	  - at: 0s
+//	    name: baseline load
+//	    load: {path: /api/info, rps: 20, duration: 6m}
+//	  - at: 2m
+//	    name: brief outage
+//	    request: {method: POST, path: "/api/admin/pause-traffic?duration=30s", admin: true, expect: 200}
+//	  - at: 4m
+//	    event: shrink the ring
+//	  - at: 4m
+//	    request: {method: PUT, path: /api/shard/ring, body: '{"shards": 3}', admin: true, expect: 200}
+//
+// Each step runs at its offset from the start and reports a JSON line on
+// stdout. Event steps are published on the target's event bus through POST
+// /api/admin/events, so they land in its ledger next to what the demo
+// caused; they need adminToken. Load keeps running in the background while
+// later steps fire, at up to demoMaxRPS; the runner exits once every step
+// and load has finished, non-zero if any request or event step failed.
+type demoScenario struct {
+	Target     string     `yaml:"target"`
+	AdminToken string     `yaml:"adminToken"`
+	Steps      []demoStep `yaml:"steps"`
+}
+
+type demoStep struct {
+	At      time.Duration `yaml:"at"`
+	Name    string        `yaml:"name"`
+	Load    *demoLoad     `yaml:"load"`
+	Request *demoRequest  `yaml:"request"`
+	Event   string        `yaml:"event"`
+}
+
+type demoLoad struct {
+	Path     string        `yaml:"path"`
+	Method   string        `yaml:"method"`
+	RPS      float64       `yaml:"rps"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+type demoRequest struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Body   string `yaml:"body"`
+	Admin  bool   `yaml:"admin"`
+	Expect int    `yaml:"expect"`
+}
+
+// demoMaxRPS caps load steps; one runner can't usefully go faster, and the
+// ticker interval must stay above zero.
+const demoMaxRPS = 1000
+
+// demoReport is one line of runner output.
+type demoReport struct {
+	Offset string         `json:"offset"`
+	Step   string         `json:"step"`
+	Kind   string         `json:"kind"`
+	OK     bool           `json:"ok"`
+	Detail map[string]any `json:"detail,omitempty"`
+}
+
+func runDemo(args []string, out io.Writer) error {
+	if len(args) != 2 || args[0] != "run" {
+		return errors.New("usage: app demo run <scenario.yaml>")
+	}
+	sc, err := loadDemoScenario(args[1])
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return (&demoRunner{scenario: sc, client: &http.Client{Timeout: 10 * time.Second}, out: out}).run(ctx)
+}
+
+func loadDemoScenario(path string) (*demoScenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc demoScenario
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(b))), &sc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &sc, sc.validate()
+}
+
+func (sc *demoScenario) validate() error {
+	if sc.Target == "" {
+		return errors.New("scenario needs a target URL")
+	}
+	sc.Target = strings.TrimSuffix(sc.Target, "/")
+	for i, s := range sc.Steps {
+		actions := 0
+		if s.Load != nil {
+			actions++
+			if s.Load.RPS <= 0 || s.Load.Duration <= 0 || s.Load.Path == "" {
+				return fmt.Errorf("step %d: load needs path, rps > 0 and duration > 0", i+1)
+			}
+			if s.Load.RPS > demoMaxRPS {
+				return fmt.Errorf("step %d: load rps may not exceed %d", i+1, demoMaxRPS)
+			}
+		}
+		if s.Request != nil {
+			actions++
+			if s.Request.Path == "" {
+				return fmt.Errorf("step %d: request needs a path", i+1)
+			}
+		}
+		if s.Event != "" {
+			actions++
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %d: exactly one of load, request or event is required", i+1)
+		}
+		if s.At < 0 {
+			return fmt.Errorf("step %d: at must not be negative", i+1)
+		}
+	}
+	sort.SliceStable(sc.Steps, func(i, j int) bool { return sc.Steps[i].At < sc.Steps[j].At })
+	return nil
+}
+
+type demoRunner struct {
+	scenario *demoScenario
+	client   *http.Client
+	out      io.Writer
+
+	start  time.Time
+	mu     sync.Mutex
+	failed int
+}
+
+func (d *demoRunner) run(ctx context.Context) error {
+	d.start = time.Now()
+	var loads sync.WaitGroup
+	for i, s := range d.scenario.Steps {
+		if s.Name == "" {
+			s.Name = "step " + strconv.Itoa(i+1)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(d.start.Add(s.At))):
+		}
+		switch {
+		case s.Load != nil:
+			loads.Add(1)
+			go func() {
+				defer loads.Done()
+				d.runLoad(ctx, s.Name, *s.Load)
+			}()
+		case s.Request != nil:
+			d.runRequest(ctx, s.Name, *s.Request)
+		default:
+			d.runEvent(ctx, s.Name, s.Event)
+		}
+	}
+	loads.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failed > 0 {
+		return fmt.Errorf("%d step(s) failed", d.failed)
+	}
+	return nil
+}
+
+func (d *demoRunner) report(step, kind string, ok bool, detail map[string]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !ok {
+		d.failed++
+	}
+	result := "ok"
+	if !ok {
+		result = "failed"
+	}
+	demoSteps.WithLabelValues(kind, result).Inc()
+	line, _ := json.Marshal(demoReport{
+		Offset: time.Since(d.start).Round(time.Millisecond).String(),
+		Step:   step,
+		Kind:   kind,
+		OK:     ok,
+		Detail: detail,
+	})
+	fmt.Fprintln(d.out, string(line))
+}
+
+func (d *demoRunner) newRequest(ctx context.Context, method, path, body string, admin bool) (*http.Request, error) {
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), d.scenario.Target+path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if admin && d.scenario.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+d.scenario.AdminToken)
+	}
+	return req, nil
+}
+
+func (d *demoRunner) runRequest(ctx context.Context, name string, r demoRequest) {
+	detail := map[string]any{"method": strings.ToUpper(r.Method), "path": r.Path}
+	req, err := d.newRequest(ctx, r.Method, r.Path, r.Body, r.Admin)
+	if err != nil {
+		detail["error"] = err.Error()
+		d.report(name, "request", false, detail)
+		return
+	}
+	detail["method"] = req.Method
+	resp, err := d.client.Do(req)
+	if err != nil {
+		detail["error"] = err.Error()
+		d.report(name, "request", false, detail)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+	detail["status"] = resp.StatusCode
+	ok := resp.StatusCode < 400
+	if r.Expect != 0 {
+		ok = resp.StatusCode == r.Expect
+		detail["expect"] = r.Expect
+	}
+	if !ok {
+		detail["body"] = strings.TrimSpace(string(body))
+	}
+	d.report(name, "request", ok, detail)
+}
+
+// runEvent publishes message on the target's event bus.
+func (d *demoRunner) runEvent(ctx context.Context, name, message string) {
+	detail := map[string]any{"message": message}
+	body, _ := json.Marshal(map[string]string{"subject": name, "message": message})
+	req, err := d.newRequest(ctx, http.MethodPost, "/api/admin/events", string(body), true)
+	if err != nil {
+		detail["error"] = err.Error()
+		d.report(name, "event", false, detail)
+		return
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		detail["error"] = err.Error()
+		d.report(name, "event", false, detail)
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	detail["status"] = resp.StatusCode
+	d.report(name, "event", resp.StatusCode < 300, detail)
+}
+
+// runLoad fires requests at a fixed rate for the configured duration and
+// reports a status-code histogram at the end. Load steps never fail the
+// scenario; errors are what a demo usually wants to show.
+func (d *demoRunner) runLoad(ctx context.Context, name string, l demoLoad) {
+	d.report(name, "load-start", true, map[string]any{"path": l.Path, "rps": l.RPS, "duration": l.Duration.String()})
+	ctx, cancel := context.WithTimeout(ctx, l.Duration)
+	defer cancel()
+
+	var mu sync.Mutex
+	statuses := map[string]int{}
+	var inflight sync.WaitGroup
+	t := time.NewTicker(time.Duration(float64(time.Second) / l.RPS))
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			inflight.Wait()
+			d.report(name, "load-done", true, map[string]any{"path": l.Path, "statuses": statuses})
+			return
+		case <-t.C:
+		}
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			key := "error"
+			if req, err := d.newRequest(ctx, l.Method, l.Path, "", false); err == nil {
+				if resp, err := d.client.Do(req); err == nil {
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					key = strconv.Itoa(resp.StatusCode)
+				} else if ctx.Err() != nil {
+					return // cut off by the end of the load window
+				}
+			}
+			demoLoadRequests.WithLabelValues(key).Inc()
+			mu.Lock()
+			statuses[key]++
+			mu.Unlock()
+		}()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadDemoScenario(t *testing.T) {
+	t.Setenv("DEMO_TOKEN", "s3cret")
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	body := `
+target: http://localhost:8080/
+adminToken: ${DEMO_TOKEN}
+steps:
+  - at: 2m
+    request: {method: POST, path: "/api/admin/pause-traffic?duration=30s", admin: true, expect: 200}
+  - at: 0s
+    load: {path: /api/info, rps: 5, duration: 1m}
+`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := loadDemoScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Target != "http://localhost:8080" || sc.AdminToken != "s3cret" {
+		t.Errorf("unexpected scenario header: %+v", sc)
+	}
+	if sc.Steps[0].Load == nil || sc.Steps[1].At != 2*time.Minute {
+		t.Errorf("steps not sorted by offset: %+v", sc.Steps)
+	}
+}
+
+func TestDemoScenarioValidation(t *testing.T) {
+	bad := []demoScenario{
+		{},
+		{Target: "http://x", Steps: []demoStep{{Event: "a", Request: &demoRequest{Path: "/"}}}},
+		{Target: "http://x", Steps: []demoStep{{Load: &demoLoad{Path: "/", RPS: 0, Duration: time.Second}}}},
+		{Target: "http://x", Steps: []demoStep{{}}},
+		{Target: "http://x", Steps: []demoStep{{Load: &demoLoad{Path: "/", RPS: 2e9, Duration: time.Second}}}},
+	}
+	for i, sc := range bad {
+		if err := sc.validate(); err == nil {
+			t.Errorf("scenario %d: expected a validation error", i)
+		}
+	}
+}
+
+func TestDemoRunnerRunsSteps(t *testing.T) {
+	var hits atomic.Int64
+	var published atomic.Value
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		case "/api/admin/events":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var e struct{ Subject, Message string }
+			_ = json.NewDecoder(r.Body).Decode(&e)
+			published.Store(e.Subject + ": " + e.Message)
+			w.WriteHeader(http.StatusCreated)
+		case "/load":
+			hits.Add(1)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	sc := &demoScenario{Target: target.URL, AdminToken: "tok", Steps: []demoStep{
+		{Name: "load", Load: &demoLoad{Path: "/load", RPS: 50, Duration: 200 * time.Millisecond}},
+		{Name: "admin", At: 50 * time.Millisecond, Request: &demoRequest{Method: "post", Path: "/admin", Admin: true, Expect: 200}},
+		{Name: "note", At: 60 * time.Millisecond, Event: "hello"},
+		{Name: "missing", At: 70 * time.Millisecond, Request: &demoRequest{Path: "/nope"}},
+	}}
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err := (&demoRunner{scenario: sc, client: target.Client(), out: &out}).run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "1 step(s) failed") {
+		t.Errorf("expected exactly the missing step to fail, got %v", err)
+	}
+	if hits.Load() < 5 {
+		t.Errorf("load step only sent %d requests", hits.Load())
+	}
+	if got := published.Load(); got != "note: hello" {
+		t.Errorf("published event = %v, want note: hello", got)
+	}
+
+	var kinds []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var r demoReport
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid report line %q: %v", line, err)
+		}
+		kinds = append(kinds, r.Step+":"+r.Kind)
+	}
+	want := "load:load-start admin:request note:event missing:request load:load-done"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("got reports %q want %q", got, want)
+	}
+}
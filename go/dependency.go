@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registeredDependencies lists the dependencies added by
+// registerDependencies.
+var registeredDependencies []*dependency
+
+// outboundClient is used for every call the app makes to other services.
+// It records a client span and injects the trace context (see tracing.go),
+// and forwards the journey and the request and correlation IDs of the
+// request being served.
+var outboundClient = &http.Client{Transport: newTracedTransport(
+	correlationTransport{base: journeyTransport{base: http.DefaultTransport}},
+)}
+
+// dependency is a downstream HTTP service that readiness depends on.
+// DEPENDENCY_URLS lists them comma-separated as [name=]url[|timeout], e.g.
+//
+//	DEPENDENCY_URLS=payments=http://payments/healthz|500ms,http://inventory/readyz
+//
+// Entries without a name use the URL host; entries without a timeout use
+// DEPENDENCY_TIMEOUT (default 2s). Readiness output only ever shows the URL
+// with its userinfo and query values redacted.
+type dependency struct {
+	name     string
+	url      string
+	redacted string
+	timeout  time.Duration
+
+	mu                  sync.Mutex
+	status              string
+	latency             time.Duration
+	consecutiveFailures int
+	lastError           string
+	lastChecked         time.Time
+}
+
+type dependencyStatus struct {
+	URL                 string `json:"url"`
+	Status              string `json:"status"`
+	LatencyMs           int64  `json:"latencyMs"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+	LastChecked         string `json:"lastChecked,omitempty"`
+}
+
+func parseDependencies(spec string, defTimeout time.Duration) ([]*dependency, error) {
+	var deps []*dependency
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		d := &dependency{timeout: defTimeout, status: "unknown"}
+		if raw, t, ok := strings.Cut(entry, "|"); ok {
+			timeout, err := time.ParseDuration(t)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("dependency %q: invalid timeout %q", entry, t)
+			}
+			entry, d.timeout = raw, timeout
+		}
+		if name, u, ok := strings.Cut(entry, "="); ok && !strings.Contains(name, "/") {
+			d.name, entry = name, u
+		}
+		parsed, err := url.Parse(entry)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("dependency %q: need an absolute http(s) URL", entry)
+		}
+		d.url, d.redacted = entry, redactURL(entry)
+		if d.name == "" {
+			d.name = parsed.Host
+		}
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+// registerDependencies adds one readiness check per configured dependency.
+func registerDependencies(spec string, defTimeout time.Duration) error {
+	deps, err := parseDependencies(spec, defTimeout)
+	if err != nil {
+		return err
+	}
+	for _, d := range deps {
+		health.registerDetailed(d.name, probeReady, d.check, d.details)
+		dependencyUp.WithLabelValues(d.name).Set(0)
+	}
+	registeredDependencies = append(registeredDependencies, deps...)
+	return nil
+}
+
+// check GETs the dependency within its timeout; any 2xx/3xx is healthy.
+func (d *dependency) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := outboundClient.Do(req)
+		if err != nil {
+			if uerr := (*url.Error)(nil); errors.As(err, &uerr) {
+				uerr.URL = d.redacted
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}()
+	latency := time.Since(start)
+	d.record(latency, err)
+	trackDependencyCall(ctx, d.name, latency)
+	return err
+}
+
+func (d *dependency) record(latency time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency, d.lastChecked = latency, time.Now()
+	if err != nil {
+		d.status, d.lastError = "down", err.Error()
+		d.consecutiveFailures++
+	} else {
+		d.status, d.lastError = "up", ""
+		d.consecutiveFailures = 0
+	}
+
+	up := 0.0
+	if err == nil {
+		up = 1
+	}
+	dependencyUp.WithLabelValues(d.name).Set(up)
+	dependencyLatency.WithLabelValues(d.name).Set(latency.Seconds())
+	dependencyFailures.WithLabelValues(d.name).Set(float64(d.consecutiveFailures))
+}
+
+func (d *dependency) details() any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := dependencyStatus{
+		URL:                 d.redacted,
+		Status:              d.status,
+		LatencyMs:           d.latency.Milliseconds(),
+		ConsecutiveFailures: d.consecutiveFailures,
+		LastError:           d.lastError,
+	}
+	if !d.lastChecked.IsZero() {
+		st.LastChecked = d.lastChecked.UTC().Format(time.RFC3339)
+	}
+	return st
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Optional disk space check. When DISK_CHECK_PATH is set, a "disk" readiness
+// check compares free space on that filesystem against DISK_MIN_FREE_MB
+// (default 100). DISK_CHECK_MODE=degrade reports low space as a warning
+// instead of failing readiness.
+type diskCheck struct {
+	path     string
+	minFree  uint64
+	degrade  bool
+	statfsFn func(path string) (free, total uint64, err error)
+
+	mu          sync.Mutex
+	free, total uint64
+}
+
+type diskStatus struct {
+	Path         string `json:"path"`
+	FreeBytes    uint64 `json:"freeBytes"`
+	TotalBytes   uint64 `json:"totalBytes"`
+	MinFreeBytes uint64 `json:"minFreeBytes"`
+}
+
+func registerDiskCheck(path string) error {
+	mode := strings.ToLower(getenv("DISK_CHECK_MODE", "fail"))
+	if mode != "fail" && mode != "degrade" {
+		return fmt.Errorf("%q is not fail or degrade", mode)
+	}
+	dc := &diskCheck{
+		path:     path,
+		minFree:  uint64(max(getenvInt("DISK_MIN_FREE_MB", 100), 0)) << 20,
+		degrade:  mode == "degrade",
+		statfsFn: diskSpace,
+	}
+	health.registerDetailed("disk", probeReady, dc.check, dc.details)
+	return nil
+}
+
+func (dc *diskCheck) check(context.Context) error {
+	free, total, err := dc.statfsFn(dc.path)
+	if err != nil {
+		return err
+	}
+	dc.mu.Lock()
+	dc.free, dc.total = free, total
+	dc.mu.Unlock()
+	diskFreeBytes.WithLabelValues(dc.path).Set(float64(free))
+
+	if free < dc.minFree {
+		err := fmt.Errorf("%d MiB free on %s, below %d MiB", free>>20, dc.path, dc.minFree>>20)
+		if dc.degrade {
+			return degraded(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (dc *diskCheck) details() any {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return diskStatus{Path: dc.path, FreeBytes: dc.free, TotalBytes: dc.total, MinFreeBytes: dc.minFree}
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errors.New("disk space check is not supported on this platform")
+}
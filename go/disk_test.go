@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestDiskCheckThreshold(t *testing.T) {
+	dc := &diskCheck{path: "/data", minFree: 100 << 20}
+	dc.statfsFn = func(string) (uint64, uint64, error) { return 50 << 20, 1 << 30, nil }
+
+	err := dc.check(context.Background())
+	if err == nil {
+		t.Fatal("expected low disk space to fail the check")
+	}
+	if errors.As(err, new(degradedError)) {
+		t.Error("fail mode returned a degraded error")
+	}
+
+	dc.degrade = true
+	if err := dc.check(context.Background()); !errors.As(err, new(degradedError)) {
+		t.Errorf("degrade mode: got %v, want a degraded error", err)
+	}
+
+	dc.statfsFn = func(string) (uint64, uint64, error) { return 200 << 20, 1 << 30, nil }
+	if err := dc.check(context.Background()); err != nil {
+		t.Errorf("unexpected error with enough free space: %v", err)
+	}
+	if st := dc.details().(diskStatus); st.FreeBytes != 200<<20 || st.TotalBytes != 1<<30 {
+		t.Errorf("details not updated: %+v", st)
+	}
+}
+
+func TestDiskCheckDegradedKeepsReadiness(t *testing.T) {
+	withTestHealth(t)
+	t.Setenv("DISK_CHECK_MODE", "degrade")
+	t.Setenv("DISK_MIN_FREE_MB", "1000000000")
+	if err := registerDiskCheck(os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := diskSpace(os.TempDir()); err != nil {
+		t.Skipf("disk space unavailable: %v", err)
+	}
+
+	code, resp := probe(t, probeHandler(probeReady, "ready"), "/readyz")
+	if code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	if len(resp.Warnings) != 1 {
+		t.Errorf("expected a degraded warning, got %v", resp.Warnings)
+	}
+}
+
+func TestRegisterDiskCheckRejectsBadMode(t *testing.T) {
+	withTestHealth(t)
+	t.Setenv("DISK_CHECK_MODE", "panic")
+	if err := registerDiskCheck(os.TempDir()); err == nil {
+		t.Error("expected an invalid DISK_CHECK_MODE to be rejected")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Optional DNS check. With DNS_CHECK_HOST set, a "dns" readiness check
+// resolves that name within DNS_CHECK_TIMEOUT (default 2s) and records the
+// lookup time in dns_lookup_duration_seconds, which makes this app a handy
+// canary for cluster DNS problems.
+type dnsCheck struct {
+	host     string
+	timeout  time.Duration
+	resolver *net.Resolver
+
+	mu        sync.Mutex
+	addrs     []string
+	latency   time.Duration
+	lastError string
+}
+
+type dnsStatus struct {
+	Host      string   `json:"host"`
+	Addresses []string `json:"addresses,omitempty"`
+	LatencyMs int64    `json:"latencyMs"`
+	LastError string   `json:"lastError,omitempty"`
+}
+
+func registerDNSCheck(host string) {
+	dc := &dnsCheck{
+		host:     host,
+		timeout:  getenvDuration("DNS_CHECK_TIMEOUT", 2*time.Second),
+		resolver: net.DefaultResolver,
+	}
+	health.registerDetailed("dns", probeReady, dc.check, dc.details)
+}
+
+func (dc *dnsCheck) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := dc.resolver.LookupHost(ctx, dc.host)
+	latency := time.Since(start)
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no addresses for %s", dc.host)
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	dnsLookupDuration.WithLabelValues(result).Observe(latency.Seconds())
+	trackDependencyCall(ctx, "dns", latency)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.addrs, dc.latency, dc.lastError = addrs, latency, ""
+	if err != nil {
+		dc.lastError = err.Error()
+	}
+	return err
+}
+
+func (dc *dnsCheck) details() any {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dnsStatus{Host: dc.host, Addresses: dc.addrs, LatencyMs: dc.latency.Milliseconds(), LastError: dc.lastError}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDNSCheck(t *testing.T) {
+	dc := &dnsCheck{host: "localhost", timeout: time.Second, resolver: net.DefaultResolver}
+	if err := dc.check(context.Background()); err != nil {
+		t.Fatalf("check returned error: %v", err)
+	}
+	if st := dc.details().(dnsStatus); len(st.Addresses) == 0 {
+		t.Errorf("expected resolved addresses in details, got %+v", st)
+	}
+
+	dc = &dnsCheck{host: "does-not-exist.invalid", timeout: time.Second, resolver: net.DefaultResolver}
+	if err := dc.check(context.Background()); err == nil {
+		t.Error("expected lookup of a .invalid name to fail")
+	}
+}
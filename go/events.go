@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// The event bus carries cross-feature notifications inside the process.
+// Features publish what happened (a health transition, a traffic pause, a
+// self-heal action, ...) without knowing who cares; the ledger and metrics
+// subscribe instead of being called from each feature directly.
+//
+// Delivery is synchronous on the publisher's goroutine, so subscribers must
+// be quick and must not block; anything slow should hand off to its own
+// goroutine.
+type eventType string
+
+const (
+	eventHealthTransition eventType = "health-transition"
+	eventTrafficPause     eventType = "traffic-pause"
+	eventSelfHeal         eventType = "self-heal"
+	eventShardRebalance   eventType = "shard-rebalance"
+	eventAnnotation       eventType = "annotation"
+)
+
+type event struct {
+	Type    eventType
+	Time    time.Time
+	Subject string
+	Message string
+	Data    any
+}
+
+type eventSubscription struct {
+	id    int
+	types []eventType // empty means every type
+	fn    func(event)
+}
+
+type eventBus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []eventSubscription
+}
+
+var events = &eventBus{}
+
+func init() {
+	events.subscribe(func(e event) {
+		ledger.record(string(e.Type), e.Subject, e.Message, e.Data)
+	})
+	events.subscribe(func(e event) {
+		eventsPublished.WithLabelValues(string(e.Type)).Inc()
+	})
+}
+
+// subscribe registers fn for events of the given types (all types when none
+// are given) and returns a function that removes the subscription.
+func (b *eventBus) subscribe(fn func(event), types ...eventType) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, eventSubscription{id: id, types: types, fn: fn})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = slices.DeleteFunc(b.subs, func(s eventSubscription) bool { return s.id == id })
+	}
+}
+
+func (b *eventBus) publish(e event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mu.RLock()
+	subs := slices.Clone(b.subs)
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if len(s.types) == 0 || slices.Contains(s.types, e.Type) {
+			s.fn(e)
+		}
+	}
+}
+
+// annotationHandler serves POST /api/admin/events, which publishes
+// {"message": ..., "subject": ...} as an annotation so tools outside the
+// process, such as the demo runner, can mark moments on the ledger.
+func annotationHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		Subject string `json:"subject"`
+		Message string `json:"message"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	e := event{Type: eventAnnotation, Subject: req.Subject, Message: req.Message, Data: map[string]any{"by": adminPrincipal(r.Context())}}
+	events.publish(e)
+	writeJSON(w, http.StatusCreated, map[string]string{"type": string(e.Type), "subject": e.Subject, "message": e.Message})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEventBusFiltersByType(t *testing.T) {
+	b := &eventBus{}
+	var all, pauses []eventType
+	b.subscribe(func(e event) { all = append(all, e.Type) })
+	unsubscribe := b.subscribe(func(e event) { pauses = append(pauses, e.Type) }, eventTrafficPause)
+
+	b.publish(event{Type: eventTrafficPause})
+	b.publish(event{Type: eventSelfHeal})
+	unsubscribe()
+	b.publish(event{Type: eventTrafficPause})
+
+	if len(all) != 3 {
+		t.Errorf("catch-all subscriber saw %v", all)
+	}
+	if len(pauses) != 1 || pauses[0] != eventTrafficPause {
+		t.Errorf("filtered subscriber saw %v", pauses)
+	}
+}
+
+func TestEventsReachLedgerAndMetrics(t *testing.T) {
+	withTestLedger(t, 10)
+	before := testutil.ToFloat64(eventsPublished.WithLabelValues(string(eventShardRebalance)))
+
+	events.publish(event{Type: eventShardRebalance, Message: "resized"})
+
+	got := ledger.list(string(eventShardRebalance), 0)
+	if len(got) != 1 || got[0].Message != "resized" {
+		t.Errorf("expected the event in the ledger, got %+v", got)
+	}
+	if after := testutil.ToFloat64(eventsPublished.WithLabelValues(string(eventShardRebalance))); after != before+1 {
+		t.Errorf("events_published_total grew by %v, want 1", after-before)
+	}
+}
+
+func TestHealthTransitionsArePublished(t *testing.T) {
+	var got []event
+	unsubscribe := events.subscribe(func(e event) { got = append(got, e) }, eventHealthTransition)
+	defer unsubscribe()
+
+	h := newHealthHistory(10, 0, 0)
+	h.observe("check:db", "ok", nil)
+	h.observe("check:db", "failed", []string{"refused"})
+
+	if len(got) != 1 || got[0].Subject != "check:db" || got[0].Message != "check:db ok -> failed (refused)" {
+		t.Errorf("unexpected events %+v", got)
+	}
+}
+
+func TestAnnotationHandler(t *testing.T) {
+	withTestLedger(t, 10)
+	rr := httptest.NewRecorder()
+	annotationHandler(rr, httptest.NewRequest("POST", "/api/admin/events", strings.NewReader(`{"subject":"demo","message":"ring shrinks now"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	if got := ledger.list(string(eventAnnotation), 0); len(got) != 1 || got[0].Message != "ring shrinks now" {
+		t.Errorf("ledger annotations = %+v", got)
+	}
+	rr = httptest.NewRecorder()
+	annotationHandler(rr, httptest.NewRequest("POST", "/api/admin/events", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("empty message: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
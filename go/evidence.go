@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// GET /api/evidence?window=15m bundles what a pipeline needs to attach to a
+// deployment record: the SLO report for the window, a summary of the
+// app's metrics, the ledger entries from the window and a fingerprint of
+// the effective configuration. ?format=tar returns the same documents as a
+// gzipped tarball instead of one JSON object; both are sent as downloads.
+type evidenceBundle struct {
+	GeneratedAt time.Time                `json:"generatedAt"`
+	Window      string                   `json:"window"`
+	App         evidenceApp              `json:"app"`
+	SLO         sloReport                `json:"slo"`
+	Metrics     map[string]metricSummary `json:"metrics"`
+	Ledger      []ledgerEntry            `json:"ledger"`
+	Config      configFingerprint        `json:"config"`
+}
+
+type evidenceApp struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Replica string `json:"replica"`
+}
+
+// metricSummary collapses a metric family to one number per type: the sum
+// over all series for counters and gauges, and total count and sum for
+// histograms and summaries.
+type metricSummary struct {
+	Type   string   `json:"type"`
+	Series int      `json:"series"`
+	Value  *float64 `json:"value,omitempty"`
+	Count  *uint64  `json:"count,omitempty"`
+	Sum    *float64 `json:"sum,omitempty"`
+}
+
+// configFingerprint identifies the configuration without revealing it:
+// values only contribute to the hash.
+type configFingerprint struct {
+	SHA256 string   `json:"sha256"`
+	Set    []string `json:"set"`
+	Unset  []string `json:"unset"`
+}
+
+const evidenceMaxWindow = sloRetention
+
+func evidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	window := 15 * time.Minute
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > evidenceMaxWindow {
+			writeError(w, http.StatusBadRequest, "window must be a positive duration up to "+evidenceMaxWindow.String())
+			return
+		}
+		window = d
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "tar" {
+		writeError(w, http.StatusBadRequest, "format must be json or tar")
+		return
+	}
+
+	b, err := collectEvidence(window)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "gather metrics: "+err.Error())
+		return
+	}
+	name := "evidence-" + b.GeneratedAt.Format("20060102T150405Z")
+	if format == "tar" {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.tar.gz"`)
+		if err := writeEvidenceTar(w, b); err != nil {
+			loggerFrom(r.Context()).Warn("writing evidence tarball failed", "err", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func collectEvidence(window time.Duration) (*evidenceBundle, error) {
+	now := time.Now().UTC()
+	metrics, err := summarizeMetrics(metricsRegistry)
+	if err != nil {
+		return nil, err
+	}
+	entries := []ledgerEntry{}
+	for _, e := range ledger.list("", 0) {
+		if e.Time.After(now.Add(-window)) {
+			entries = append(entries, e)
+		}
+	}
+	return &evidenceBundle{
+		GeneratedAt: now,
+		Window:      window.String(),
+		App:         evidenceApp{Name: appName, Version: version, Commit: commit, Replica: replica.ID},
+		SLO:         slo.report(window),
+		Metrics:     metrics,
+		Ledger:      entries,
+		Config:      currentConfigFingerprint(),
+	}, nil
+}
+
+func summarizeMetrics(g prometheus.Gatherer) (map[string]metricSummary, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]metricSummary, len(families))
+	for _, f := range families {
+		s := metricSummary{Type: f.GetType().String(), Series: len(f.GetMetric())}
+		var value, sum float64
+		var count uint64
+		for _, m := range f.GetMetric() {
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				value += m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value += m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value += m.GetUntyped().GetValue()
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				count += m.GetHistogram().GetSampleCount()
+				sum += m.GetHistogram().GetSampleSum()
+			case dto.MetricType_SUMMARY:
+				count += m.GetSummary().GetSampleCount()
+				sum += m.GetSummary().GetSampleSum()
+			}
+		}
+		switch f.GetType() {
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM, dto.MetricType_SUMMARY:
+			s.Count, s.Sum = &count, &sum
+		default:
+			s.Value = &value
+		}
+		out[f.GetName()] = s
+	}
+	return out, nil
+}
+
+// currentConfigFingerprint hashes every environment variable the app has
+// read, name and value, in name order. Secret values are left out of the
+// hash, since a fingerprint of a short password is as good as the password;
+// only whether they are set counts.
+func currentConfigFingerprint() configFingerprint {
+	var keys []string
+	configKeys.Range(func(k, _ any) bool {
+		keys = append(keys, k.(string))
+		return true
+	})
+	sort.Strings(keys)
+
+	fp := configFingerprint{Set: []string{}, Unset: []string{}}
+	h := sha256.New()
+	for _, k := range keys {
+		v, ok := os.LookupEnv(k)
+		if ok {
+			fp.Set = append(fp.Set, k)
+		} else {
+			fp.Unset = append(fp.Unset, k)
+		}
+		if isSecretConfigKey(k) && v != "" {
+			v = "[REDACTED]"
+		}
+		h.Write([]byte(k + "=" + v + "\n"))
+	}
+	fp.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return fp
+}
+
+// writeEvidenceTar writes b as a gzipped tarball with one JSON file per
+// section plus manifest.json for the rest.
+func writeEvidenceTar(w io.Writer, b *evidenceBundle) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"manifest.json", map[string]any{"generatedAt": b.GeneratedAt, "window": b.Window, "app": b.App}},
+		{"slo.json", b.SLO},
+		{"metrics.json", b.Metrics},
+		{"ledger.json", b.Ledger},
+		{"config.json", b.Config},
+	}
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return err
+		}
+		hdr := &tar.Header{Name: "evidence/" + f.name, Mode: 0o644, Size: int64(len(data)), ModTime: b.GeneratedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEvidenceJSON(t *testing.T) {
+	withTestLedger(t, 10)
+	ledger.record("test", "deploy", "rolled out", nil)
+	t.Setenv("EVIDENCE_TEST_SECRET", "hunter2")
+	getenv("EVIDENCE_TEST_SECRET", "")
+
+	rr := httptest.NewRecorder()
+	evidenceHandler(rr, httptest.NewRequest("GET", "/api/evidence?window=5m", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rr.Code, rr.Body)
+	}
+	var b evidenceBundle
+	if err := json.Unmarshal(rr.Body.Bytes(), &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Window != "5m0s" || len(b.Ledger) != 1 || b.Ledger[0].Subject != "deploy" {
+		t.Errorf("window %q ledger %+v", b.Window, b.Ledger)
+	}
+	if _, ok := b.Metrics["app_build_info"]; !ok {
+		t.Error("metrics summary is missing app_build_info")
+	}
+	if len(b.Config.SHA256) != 64 {
+		t.Errorf("config fingerprint = %q", b.Config.SHA256)
+	}
+	found := false
+	for _, k := range b.Config.Set {
+		found = found || k == "EVIDENCE_TEST_SECRET"
+	}
+	if !found {
+		t.Errorf("EVIDENCE_TEST_SECRET missing from set keys %v", b.Config.Set)
+	}
+	if strings.Contains(rr.Body.String(), "hunter2") {
+		t.Error("config values must not appear in the evidence")
+	}
+}
+
+func TestEvidenceFingerprintTracksValues(t *testing.T) {
+	t.Setenv("EVIDENCE_TEST_VALUE", "a")
+	getenv("EVIDENCE_TEST_VALUE", "")
+	before := currentConfigFingerprint().SHA256
+	t.Setenv("EVIDENCE_TEST_VALUE", "b")
+	if after := currentConfigFingerprint().SHA256; after == before {
+		t.Error("fingerprint did not change with a config value")
+	}
+}
+
+func TestEvidenceFingerprintIgnoresSecretValues(t *testing.T) {
+	t.Setenv("EVIDENCE_TEST_PASSWORD", "a")
+	getenv("EVIDENCE_TEST_PASSWORD", "")
+	before := currentConfigFingerprint().SHA256
+	t.Setenv("EVIDENCE_TEST_PASSWORD", "b")
+	if after := currentConfigFingerprint().SHA256; after != before {
+		t.Error("fingerprint depends on a secret value")
+	}
+}
+
+func TestEvidenceTar(t *testing.T) {
+	rr := httptest.NewRecorder()
+	evidenceHandler(rr, httptest.NewRequest("GET", "/api/evidence?format=tar", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("status %d content type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	want := []string{"evidence/manifest.json", "evidence/slo.json", "evidence/metrics.json", "evidence/ledger.json", "evidence/config.json"}
+	if len(names) != len(want) {
+		t.Fatalf("tarball holds %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("entry %d = %s, want %s", i, names[i], want[i])
+		}
+	}
+}
+
+func TestEvidenceRejectsBadWindow(t *testing.T) {
+	for _, q := range []string{"window=-1m", "window=48h", "window=soon", "format=zip"} {
+		rr := httptest.NewRecorder()
+		evidenceHandler(rr, httptest.NewRequest("GET", "/api/evidence?"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HEAD support. Every route serving GET answers HEAD (load balancers often
+// probe with it) by running the GET handler with a writer that discards
+// the body but counts it, so the response carries the same status and
+// headers as the GET would, Content-Type and Content-Length included,
+// whatever the body's size. net/http alone only sets Content-Length for
+// bodies that fit its buffer.
+func withHead() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			hw := &headWriter{ResponseWriter: w}
+			defer hw.finish()
+			next.ServeHTTP(hw, r)
+		})
+	}
+}
+
+// headWriter holds back the status line until the handler returns, so it
+// can add the Content-Length the body would have had.
+type headWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	sent   bool
+}
+
+func (hw *headWriter) WriteHeader(code int) {
+	if code < 200 {
+		hw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if hw.status == 0 {
+		hw.status = code
+	}
+}
+
+func (hw *headWriter) Write(b []byte) (int, error) {
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	if hw.bytes == 0 && hw.Header().Get("Content-Type") == "" && len(b) > 0 {
+		hw.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	hw.bytes += int64(len(b))
+	return len(b), nil
+}
+
+// Flush sends the headers as they stand, for streaming handlers; the
+// length is unknown then.
+func (hw *headWriter) Flush() {
+	hw.send(false)
+	if f, ok := hw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (hw *headWriter) finish() {
+	hw.send(true)
+}
+
+func (hw *headWriter) send(done bool) {
+	if hw.sent {
+		return
+	}
+	hw.sent = true
+	if hw.status == 0 {
+		hw.status = http.StatusOK
+	}
+	h := hw.Header()
+	if done && h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" && bodyAllowed(hw.status) {
+		h.Set("Content-Length", strconv.FormatInt(hw.bytes, 10))
+	}
+	hw.ResponseWriter.WriteHeader(hw.status)
+}
+
+func (hw *headWriter) Unwrap() http.ResponseWriter { return hw.ResponseWriter }
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHeadMatchesGetWithoutBody(t *testing.T) {
+	big := strings.Repeat("x", 64<<10)
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		w.Header().Set("X-Route", "big")
+		writeJSON(w, http.StatusOK, map[string]string{"data": big})
+	}), withHead())
+
+	get := httptest.NewRecorder()
+	h.ServeHTTP(get, httptest.NewRequest("GET", "/api/big", nil))
+	head := httptest.NewRecorder()
+	h.ServeHTTP(head, httptest.NewRequest("HEAD", "/api/big", nil))
+
+	if head.Code != http.StatusOK || head.Body.Len() != 0 {
+		t.Errorf("HEAD: status %d, %d body bytes", head.Code, head.Body.Len())
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Errorf("HEAD Content-Length %q, want %q", got, want)
+	}
+	for _, k := range []string{"Content-Type", "X-Route"} {
+		if head.Header().Get(k) != get.Header().Get(k) {
+			t.Errorf("HEAD %s %q, GET has %q", k, head.Header().Get(k), get.Header().Get(k))
+		}
+	}
+}
+
+func TestHeadKeepsStatusAndSniffsType(t *testing.T) {
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			writeError(w, http.StatusServiceUnavailable, "not ready")
+			return
+		}
+		w.Write([]byte("<html><body>home</body></html>"))
+	}), withHead())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("HEAD", "/down", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Body.Len() != 0 {
+		t.Errorf("HEAD /down: status %d, %d body bytes", rr.Code, rr.Body.Len())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("HEAD", "/", nil))
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("HEAD /: Content-Type %q", rr.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Request header anomaly tracking. Header bloat usually arrives with a new
+// proxy or mesh sidecar during a rollout, so every request's header count
+// and size are recorded, and requests above HEADER_ANOMALY_COUNT headers or
+// HEADER_ANOMALY_BYTES bytes are counted and logged as outliers. Setting
+// HEADER_REJECT_COUNT or HEADER_REJECT_BYTES also rejects requests above
+// that limit with 431; both are off by default.
+var (
+	headerAnomalyCount = getenvInt("HEADER_ANOMALY_COUNT", 50)
+	headerAnomalyBytes = getenvInt("HEADER_ANOMALY_BYTES", 8<<10)
+	headerRejectCount  = getenvInt("HEADER_REJECT_COUNT", 0)
+	headerRejectBytes  = getenvInt("HEADER_REJECT_BYTES", 0)
+)
+
+// headerStats returns the number of header fields in h, their approximate
+// size on the wire ("Name: value\r\n" per field) and the largest field's
+// name.
+func headerStats(h http.Header) (count, size int, largest string) {
+	largestSize := 0
+	for name, values := range h {
+		for _, v := range values {
+			n := len(name) + len(v) + 4
+			count++
+			size += n
+			if n > largestSize {
+				largest, largestSize = name, n
+			}
+		}
+	}
+	return count, size, largest
+}
+
+func withHeaderAnomalies() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count, size, largest := headerStats(r.Header)
+			httpRequestHeaderCount.Observe(float64(count))
+			httpRequestHeaderBytes.Observe(float64(size))
+
+			var reasons []string
+			if headerAnomalyCount > 0 && count > headerAnomalyCount {
+				reasons = append(reasons, "count")
+			}
+			if headerAnomalyBytes > 0 && size > headerAnomalyBytes {
+				reasons = append(reasons, "size")
+			}
+			for _, reason := range reasons {
+				httpHeaderAnomalies.WithLabelValues(reason).Inc()
+			}
+			if len(reasons) > 0 {
+				loggerFrom(r.Context()).Warn("request header anomaly", "path", r.URL.Path, "headers", count, "bytes", size, "largest", largest, "via", r.Header.Get("Via"))
+			}
+
+			switch {
+			case headerRejectCount > 0 && count > headerRejectCount:
+				httpHeaderRejections.WithLabelValues("count").Inc()
+				writeError(w, http.StatusRequestHeaderFieldsTooLarge, "too many request headers ("+strconv.Itoa(count)+" > "+strconv.Itoa(headerRejectCount)+")")
+				return
+			case headerRejectBytes > 0 && size > headerRejectBytes:
+				httpHeaderRejections.WithLabelValues("size").Inc()
+				writeError(w, http.StatusRequestHeaderFieldsTooLarge, "request headers too large ("+strconv.Itoa(size)+" > "+strconv.Itoa(headerRejectBytes)+" bytes)")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
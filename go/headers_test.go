@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHeaderStats(t *testing.T) {
+	h := http.Header{}
+	h.Set("Accept", "*/*")
+	h.Add("X-Forwarded-For", "10.0.0.1")
+	h.Add("X-Forwarded-For", "10.0.0.2")
+	h.Set("Cookie", strings.Repeat("c", 100))
+	count, size, largest := headerStats(h)
+	want := (len("Accept") + 3 + 4) + 2*(len("X-Forwarded-For")+8+4) + (len("Cookie") + 100 + 4)
+	if count != 4 || size != want || largest != "Cookie" {
+		t.Errorf("headerStats = %d, %d, %q; want 4, %d, Cookie", count, size, largest, want)
+	}
+}
+
+func TestHeaderAnomaliesFlagAndReject(t *testing.T) {
+	prevCount, prevReject := headerAnomalyCount, headerRejectBytes
+	headerAnomalyCount, headerRejectBytes = 3, 1024
+	t.Cleanup(func() { headerAnomalyCount, headerRejectBytes = prevCount, prevReject })
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withHeaderAnomalies())
+
+	flagged := testutil.ToFloat64(httpHeaderAnomalies.WithLabelValues("count"))
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 5; i++ {
+		req.Header.Set("X-Extra-"+strconv.Itoa(i), "v")
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("anomalous but under the reject limit: status %d", rr.Code)
+	}
+	if got := testutil.ToFloat64(httpHeaderAnomalies.WithLabelValues("count")); got != flagged+1 {
+		t.Errorf("count anomalies grew by %v, want 1", got-flagged)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cookie", strings.Repeat("c", 2048))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("oversized headers: status %d, want 431", rr.Code)
+	}
+}
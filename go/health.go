@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// probeKind selects which probe endpoints a health check contributes to.
+type probeKind uint8
+
+const (
+	probeLive probeKind = 1 << iota
+	probeReady
+
+	probeAll = probeLive | probeReady
+)
+
+// healthCheck is a named check evaluated by the probe endpoints. Names are
+// what operators pass to ?exclude= / ?include=, so keep them short.
+type healthCheck struct {
+	name  string
+	kinds probeKind
+	fn    func(ctx context.Context) error
+	// details, if set, reports extra state for verbose probe output.
+	details func() any
+}
+
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, degraded, failed, excluded
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+// degradedError marks a check failure that should be reported but not fail
+// the probe. Wrap an error with degraded() to return one.
+type degradedError struct{ err error }
+
+func (e degradedError) Error() string { return e.err.Error() }
+func (e degradedError) Unwrap() error { return e.err }
+
+func degraded(err error) error { return degradedError{err} }
+
+type healthRegistry struct {
+	mu     sync.RWMutex
+	checks []healthCheck
+	// observers see every evaluated (not excluded) check result.
+	observers []func(checkResult)
+	// last holds the most recent evaluated result per check.
+	last map[string]checkResult
+}
+
+var (
+	health = &healthRegistry{}
+
+	// forcedUnready is flipped by SIGUSR1 to pull the pod out of Service
+	// endpoints without deleting it.
+	forcedUnready atomic.Bool
+
+	// shuttingDown is set as soon as SIGTERM arrives so readiness fails
+	// while endpoints are being removed, before the server stops accepting.
+	shuttingDown atomic.Bool
+)
+
+func init() {
+	health.observe(recordCheckStatus)
+	health.register("ping", probeAll, func(context.Context) error { return nil })
+	health.register("startup", probeReady, func(context.Context) error {
+		if !startup.started() {
+			return fmt.Errorf("startup pending: %s", strings.Join(startup.pendingTasks(), ", "))
+		}
+		return nil
+	})
+	health.register("shutdown", probeReady, func(context.Context) error {
+		if shuttingDown.Load() {
+			return errors.New("shutting down")
+		}
+		return nil
+	})
+	health.register("manual", probeReady, func(context.Context) error {
+		if forcedUnready.Load() {
+			return errors.New("marked unready by operator signal")
+		}
+		return nil
+	})
+}
+
+// probePaths are polled by kubelet and Prometheus; traffic gates must never
+// block them.
+var probePaths = []string{"/healthz", "/livez", "/readyz", "/startupz", "/health", "/live", "/ready", "/metrics"}
+
+func isProbePath(path string) bool {
+	return slices.Contains(probePaths, path)
+}
+
+// toggleReadiness flips the operator readiness override and returns the new
+// readiness state.
+func toggleReadiness() bool {
+	for {
+		old := forcedUnready.Load()
+		if forcedUnready.CompareAndSwap(old, !old) {
+			return old
+		}
+	}
+}
+
+func (h *healthRegistry) register(name string, kinds probeKind, fn func(ctx context.Context) error) {
+	h.registerDetailed(name, kinds, fn, nil)
+}
+
+func (h *healthRegistry) observe(fn func(checkResult)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.observers = append(h.observers, fn)
+}
+
+// lastResult returns the most recent result of the named check. registered
+// is false if no such check exists; ok is false if it has not run yet.
+func (h *healthRegistry) lastResult(name string) (res checkResult, registered, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	registered = slices.ContainsFunc(h.checks, func(c healthCheck) bool { return c.name == name })
+	res, ok = h.last[name]
+	return res, registered, ok
+}
+
+// lastResults returns the most recent result of every check that has run.
+func (h *healthRegistry) lastResults() map[string]checkResult {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return maps.Clone(h.last)
+}
+
+func (h *healthRegistry) registerDetailed(name string, kinds probeKind, fn func(ctx context.Context) error, details func() any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, healthCheck{name: name, kinds: kinds, fn: fn, details: details})
+}
+
+// Checks run concurrently. Each gets HEALTH_CHECK_TIMEOUT, or its entry in
+// HEALTH_CHECK_TIMEOUTS ("db=500ms,payments=1s"), and the probe as a whole
+// gives up after HEALTH_PROBE_BUDGET. Defaults keep a probe under the 2s
+// kubelet timeout in k8s/deployment.yml. A check that overruns is reported
+// as failed without waiting for it to return.
+var (
+	healthCheckTimeout  = getenvDuration("HEALTH_CHECK_TIMEOUT", 1500*time.Millisecond)
+	healthProbeBudget   = getenvDuration("HEALTH_PROBE_BUDGET", 1800*time.Millisecond)
+	healthCheckTimeouts = mustParseCheckTimeouts(getenv("HEALTH_CHECK_TIMEOUTS", ""))
+)
+
+func mustParseCheckTimeouts(spec string) map[string]time.Duration {
+	t, err := parseBudgets(spec)
+	if err != nil {
+		invalidConfig("HEALTH_CHECK_TIMEOUTS", err)
+	}
+	return t
+}
+
+func checkTimeout(name string) time.Duration {
+	if d, ok := healthCheckTimeouts[name]; ok {
+		return d
+	}
+	return healthCheckTimeout
+}
+
+// run evaluates every check matching kinds. Following kube-apiserver, names
+// listed in exclude are skipped; if include is non-empty only those names
+// run. Selector names that match no check are returned so callers can warn
+// about typos instead of silently ignoring them.
+func (h *healthRegistry) run(ctx context.Context, kinds probeKind, exclude, include []string) (ok bool, results []checkResult, unknown []string) {
+	h.mu.RLock()
+	checks := slices.Clone(h.checks)
+	observers := slices.Clone(h.observers)
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeBudget)
+	defer cancel()
+
+	type pending struct {
+		check   healthCheck
+		ctx     context.Context
+		cancel  context.CancelFunc
+		timeout time.Duration
+		done    chan error
+	}
+	var running []*pending
+	matched := make(map[string]bool)
+	ok = true
+	for _, c := range checks {
+		if c.kinds&kinds == 0 {
+			continue
+		}
+		excluded, included := slices.Contains(exclude, c.name), slices.Contains(include, c.name)
+		if excluded || included {
+			matched[c.name] = true
+		}
+		if excluded || (len(include) > 0 && !included) {
+			results = append(results, checkResult{Name: c.name, Status: "excluded"})
+			running = append(running, nil)
+			continue
+		}
+		p := &pending{check: c, timeout: checkTimeout(c.name), done: make(chan error, 1)}
+		p.ctx, p.cancel = context.WithTimeout(ctx, p.timeout)
+		go func() { p.done <- p.check.fn(p.ctx) }()
+		results = append(results, checkResult{})
+		running = append(running, p)
+	}
+
+	for i, p := range running {
+		if p == nil {
+			continue
+		}
+		var err error
+		select {
+		case err = <-p.done:
+		case <-p.ctx.Done():
+			select {
+			case err = <-p.done:
+			default:
+				if ctx.Err() != nil {
+					err = fmt.Errorf("probe budget of %s exhausted", healthProbeBudget)
+				} else {
+					err = fmt.Errorf("timed out after %s", p.timeout)
+				}
+			}
+		}
+		p.cancel()
+
+		res := checkResult{Name: p.check.name, Status: "ok"}
+		if err != nil {
+			res.Status, res.Error = "failed", err.Error()
+			if errors.As(err, new(degradedError)) {
+				res.Status = "degraded"
+			} else {
+				ok = false
+			}
+		}
+		if p.check.details != nil {
+			res.Details = p.check.details()
+		}
+		for _, fn := range observers {
+			fn(res)
+		}
+		h.mu.Lock()
+		if h.last == nil {
+			h.last = make(map[string]checkResult)
+		}
+		h.last[res.Name] = res
+		h.mu.Unlock()
+		results[i] = res
+	}
+	for _, name := range append(slices.Clone(exclude), include...) {
+		if !matched[name] && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	return ok, results, unknown
+}
+
+type probeResponse struct {
+	Status   string        `json:"status"`
+	Checks   []checkResult `json:"checks,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+// recordCheckStatus exports a check result as app_health_check_status.
+func recordCheckStatus(res checkResult) {
+	appHealthCheckStatus.WithLabelValues(res.Name).Set(checkStatusValue(res.Status))
+}
+
+// checkStatusValue maps a check status to 1 (ok), 0.5 (degraded) or 0.
+func checkStatusValue(status string) float64 {
+	switch status {
+	case "ok":
+		return 1
+	case "degraded":
+		return 0.5
+	}
+	return 0
+}
+
+// probeHandler serves a kube-style probe endpoint. Per-check results are
+// included when ?verbose is set or when the probe fails; degraded checks are
+// always surfaced as warnings.
+func probeHandler(kinds probeKind, okStatus string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		ok, results, unknown := health.run(r.Context(), kinds, q["exclude"], q["include"])
+		if len(q["exclude"]) == 0 && len(q["include"]) == 0 {
+			healthHistory.observeProbe(kinds, ok, results)
+			if kinds == probeReady {
+				ready := 0.0
+				if ok {
+					ready = 1
+				}
+				appReady.Set(ready)
+			}
+		}
+
+		resp := probeResponse{Status: okStatus}
+		code := http.StatusOK
+		if !ok {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		if _, verbose := q["verbose"]; verbose || !ok {
+			resp.Checks = results
+		}
+		for _, res := range results {
+			if res.Status == "degraded" {
+				resp.Warnings = append(resp.Warnings, res.Name+" degraded: "+res.Error)
+			}
+		}
+		for _, name := range unknown {
+			resp.Warnings = append(resp.Warnings, "no health check named "+name)
+		}
+		if prefersPlainText(r) {
+			_, verbose := q["verbose"]
+			writePlainProbe(w, code, resp, verbose)
+			return
+		}
+		writeJSON(w, code, resp)
+	}
+}
+
+// prefersPlainText reports whether the Accept header ranks text/plain above
+// application/json. JSON stays the default, including for */*.
+func prefersPlainText(r *http.Request) bool {
+	var plainQ, jsonQ float64
+	for _, part := range strings.Split(strings.Join(r.Header.Values("Accept"), ","), ",") {
+		media, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(media)) {
+		case "text/plain":
+			plainQ = max(plainQ, q)
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		}
+	}
+	return plainQ > 0 && plainQ > jsonQ
+}
+
+// writePlainProbe renders a probe for load balancers that only understand a
+// bare "ok" body: the body is exactly "ok" or "unavailable". With ?verbose,
+// check lines in the kube-apiserver "[+]name ok" style and warnings come
+// first.
+func writePlainProbe(w http.ResponseWriter, code int, resp probeResponse, verbose bool) {
+	var b strings.Builder
+	if !verbose {
+		resp.Checks, resp.Warnings = nil, nil
+	}
+	for _, c := range resp.Checks {
+		switch c.Status {
+		case "failed":
+			fmt.Fprintf(&b, "[-]%s failed: %s\n", c.Name, c.Error)
+		case "excluded":
+			fmt.Fprintf(&b, "[+]%s excluded: ok\n", c.Name)
+		default:
+			fmt.Fprintf(&b, "[+]%s %s\n", c.Name, c.Status)
+		}
+	}
+	for _, warn := range resp.Warnings {
+		fmt.Fprintf(&b, "warn: %s\n", warn)
+	}
+	if code == http.StatusOK {
+		b.WriteString("ok")
+	} else {
+		b.WriteString("unavailable")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, b.String())
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func withTestHealth(t *testing.T) {
+	t.Helper()
+	prev := health
+	health = &healthRegistry{}
+	t.Cleanup(func() { health = prev })
+}
+
+func probe(t *testing.T, h http.Handler, target string) (int, probeResponse) {
+	t.Helper()
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	var resp probeResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("probe returned invalid JSON %q: %v", rr.Body.String(), err)
+	}
+	return rr.Code, resp
+}
+
+func TestReadyzExclude(t *testing.T) {
+	withTestHealth(t)
+	health.register("ping", probeAll, func(context.Context) error { return nil })
+	health.register("db", probeReady, func(context.Context) error { return errors.New("connection refused") })
+	h := probeHandler(probeReady, "ready")
+
+	if code, _ := probe(t, h, "/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusServiceUnavailable)
+	}
+	code, resp := probe(t, h, "/readyz?exclude=db&verbose")
+	if code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	if len(resp.Checks) != 2 || resp.Checks[1].Status != "excluded" {
+		t.Errorf("expected db to be reported as excluded, got %+v", resp.Checks)
+	}
+}
+
+func TestReadyzIncludeAndUnknownNames(t *testing.T) {
+	withTestHealth(t)
+	health.register("ping", probeAll, func(context.Context) error { return nil })
+	health.register("db", probeReady, func(context.Context) error { return errors.New("down") })
+	h := probeHandler(probeReady, "ready")
+
+	code, resp := probe(t, h, "/readyz?include=ping&exclude=cache")
+	if code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+	if len(resp.Warnings) != 1 {
+		t.Errorf("expected one warning for unknown check name, got %v", resp.Warnings)
+	}
+}
+
+func TestLivezIgnoresReadinessChecks(t *testing.T) {
+	withTestHealth(t)
+	health.register("ping", probeAll, func(context.Context) error { return nil })
+	health.register("db", probeReady, func(context.Context) error { return errors.New("down") })
+
+	if code, _ := probe(t, probeHandler(probeLive, "alive"), "/livez"); code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusOK)
+	}
+}
+
+func TestToggleReadiness(t *testing.T) {
+	t.Cleanup(func() { forcedUnready.Store(false) })
+
+	if ready := toggleReadiness(); ready {
+		t.Error("expected first toggle to mark the app unready")
+	}
+	if ready := toggleReadiness(); !ready {
+		t.Error("expected second toggle to mark the app ready again")
+	}
+}
+
+func TestShutdownFailsReadiness(t *testing.T) {
+	t.Cleanup(func() { shuttingDown.Store(false) })
+	shuttingDown.Store(true)
+
+	code, resp := probe(t, probeHandler(probeReady, "ready"), "/readyz?include=shutdown")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusServiceUnavailable)
+	}
+	for _, c := range resp.Checks {
+		if c.Status == "failed" && c.Name != "shutdown" {
+			t.Errorf("unexpected failing check %+v", c)
+		}
+	}
+}
+
+func TestHealthChecksRunConcurrentlyWithTimeouts(t *testing.T) {
+	withTestHealth(t)
+	prevTimeout, prevBudget, prevTimeouts := healthCheckTimeout, healthProbeBudget, healthCheckTimeouts
+	t.Cleanup(func() {
+		healthCheckTimeout, healthProbeBudget, healthCheckTimeouts = prevTimeout, prevBudget, prevTimeouts
+	})
+	healthCheckTimeout = 500 * time.Millisecond
+	healthProbeBudget = time.Second
+	healthCheckTimeouts = map[string]time.Duration{"stuck": 50 * time.Millisecond}
+
+	slow := func(context.Context) error { time.Sleep(100 * time.Millisecond); return nil }
+	health.register("slow-a", probeReady, slow)
+	health.register("slow-b", probeReady, slow)
+	// stuck ignores its context entirely, like a wedged driver call.
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	health.register("stuck", probeReady, func(context.Context) error { <-release; return nil })
+
+	start := time.Now()
+	ok, results, _ := health.run(context.Background(), probeReady, nil, nil)
+	elapsed := time.Since(start)
+
+	if ok {
+		t.Error("expected the stuck check to fail the probe")
+	}
+	if elapsed > 300*time.Millisecond {
+		t.Errorf("probe took %v; checks do not appear to run concurrently", elapsed)
+	}
+	want := []string{"ok", "ok", "failed"}
+	for i, res := range results {
+		if res.Status != want[i] {
+			t.Errorf("check %s: got status %s want %s", res.Name, res.Status, want[i])
+		}
+	}
+	if results[2].Error != "timed out after 50ms" {
+		t.Errorf("unexpected timeout error %q", results[2].Error)
+	}
+}
+
+func TestHealthProbeBudget(t *testing.T) {
+	withTestHealth(t)
+	prevBudget := healthProbeBudget
+	t.Cleanup(func() { healthProbeBudget = prevBudget })
+	healthProbeBudget = 50 * time.Millisecond
+
+	health.register("ping", probeReady, func(context.Context) error { return nil })
+	health.register("hang", probeReady, func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() })
+
+	code, resp := probe(t, probeHandler(probeReady, "ready"), "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", code, http.StatusServiceUnavailable)
+	}
+	if len(resp.Checks) != 2 || resp.Checks[0].Status != "ok" || resp.Checks[1].Status != "failed" {
+		t.Errorf("expected partial results, got %+v", resp.Checks)
+	}
+}
+
+func TestProbePlainText(t *testing.T) {
+	withTestHealth(t)
+	down := false
+	health.register("ping", probeAll, func(context.Context) error { return degraded(errors.New("slow")) })
+	health.register("db", probeReady, func(context.Context) error {
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	h := probeHandler(probeReady, "ready")
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("/readyz", "text/plain"); rr.Body.String() != "ok" || rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("got %q (%s), want a bare ok", rr.Body.String(), rr.Header().Get("Content-Type"))
+	}
+	if rr := get("/readyz", "*/*"); rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("*/* should keep JSON, got %s", rr.Header().Get("Content-Type"))
+	}
+	if rr := get("/readyz", "application/json, text/plain;q=0.5"); rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("JSON ranked higher should win, got %s", rr.Header().Get("Content-Type"))
+	}
+
+	down = true
+	rr := get("/readyz", "text/plain")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if rr.Body.String() != "unavailable" {
+		t.Errorf("got body %q want a bare unavailable", rr.Body.String())
+	}
+	rr = get("/readyz?verbose", "text/plain")
+	want := "[+]ping degraded\n[-]db failed: connection refused\nwarn: ping degraded: slow\nunavailable"
+	if rr.Body.String() != want {
+		t.Errorf("got verbose body %q want %q", rr.Body.String(), want)
+	}
+}
+
+func TestHealthStateGauges(t *testing.T) {
+	withTestHealth(t)
+	health.observe(recordCheckStatus)
+	var fail, warn error
+	health.register("gauge-db", probeReady, func(context.Context) error { return fail })
+	health.register("gauge-cache", probeReady, func(context.Context) error { return warn })
+	h := probeHandler(probeReady, "ready")
+
+	probe(t, h, "/readyz")
+	if got := testutil.ToFloat64(appReady); got != 1 {
+		t.Errorf("app_ready: got %v want 1", got)
+	}
+	if got := testutil.ToFloat64(appHealthCheckStatus.WithLabelValues("gauge-db")); got != 1 {
+		t.Errorf("app_health_check_status{check=gauge-db}: got %v want 1", got)
+	}
+
+	fail, warn = errors.New("down"), degraded(errors.New("slow"))
+	probe(t, h, "/readyz")
+	if got := testutil.ToFloat64(appReady); got != 0 {
+		t.Errorf("app_ready: got %v want 0", got)
+	}
+	if got := testutil.ToFloat64(appHealthCheckStatus.WithLabelValues("gauge-db")); got != 0 {
+		t.Errorf("app_health_check_status{check=gauge-db}: got %v want 0", got)
+	}
+	if got := testutil.ToFloat64(appHealthCheckStatus.WithLabelValues("gauge-cache")); got != 0.5 {
+		t.Errorf("app_health_check_status{check=gauge-cache}: got %v want 0.5", got)
+	}
+
+	// Filtered probes update per-check gauges but not app_ready.
+	fail = nil
+	probe(t, h, "/readyz?exclude=gauge-cache")
+	if got := testutil.ToFloat64(appReady); got != 0 {
+		t.Errorf("filtered probe changed app_ready to %v", got)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health history. Every change in a check's status, and every flip of a
+// probe endpoint between passing and failing, is kept in a ring buffer of
+// HEALTH_HISTORY_SIZE entries (default 200) and served at
+// /api/health/history, so flapping probes can be debugged after the fact. A
+// subject that changed state at least HEALTH_FLAP_THRESHOLD times (default
+// 4) within HEALTH_FLAP_WINDOW (default 5m) is reported as flapping.
+type healthTransition struct {
+	Time    time.Time `json:"time"`
+	Subject string    `json:"subject"` // probe:<kind> or check:<name>
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to"`
+	// Causes lists the failing checks behind a probe flip, or the check's
+	// error for a check transition.
+	Causes []string `json:"causes,omitempty"`
+}
+
+type flappingSubject struct {
+	Subject string `json:"subject"`
+	Flips   int    `json:"flips"`
+}
+
+type healthHistoryLog struct {
+	size          int
+	flapWindow    time.Duration
+	flapThreshold int
+
+	mu          sync.Mutex
+	last        map[string]string
+	transitions []healthTransition
+}
+
+var healthHistory = newHealthHistory(
+	getenvInt("HEALTH_HISTORY_SIZE", 200),
+	getenvDuration("HEALTH_FLAP_WINDOW", 5*time.Minute),
+	getenvInt("HEALTH_FLAP_THRESHOLD", 4),
+)
+
+func init() {
+	health.observe(func(res checkResult) {
+		var causes []string
+		if res.Error != "" {
+			causes = []string{res.Error}
+		}
+		healthHistory.observe("check:"+res.Name, res.Status, causes)
+	})
+}
+
+func newHealthHistory(size int, window time.Duration, threshold int) *healthHistoryLog {
+	return &healthHistoryLog{size: max(size, 1), flapWindow: window, flapThreshold: threshold, last: make(map[string]string)}
+}
+
+func (k probeKind) String() string {
+	switch k {
+	case probeLive:
+		return "live"
+	case probeReady:
+		return "ready"
+	default:
+		return "health"
+	}
+}
+
+// observe records state for subject if it differs from the last one seen.
+// The first observation of a subject is only recorded when it is not ok.
+func (h *healthHistoryLog) observe(subject, state string, causes []string) {
+	h.mu.Lock()
+	prev, seen := h.last[subject]
+	h.last[subject] = state
+	if prev == state || (!seen && state == "ok") {
+		h.mu.Unlock()
+		return
+	}
+	t := healthTransition{Time: time.Now().UTC(), Subject: subject, From: prev, To: state, Causes: causes}
+	h.transitions = append(h.transitions, t)
+	if over := len(h.transitions) - h.size; over > 0 {
+		h.transitions = append(h.transitions[:0:0], h.transitions[over:]...)
+	}
+	h.mu.Unlock()
+
+	events.publish(event{Type: eventHealthTransition, Time: t.Time, Subject: subject, Message: transitionMessage(t), Data: t})
+}
+
+func transitionMessage(t healthTransition) string {
+	from := t.From
+	if from == "" {
+		from = "unknown"
+	}
+	msg := t.Subject + " " + from + " -> " + t.To
+	if len(t.Causes) > 0 {
+		msg += " (" + strings.Join(t.Causes, "; ") + ")"
+	}
+	return msg
+}
+
+// observeProbe records the outcome of an unfiltered probe run.
+func (h *healthHistoryLog) observeProbe(kinds probeKind, ok bool, results []checkResult) {
+	state := "ok"
+	var causes []string
+	if !ok {
+		state = "failed"
+		for _, r := range results {
+			if r.Status == "failed" {
+				causes = append(causes, r.Name)
+			}
+		}
+	}
+	h.observe("probe:"+kinds.String(), state, causes)
+}
+
+func (h *healthHistoryLog) snapshot() ([]healthTransition, []flappingSubject) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cutoff := time.Now().Add(-h.flapWindow)
+	flips := make(map[string]int)
+	for _, t := range h.transitions {
+		if t.Time.After(cutoff) && t.From != "" {
+			flips[t.Subject]++
+		}
+	}
+	flapping := []flappingSubject{}
+	for subject, n := range flips {
+		if n >= h.flapThreshold {
+			flapping = append(flapping, flappingSubject{Subject: subject, Flips: n})
+		}
+	}
+	slices.SortFunc(flapping, func(a, b flappingSubject) int { return b.Flips - a.Flips })
+	return slices.Clone(h.transitions), flapping
+}
+
+func healthHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	transitions, flapping := healthHistory.snapshot()
+	if transitions == nil {
+		transitions = []healthTransition{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"transitions": transitions,
+		"flapping":    flapping,
+		"flapWindow":  healthHistory.flapWindow.String(),
+	})
+}
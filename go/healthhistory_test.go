@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthHistoryRecordsTransitions(t *testing.T) {
+	h := newHealthHistory(10, time.Minute, 3)
+	h.observe("check:db", "ok", nil)
+	h.observe("check:db", "ok", nil)
+	h.observe("check:db", "failed", []string{"connection refused"})
+	h.observe("check:redis", "failed", nil)
+
+	got, _ := h.snapshot()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", got)
+	}
+	if got[0].Subject != "check:db" || got[0].From != "ok" || got[0].To != "failed" {
+		t.Errorf("unexpected db transition %+v", got[0])
+	}
+	if got[1].Subject != "check:redis" || got[1].From != "" {
+		t.Errorf("a failing first observation should be recorded, got %+v", got[1])
+	}
+}
+
+func TestHealthHistoryFlapDetection(t *testing.T) {
+	h := newHealthHistory(10, time.Minute, 3)
+	for _, s := range []string{"ok", "failed", "ok", "failed"} {
+		h.observe("probe:ready", s, nil)
+	}
+	_, flapping := h.snapshot()
+	if len(flapping) != 1 || flapping[0].Subject != "probe:ready" || flapping[0].Flips != 3 {
+		t.Errorf("expected probe:ready flapping with 3 flips, got %+v", flapping)
+	}
+}
+
+func TestProbeFlipRecordsCause(t *testing.T) {
+	withTestHealth(t)
+	prev := healthHistory
+	healthHistory = newHealthHistory(10, time.Minute, 3)
+	t.Cleanup(func() { healthHistory = prev })
+
+	down := false
+	health.register("db", probeReady, func(context.Context) error {
+		if down {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	h := probeHandler(probeReady, "ready")
+	probe(t, h, "/readyz")
+	down = true
+	probe(t, h, "/readyz")
+	probe(t, h, "/readyz?exclude=db")
+
+	rr := httptest.NewRecorder()
+	healthHistoryHandler(rr, httptest.NewRequest("GET", "/api/health/history", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var resp struct {
+		Transitions []healthTransition `json:"transitions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Transitions) != 1 {
+		t.Fatalf("expected one probe flip (filtered probes are ignored), got %+v", resp.Transitions)
+	}
+	tr := resp.Transitions[0]
+	if tr.Subject != "probe:ready" || tr.To != "failed" || len(tr.Causes) != 1 || tr.Causes[0] != "db" {
+		t.Errorf("unexpected transition %+v", tr)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Host allowlist. ALLOWED_HOSTS ("demo.example.com,*.demo.example.com";
+// empty, the default, allows any) lists the names the app may be reached
+// by. A request whose Host header matches none of them is refused with 400,
+// which stops DNS rebinding and Host-header injection when the app sits
+// behind a wildcard ingress. Over TLS, a Host that differs from the SNI
+// server name the connection was set up for gets 421 Misdirected Request.
+// Ports are ignored, "*.example.com" matches any subdomain of example.com,
+// and probe paths are exempt because kubelets probe by pod IP.
+var allowedHosts = newHostAllowlist(getenv("ALLOWED_HOSTS", ""))
+
+type hostAllowlist struct {
+	exact    []string
+	suffixes []string
+}
+
+func newHostAllowlist(spec string) *hostAllowlist {
+	l := &hostAllowlist{}
+	for _, h := range strings.Split(spec, ",") {
+		h = normalizeHost(h)
+		switch {
+		case h == "":
+		case h == "*":
+			return &hostAllowlist{}
+		case strings.HasPrefix(h, "*."):
+			l.suffixes = append(l.suffixes, h[1:])
+		default:
+			l.exact = append(l.exact, h)
+		}
+	}
+	return l
+}
+
+func (l *hostAllowlist) enabled() bool { return len(l.exact)+len(l.suffixes) > 0 }
+
+func (l *hostAllowlist) allows(host string) bool {
+	host = normalizeHost(host)
+	if host == "" {
+		return false
+	}
+	return slices.Contains(l.exact, host) ||
+		slices.ContainsFunc(l.suffixes, func(s string) bool { return strings.HasSuffix(host, s) })
+}
+
+// normalizeHost lowercases a Host value and strips its port and any
+// trailing dot.
+func normalizeHost(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	if host, _, err := net.SplitHostPort(h); err == nil {
+		h = host
+	}
+	return strings.TrimSuffix(strings.Trim(h, "[]"), ".")
+}
+
+func withAllowedHosts() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allowedHosts.enabled() || isProbePath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowedHosts.allows(r.Host) {
+				hostRejections.WithLabelValues("host").Inc()
+				writeError(w, http.StatusBadRequest, "host not allowed")
+				return
+			}
+			if r.TLS != nil && r.TLS.ServerName != "" && normalizeHost(r.TLS.ServerName) != normalizeHost(r.Host) {
+				hostRejections.WithLabelValues("sni").Inc()
+				writeError(w, http.StatusMisdirectedRequest, "host does not match the TLS server name")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostAllowlist(t *testing.T) {
+	l := newHostAllowlist("Demo.example.com, *.apps.example.com")
+	for host, want := range map[string]bool{
+		"demo.example.com":      true,
+		"demo.example.com:8080": true,
+		"DEMO.example.com.":     true,
+		"a.apps.example.com":    true,
+		"apps.example.com":      false,
+		"evil.com":              false,
+		"demo.example.com.evil": false,
+		"":                      false,
+	} {
+		if got := l.allows(host); got != want {
+			t.Errorf("allows(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if newHostAllowlist("").enabled() || newHostAllowlist("a.com,*").enabled() {
+		t.Error("an empty or wildcard list should allow any host")
+	}
+}
+
+func TestWithAllowedHosts(t *testing.T) {
+	prev := allowedHosts
+	allowedHosts = newHostAllowlist("demo.example.com")
+	t.Cleanup(func() { allowedHosts = prev })
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withAllowedHosts())
+	serve := func(path, host string, sni string) int {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Host = host
+		if sni != "" {
+			r.TLS = &tls.ConnectionState{ServerName: sni}
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code
+	}
+	if code := serve("/api/info", "demo.example.com", ""); code != http.StatusOK {
+		t.Errorf("allowed host: status %d", code)
+	}
+	if code := serve("/api/info", "attacker.test", ""); code != http.StatusBadRequest {
+		t.Errorf("other host: status %d, want 400", code)
+	}
+	if code := serve("/api/info", "demo.example.com", "other.example.com"); code != http.StatusMisdirectedRequest {
+		t.Errorf("SNI mismatch: status %d, want 421", code)
+	}
+	if code := serve("/healthz", "10.1.2.3:8080", ""); code != http.StatusOK {
+		t.Errorf("probe by pod IP: status %d", code)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// withMetrics records per-route request metrics and feeds the SLO tracker.
+// Routes are labeled by their mux pattern (e.g. "/api/kv/{key}") rather than
+// the raw path so label cardinality stays bounded.
+func withMetrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			httpRequestsInFlight.Inc()
+			defer httpRequestsInFlight.Dec()
+			otelHTTP.start(r.Context(), methodLabel(r.Method))
+			start := time.Now()
+			cw := &rwCapture{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			elapsed := time.Since(start)
+			route, method := routeLabel(r), methodLabel(r.Method)
+			observeWithTrace(httpRequestDuration.WithLabelValues(route, method, statusClass(status)), elapsed.Seconds(), r)
+			httpRequestsTotal.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+			httpResponseSize.WithLabelValues(route).Observe(float64(cw.bytes))
+			otelHTTP.finish(r.Context(), route, method, status, elapsed, int64(cw.bytes))
+			if statsd != nil {
+				tags := []string{"route:" + route, "method:" + method, "status:" + strconv.Itoa(status)}
+				statsd.count("http.requests", 1, tags...)
+				statsd.timing("http.request.duration", elapsed, tags...)
+			}
+			if !isProbePath(r.URL.Path) {
+				slo.record(status, elapsed)
+				clients.record(clients.key(r), status, start)
+			}
+		})
+	}
+}
+
+// observeWithTrace records v, attaching the request's trace ID as an
+// exemplar when it arrived with a sampled trace, so dashboards can jump from
+// a latency bucket to an example trace.
+func observeWithTrace(o prometheus.Observer, v float64, r *http.Request) {
+	if tc, ok := requestTrace(r); ok && tc.Sampled {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": tc.TraceID})
+			return
+		}
+	}
+	o.Observe(v)
+}
+
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	return r.Pattern
+}
+
+// methodLabel folds non-standard methods into OTHER so clients can't mint
+// new label values.
+func methodLabel(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return m
+	}
+	return "OTHER"
+}
+
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func histogramCount(t *testing.T, h *prometheus.HistogramVec, labels ...string) uint64 {
+	t.Helper()
+	obs, err := h.GetMetricWithLabelValues(labels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m dto.Metric
+	if err := obs.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestWithMetricsObservesDuration(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/items/{id}", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}), withMetrics()))
+
+	before := histogramCount(t, httpRequestDuration, "/api/items/{id}", "GET", "4xx")
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/items/42", nil))
+	if got := histogramCount(t, httpRequestDuration, "/api/items/{id}", "GET", "4xx"); got != before+1 {
+		t.Errorf("expected one observation for the route pattern, got %d", got-before)
+	}
+}
+
+func TestWithMetricsCountsRequestsAndInFlight(t *testing.T) {
+	var inFlight float64
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = testutil.ToFloat64(httpRequestsInFlight)
+		w.WriteHeader(http.StatusCreated)
+	}), withMetrics())
+	mux := http.NewServeMux()
+	mux.Handle("/api/things", h)
+
+	before := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/api/things", "POST", "201"))
+	base := testutil.ToFloat64(httpRequestsInFlight)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/things", nil))
+
+	if got := testutil.ToFloat64(httpRequestsTotal.WithLabelValues("/api/things", "POST", "201")); got != before+1 {
+		t.Errorf("http_requests_total grew by %v, want 1", got-before)
+	}
+	if inFlight != base+1 {
+		t.Errorf("in-flight gauge during request: got %v want %v", inFlight, base+1)
+	}
+	if got := testutil.ToFloat64(httpRequestsInFlight); got != base {
+		t.Errorf("in-flight gauge after request: got %v want %v", got, base)
+	}
+}
+
+func TestWithMetricsObservesResponseSize(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/blob", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 1000))
+	}), withMetrics()))
+
+	obs, _ := httpResponseSize.GetMetricWithLabelValues("/api/blob")
+	var before dto.Metric
+	_ = obs.(prometheus.Metric).Write(&before)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/blob", nil))
+	var after dto.Metric
+	_ = obs.(prometheus.Metric).Write(&after)
+
+	if got := after.GetHistogram().GetSampleSum() - before.GetHistogram().GetSampleSum(); got != 1000 {
+		t.Errorf("observed %v bytes, want 1000", got)
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	if methodLabel("GET") != "GET" || methodLabel("BREW") != "OTHER" {
+		t.Error("unexpected method labels")
+	}
+	if statusClass(503) != "5xx" {
+		t.Errorf("statusClass(503) = %s", statusClass(503))
+	}
+}
+
+func TestWithMetricsAttachesTraceExemplar(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/traced", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withMetrics()))
+
+	req := httptest.NewRequest("GET", "/api/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	obs, _ := httpRequestDuration.GetMetricWithLabelValues("/api/traced", "GET", "2xx")
+	var m dto.Metric
+	if err := obs.(prometheus.Metric).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	var traceIDs []string
+	for _, b := range m.GetHistogram().GetBucket() {
+		for _, l := range b.GetExemplar().GetLabel() {
+			traceIDs = append(traceIDs, l.GetValue())
+		}
+	}
+	if len(traceIDs) != 1 || traceIDs[0] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("exemplar trace IDs = %v", traceIDs)
+	}
+}
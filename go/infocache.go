@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// /api/info is served from a cached snapshot so load tests against it
+// measure the server, not hostname lookups and pool stat reads. A background
+// refresher rebuilds the snapshot every INFO_REFRESH_INTERVAL (default 10s);
+// a request that finds it older than that serves it anyway and triggers a
+// refresh (stale-while-revalidate). Uptime is always computed per request.
+type infoCache struct {
+	interval time.Duration
+	build    func() AppInfo
+
+	snap       atomic.Pointer[AppInfo]
+	refreshing atomic.Bool
+	once       sync.Once
+}
+
+var appInfoCache = &infoCache{
+	interval: infoRefreshInterval(),
+	build:    buildAppInfo,
+}
+
+// infoRefreshInterval reads INFO_REFRESH_INTERVAL, falling back to the
+// default for values that aren't positive; the refresher's ticker needs one.
+func infoRefreshInterval() time.Duration {
+	const def = 10 * time.Second
+	d := getenvDuration("INFO_REFRESH_INTERVAL", def)
+	if d <= 0 {
+		logger.Warn("invalid duration in environment, using default", "key", "INFO_REFRESH_INTERVAL", "value", d.String(), "default", def)
+		return def
+	}
+	return d
+}
+
+func buildAppInfo() AppInfo {
+	hostname, _ := os.Hostname()
+	return AppInfo{
+		Name:        appName,
+		Version:     version,
+		Environment: env,
+		BuildTime:   buildTime,
+		Hostname:    hostname,
+		Database:    currentDBInfo(),
+		Replica:     replica,
+		AsOf:        time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// get returns the current snapshot, building it synchronously only the
+// first time.
+func (c *infoCache) get() AppInfo {
+	if p := c.snap.Load(); p != nil {
+		if asOf, err := time.Parse(time.RFC3339Nano, p.AsOf); err == nil && time.Since(asOf) > c.interval {
+			c.refreshAsync()
+		}
+		return *p
+	}
+	c.once.Do(c.refresh)
+	return *c.snap.Load()
+}
+
+func (c *infoCache) refresh() {
+	info := c.build()
+	c.snap.Store(&info)
+}
+
+func (c *infoCache) refreshAsync() {
+	if !c.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer c.refreshing.Store(false)
+		c.refresh()
+	}()
+}
+
+// run refreshes the snapshot on a fixed interval.
+func (c *infoCache) run() {
+	t := time.NewTicker(c.interval)
+	defer t.Stop()
+	for range t.C {
+		c.refreshAsync()
+	}
+}
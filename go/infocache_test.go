@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestInfoCacheServesStaleWhileRevalidating(t *testing.T) {
+	var builds atomic.Int64
+	c := &infoCache{interval: 20 * time.Millisecond, build: func() AppInfo {
+		n := builds.Add(1)
+		return AppInfo{Version: string(rune('0' + n)), AsOf: time.Now().UTC().Format(time.RFC3339Nano)}
+	}}
+
+	if got := c.get().Version; got != "1" {
+		t.Fatalf("first get should build synchronously, got version %q", got)
+	}
+	if got := c.get().Version; got != "1" || builds.Load() != 1 {
+		t.Errorf("fresh snapshot should be reused, got %q after %d builds", got, builds.Load())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if got := c.get().Version; got != "1" {
+		t.Errorf("stale snapshot should still be served, got %q", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.get().Version != "2" {
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh never landed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInfoHandlerIncludesAsOfAndLiveUptime(t *testing.T) {
+	rr := httptest.NewRecorder()
+	infoHandler(rr, httptest.NewRequest("GET", "/api/info", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var info AppInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.AsOf == "" || info.Uptime == "" {
+		t.Errorf("expected asOf and uptime, got %+v", info)
+	}
+}
+
+func TestInfoRefreshIntervalRejectsNonPositive(t *testing.T) {
+	for _, v := range []string{"0s", "-5s"} {
+		t.Setenv("INFO_REFRESH_INTERVAL", v)
+		if got := infoRefreshInterval(); got != 10*time.Second {
+			t.Errorf("INFO_REFRESH_INTERVAL=%s: got %s, want the 10s default", v, got)
+		}
+	}
+	t.Setenv("INFO_REFRESH_INTERVAL", "3s")
+	if got := infoRefreshInterval(); got != 3*time.Second {
+		t.Errorf("got %s, want 3s", got)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// `app inspect` prints a JSON footprint report for tracking image bloat
+// across releases: how much of the binary is embedded assets versus code,
+// and, unless --no-start is given, how long a fresh copy of the server takes
+// to become ready and how much memory it holds at that point.
+type inspectReport struct {
+	Version   string         `json:"version"`
+	GoVersion string         `json:"goVersion"`
+	GOOS      string         `json:"goos"`
+	GOARCH    string         `json:"goarch"`
+	Binary    binaryReport   `json:"binary"`
+	Startup   *startupReport `json:"startup,omitempty"`
+}
+
+type binaryReport struct {
+	Path               string        `json:"path"`
+	Bytes              int64         `json:"bytes"`
+	EmbeddedAssetBytes int64         `json:"embeddedAssetBytes"`
+	CodeBytes          int64         `json:"codeBytes"`
+	Assets             []assetReport `json:"assets"`
+}
+
+type assetReport struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+type startupReport struct {
+	TimeToStartedMs int64 `json:"timeToStartedMs"`
+	TimeToReadyMs   int64 `json:"timeToReadyMs"`
+	// RSSBytes is the resident set size once ready; omitted where /proc is
+	// unavailable.
+	RSSBytes int64  `json:"rssBytes,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func runInspect(args []string, out io.Writer) error {
+	fl := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	noStart := fl.Bool("no-start", false, "skip launching the server to measure startup")
+	timeout := fl.Duration("timeout", 30*time.Second, "how long to wait for the server to become ready")
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	assets, assetBytes, err := embeddedAssets()
+	if err != nil {
+		return err
+	}
+	rep := inspectReport{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		Binary: binaryReport{
+			Path:               exe,
+			Bytes:              fi.Size(),
+			EmbeddedAssetBytes: assetBytes,
+			CodeBytes:          fi.Size() - assetBytes,
+			Assets:             assets,
+		},
+	}
+	if !*noStart {
+		rep.Startup = measureStartup(exe, *timeout)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// embeddedAssets lists every file in embeddedFS, largest first.
+func embeddedAssets() ([]assetReport, int64, error) {
+	var out []assetReport
+	var total int64
+	err := fs.WalkDir(embeddedFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out = append(out, assetReport{Path: path, Bytes: info.Size()})
+		total += info.Size()
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Bytes > out[j].Bytes })
+	return out, total, err
+}
+
+// measureStartup runs exe as a server on a free loopback port and times
+// /startupz and /readyz until both succeed.
+func measureStartup(exe string, timeout time.Duration) *startupReport {
+	rep := &startupReport{}
+	port, err := freePort()
+	if err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), "PORT="+strconv.Itoa(port), "WORKER_ID=")
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	base := "http://127.0.0.1:" + strconv.Itoa(port)
+	if err := waitFor(ctx, base+"/startupz"); err != nil {
+		rep.Error = "waiting for /startupz: " + err.Error()
+		return rep
+	}
+	rep.TimeToStartedMs = time.Since(start).Milliseconds()
+	if err := waitFor(ctx, base+"/readyz"); err != nil {
+		rep.Error = "waiting for /readyz: " + err.Error()
+		return rep
+	}
+	rep.TimeToReadyMs = time.Since(start).Milliseconds()
+	if rss, err := processRSS(cmd.Process.Pid); err == nil {
+		rep.RSSBytes = rss
+	}
+	return rep
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// waitFor polls url until it returns 200 or ctx ends.
+func waitFor(ctx context.Context, url string) error {
+	client := &http.Client{Timeout: time.Second}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// processRSS reads VmRSS for pid from /proc.
+func processRSS(pid int) (int64, error) {
+	f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		v, ok := strings.CutPrefix(sc.Text(), "VmRSS:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(v), " kB"), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return kb * 1024, nil
+	}
+	return 0, errors.New("VmRSS not found")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestEmbeddedAssets(t *testing.T) {
+	assets, total, err := embeddedAssets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int64
+	found := false
+	for _, a := range assets {
+		sum += a.Bytes
+		if a.Path == "static/index.html" {
+			found = true
+			if a.Bytes != int64(len(indexHTML)) {
+				t.Errorf("index.html size: got %d want %d", a.Bytes, len(indexHTML))
+			}
+		}
+	}
+	if !found {
+		t.Error("static/index.html missing from asset list")
+	}
+	if sum != total {
+		t.Errorf("total %d does not match sum of assets %d", total, sum)
+	}
+	for i := 1; i < len(assets); i++ {
+		if assets[i].Bytes > assets[i-1].Bytes {
+			t.Fatal("assets not sorted largest first")
+		}
+	}
+}
+
+func TestRunInspectNoStart(t *testing.T) {
+	var out bytes.Buffer
+	if err := runInspect([]string{"--no-start"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	var rep inspectReport
+	if err := json.Unmarshal(out.Bytes(), &rep); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if rep.Binary.Bytes <= 0 || rep.Binary.CodeBytes != rep.Binary.Bytes-rep.Binary.EmbeddedAssetBytes {
+		t.Errorf("inconsistent binary sizes: %+v", rep.Binary)
+	}
+	if rep.Startup != nil {
+		t.Error("startup measured despite --no-start")
+	}
+}
+
+func TestProcessRSS(t *testing.T) {
+	if _, err := os.Stat("/proc/self/status"); err != nil {
+		t.Skip("no /proc on this platform")
+	}
+	rss, err := processRSS(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rss <= 0 {
+		t.Errorf("rss = %d, want > 0", rss)
+	}
+}
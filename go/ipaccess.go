@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// IP access rules. IP_ACCESS_RULES restricts groups of routes, identified
+// by path prefix, to or from client address ranges, e.g.
+//
+//	/api/admin/ allow private; / deny 203.0.113.0/24,2001:db8::/32
+//
+// Rules are separated by ";", each a path prefix, "allow" or "deny" and a
+// comma-separated list of CIDRs or addresses; "private" stands for the
+// RFC 1918, loopback and IPv6 unique-local ranges. A request is judged by
+// the group with the longest prefix matching its path: it is refused with
+// 403 if its client address is on the group's deny list, or if the group
+// has an allow list and the address isn't on it. Paths no group covers are
+// unrestricted.
+var privateRanges = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+type ipAccessGroup struct {
+	prefix string
+	allow  []netip.Prefix
+	deny   []netip.Prefix
+}
+
+// ipAccessRules holds the groups, longest prefix first.
+type ipAccessRules []*ipAccessGroup
+
+func parseIPAccessRules(spec string) (ipAccessRules, error) {
+	byPrefix := make(map[string]*ipAccessGroup)
+	var rules ipAccessRules
+	for _, rule := range strings.Split(spec, ";") {
+		fields := strings.Fields(rule)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 || !strings.HasPrefix(fields[0], "/") {
+			return nil, fmt.Errorf("invalid rule %q, want \"/prefix allow|deny cidr,...\"", strings.TrimSpace(rule))
+		}
+		var cidrs []netip.Prefix
+		for _, s := range strings.Split(fields[2], ",") {
+			if s == "private" {
+				cidrs = append(cidrs, privateRanges...)
+				continue
+			}
+			p, err := parseCIDRList(s)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", strings.TrimSpace(rule), err)
+			}
+			cidrs = append(cidrs, p...)
+		}
+		g, ok := byPrefix[fields[0]]
+		if !ok {
+			g = &ipAccessGroup{prefix: fields[0]}
+			byPrefix[g.prefix] = g
+			rules = append(rules, g)
+		}
+		switch fields[1] {
+		case "allow":
+			g.allow = append(g.allow, cidrs...)
+		case "deny":
+			g.deny = append(g.deny, cidrs...)
+		default:
+			return nil, fmt.Errorf("invalid rule %q, want allow or deny", strings.TrimSpace(rule))
+		}
+	}
+	slices.SortFunc(rules, func(a, b *ipAccessGroup) int { return len(b.prefix) - len(a.prefix) })
+	return rules, nil
+}
+
+// group returns the group covering path, or nil.
+func (rules ipAccessRules) group(path string) *ipAccessGroup {
+	for _, g := range rules {
+		if strings.HasPrefix(path, g.prefix) {
+			return g
+		}
+	}
+	return nil
+}
+
+func (g *ipAccessGroup) allows(a netip.Addr) bool {
+	in := func(p netip.Prefix) bool { return p.Contains(a) }
+	if slices.ContainsFunc(g.deny, in) {
+		return false
+	}
+	return len(g.allow) == 0 || slices.ContainsFunc(g.allow, in)
+}
+
+func withIPAccess(rules ipAccessRules) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g := rules.group(r.URL.Path)
+			if g == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if a, ok := clientAddr(r); !ok || !g.allows(a) {
+				ipAccessDenied.WithLabelValues(g.prefix).Inc()
+				writeError(w, http.StatusForbidden, "client address not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
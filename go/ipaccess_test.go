@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseIPAccessRules(t *testing.T) {
+	rules, err := parseIPAccessRules("/ deny 203.0.113.0/24; /api/admin/ allow private ; /api/admin/ deny 10.9.9.9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].prefix != "/api/admin/" || rules[1].prefix != "/" {
+		t.Fatalf("groups %+v, want /api/admin/ before /", rules)
+	}
+	for _, bad := range []string{"/api allow", "api/ allow 10.0.0.0/8", "/api permit 10.0.0.0/8", "/api allow 10.0.0.0/33"} {
+		if _, err := parseIPAccessRules(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestWithIPAccess(t *testing.T) {
+	rules, err := parseIPAccessRules("/api/admin/ allow private; /api/admin/ deny 10.9.9.9; / deny 203.0.113.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withIPAccess(rules))
+	before := testutil.ToFloat64(ipAccessDenied.WithLabelValues("/api/admin/"))
+	for _, tc := range []struct {
+		path, remote string
+		want         int
+	}{
+		{"/api/admin/loglevel", "10.1.2.3:5000", http.StatusOK},
+		{"/api/admin/loglevel", "[::ffff:192.168.1.1]:5000", http.StatusOK},
+		{"/api/admin/loglevel", "198.51.100.7:5000", http.StatusForbidden},
+		{"/api/admin/loglevel", "10.9.9.9:5000", http.StatusForbidden},
+		{"/api/info", "198.51.100.7:5000", http.StatusOK},
+		{"/api/info", "203.0.113.5:5000", http.StatusForbidden},
+	} {
+		r := httptest.NewRequest("GET", tc.path, nil)
+		r.RemoteAddr = tc.remote
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		if rr.Code != tc.want {
+			t.Errorf("%s from %s: status %d, want %d", tc.path, tc.remote, rr.Code, tc.want)
+		}
+	}
+	if got := testutil.ToFloat64(ipAccessDenied.WithLabelValues("/api/admin/")); got != before+2 {
+		t.Errorf("ip_access_denied_total{group=/api/admin/} grew by %v, want 2", got-before)
+	}
+}
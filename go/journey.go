@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request journeys. Each hop appends "<replica-id>@<unix-ms>" to the
+// X-Journey header, both on the response and on any outbound call made
+// while serving the request, so a path through several replicas can be
+// reconstructed from headers alone. Only the last JOURNEY_MAX_HOPS hops
+// (default 32) are kept.
+const journeyHeader = "X-Journey"
+
+var journeyMaxHops = max(getenvInt("JOURNEY_MAX_HOPS", 32), 1)
+
+type journeyKey struct{}
+
+// appendJourney adds this replica's hop to an incoming journey value.
+func appendJourney(incoming string, now time.Time) string {
+	var hops []string
+	for _, h := range strings.Split(incoming, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hops = append(hops, h)
+		}
+	}
+	hops = append(hops, replica.ID+"@"+strconv.FormatInt(now.UnixMilli(), 10))
+	if len(hops) > journeyMaxHops {
+		hops = hops[len(hops)-journeyMaxHops:]
+	}
+	return strings.Join(hops, ", ")
+}
+
+func journeyFrom(ctx context.Context) string {
+	j, _ := ctx.Value(journeyKey{}).(string)
+	return j
+}
+
+// withJourney records this hop on the response and in the request context
+// for outbound calls.
+func withJourney() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			j := appendJourney(strings.Join(r.Header.Values(journeyHeader), ","), time.Now())
+			w.Header().Set(journeyHeader, j)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), journeyKey{}, j)))
+		})
+	}
+}
+
+// journeyTransport forwards the current request's journey on outbound
+// calls.
+type journeyTransport struct {
+	base http.RoundTripper
+}
+
+func (t journeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if j := journeyFrom(req.Context()); j != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(journeyHeader, j)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections lets outboundClient.CloseIdleConnections reach the
+// wrapped transport.
+func (t journeyTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
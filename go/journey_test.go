@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendJourney(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	hop := replica.ID + "@1700000000123"
+	if got := appendJourney("", now); got != hop {
+		t.Errorf("got %q want %q", got, hop)
+	}
+	if got := appendJourney("aaa@1, bbb@2", now); got != "aaa@1, bbb@2, "+hop {
+		t.Errorf("got %q", got)
+	}
+
+	prev := journeyMaxHops
+	journeyMaxHops = 2
+	t.Cleanup(func() { journeyMaxHops = prev })
+	if got := appendJourney("aaa@1, bbb@2", now); got != "bbb@2, "+hop {
+		t.Errorf("expected the oldest hop to be dropped, got %q", got)
+	}
+}
+
+func TestJourneyPropagatesToOutboundCalls(t *testing.T) {
+	var seen string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get(journeyHeader)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: journeyTransport{base: http.DefaultTransport}}
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}), withJourney())
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(journeyHeader, "edge@1")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	got := rr.Header().Get(journeyHeader)
+	if !strings.HasPrefix(got, "edge@1, "+replica.ID+"@") {
+		t.Errorf("response journey %q does not extend the incoming one", got)
+	}
+	if seen != got {
+		t.Errorf("outbound journey %q differs from response journey %q", seen, got)
+	}
+}
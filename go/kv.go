@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// kvStore is a small in-memory scratchpad that pipeline stages can use to
+// hand values to each other through the deployed app. Every key carries a
+// TTL and the store enforces caps on key count and value size so it can't
+// grow without bound.
+type kvStore struct {
+	mu         sync.Mutex
+	items      map[string]kvItem
+	maxKeys    int
+	maxValue   int
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	// bytes approximates the memory held by items, for the memory budget.
+	bytes int64
+	// backend receives every change; see Store.
+	backend Store
+}
+
+type kvItem struct {
+	value       []byte
+	contentType string
+	expires     time.Time
+}
+
+const kvMaxKeyLen = 256
+
+// kvItemOverhead approximates per-key bookkeeping beyond key and value.
+const kvItemOverhead = 64
+
+func kvItemSize(key string, it kvItem) int64 {
+	return int64(len(key)+len(it.value)+len(it.contentType)) + kvItemOverhead
+}
+
+var (
+	errKVValueTooLarge = errors.New("value exceeds size cap")
+	errKVBadTTL        = errors.New("ttl must be a positive duration")
+)
+
+var kv = newKVStore(
+	getenvInt("KV_MAX_KEYS", 1000),
+	getenvInt("KV_MAX_VALUE_BYTES", 64<<10),
+	getenvDuration("KV_DEFAULT_TTL", time.Hour),
+	getenvDuration("KV_MAX_TTL", 24*time.Hour),
+)
+
+func newKVStore(maxKeys, maxValue int, defaultTTL, maxTTL time.Duration) *kvStore {
+	return &kvStore{
+		items:      make(map[string]kvItem),
+		maxKeys:    maxKeys,
+		maxValue:   maxValue,
+		defaultTTL: defaultTTL,
+		maxTTL:     maxTTL,
+		backend:    memoryStore{},
+	}
+}
+
+// attach loads any unexpired keys from backend, up to maxKeys, and writes
+// through to it from then on.
+func (s *kvStore) attach(backend Store) error {
+	items, err := backend.LoadKV()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backend = backend
+	for k, it := range items {
+		if now.After(it.expires) || len(it.value) > s.maxValue {
+			storeWrite("kv-delete", backend.DeleteKV(k))
+			continue
+		}
+		if len(s.items) >= s.maxKeys {
+			s.evictOneLocked("capacity")
+		}
+		s.items[k] = it
+		s.bytes += kvItemSize(k, it)
+	}
+	kvKeys.Set(float64(len(s.items)))
+	return nil
+}
+
+func (s *kvStore) get(key string) (kvItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if !ok {
+		return kvItem{}, false
+	}
+	if time.Now().After(it.expires) {
+		s.evictLocked(key, "expired")
+		return kvItem{}, false
+	}
+	return it, true
+}
+
+// put stores value under key and reports whether the key was newly created.
+// A ttl of zero selects the default; anything above maxTTL is clamped.
+func (s *kvStore) put(key string, value []byte, contentType string, ttl time.Duration) (kvItem, bool, error) {
+	if len(value) > s.maxValue {
+		return kvItem{}, false, errKVValueTooLarge
+	}
+	if ttl < 0 {
+		return kvItem{}, false, errKVBadTTL
+	}
+	if ttl == 0 {
+		ttl = s.defaultTTL
+	}
+	if ttl > s.maxTTL {
+		ttl = s.maxTTL
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, exists := s.items[key]
+	if exists {
+		s.bytes -= kvItemSize(key, old)
+	} else if len(s.items) >= s.maxKeys {
+		s.evictOneLocked("capacity")
+	}
+	it := kvItem{value: value, contentType: contentType, expires: time.Now().Add(ttl)}
+	s.items[key] = it
+	s.bytes += kvItemSize(key, it)
+	kvKeys.Set(float64(len(s.items)))
+	storeWrite("kv-put", s.backend.PutKV(key, it))
+	memBudget.nudge()
+	return it, !exists, nil
+}
+
+func (s *kvStore) delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	s.bytes -= kvItemSize(key, it)
+	delete(s.items, key)
+	kvKeys.Set(float64(len(s.items)))
+	storeWrite("kv-delete", s.backend.DeleteKV(key))
+	return true
+}
+
+// clear drops every key and returns how many there were.
+func (s *kvStore) clear(reason string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.items)
+	for k := range s.items {
+		s.evictLocked(k, reason)
+	}
+	return n
+}
+
+func (s *kvStore) usage() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// shed evicts keys, soonest-expiring first, until at least n bytes are
+// freed or the store is empty. It returns the bytes freed.
+func (s *kvStore) shed(n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := s.bytes
+	for start-s.bytes < n && len(s.items) > 0 {
+		s.evictOneLocked("memory")
+	}
+	return start - s.bytes
+}
+
+// sweep drops every expired key.
+func (s *kvStore) sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, it := range s.items {
+		if now.After(it.expires) {
+			s.evictLocked(k, "expired")
+		}
+	}
+}
+
+// evictOneLocked makes room by dropping whichever key would have expired
+// soonest.
+func (s *kvStore) evictOneLocked(reason string) {
+	var victim string
+	var soonest time.Time
+	for k, it := range s.items {
+		if victim == "" || it.expires.Before(soonest) {
+			victim, soonest = k, it.expires
+		}
+	}
+	if victim != "" {
+		s.evictLocked(victim, reason)
+	}
+}
+
+func (s *kvStore) evictLocked(key, reason string) {
+	s.bytes -= kvItemSize(key, s.items[key])
+	delete(s.items, key)
+	kvEvictions.WithLabelValues(reason).Inc()
+	kvKeys.Set(float64(len(s.items)))
+	storeWrite("kv-delete", s.backend.DeleteKV(key))
+}
+
+// janitor sweeps expired keys every interval until ctx is done.
+func (s *kvStore) janitor(ctx context.Context, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.sweep()
+		}
+	}
+}
+
+// kvHandler serves GET/PUT/DELETE on /api/kv/{key}. PUT accepts an optional
+// ?ttl=<duration> query parameter.
+func kvHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if key == "" || len(key) > kvMaxKeyLen {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("key must be 1-%d bytes", kvMaxKeyLen))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		it, ok := kv.get(key)
+		if !ok {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		ct := it.contentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", ct)
+		w.Header().Set("Expires", it.expires.UTC().Format(http.TimeFormat))
+		_, _ = w.Write(it.value)
+
+	case http.MethodPut:
+		var ttl time.Duration
+		if v := r.URL.Query().Get("ttl"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, errKVBadTTL.Error())
+				return
+			}
+			ttl = d
+		}
+		// Read one byte past the cap so oversized bodies are detectable.
+		body, err := io.ReadAll(io.LimitReader(r.Body, int64(kv.maxValue)+1))
+		if errors.As(err, new(*http.MaxBytesError)) {
+			writeBodyError(w, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body")
+			return
+		}
+		it, created, err := kv.put(key, body, r.Header.Get("Content-Type"), ttl)
+		if errors.Is(err, errKVValueTooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error()+" ("+strconv.Itoa(kv.maxValue)+" bytes)")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, map[string]any{
+			"key":       key,
+			"bytes":     len(it.value),
+			"expiresAt": it.expires.UTC().Format(time.RFC3339),
+		})
+
+	case http.MethodDelete:
+		if !kv.delete(key) {
+			writeError(w, http.StatusNotFound, "key not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
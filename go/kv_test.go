@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func kvRequest(t *testing.T, method, target, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("key", key)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(kvHandler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestKVHandlerRoundTrip(t *testing.T) {
+	kv = newKVStore(10, 64, time.Minute, time.Hour)
+
+	if rr := kvRequest(t, "PUT", "/api/kv/build?ttl=5m", "build", "1234"); rr.Code != http.StatusCreated {
+		t.Fatalf("PUT returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	rr := kvRequest(t, "GET", "/api/kv/build", "build", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr.Body.String() != "1234" {
+		t.Errorf("GET returned unexpected body: got %v want %v", rr.Body.String(), "1234")
+	}
+	if rr := kvRequest(t, "DELETE", "/api/kv/build", "build", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+	if rr := kvRequest(t, "GET", "/api/kv/build", "build", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("GET after DELETE returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestKVHandlerSizeCap(t *testing.T) {
+	kv = newKVStore(10, 4, time.Minute, time.Hour)
+
+	rr := kvRequest(t, "PUT", "/api/kv/big", "big", "12345")
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PUT returned wrong status code: got %v want %v", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestKVStoreEvictsSoonestExpiring(t *testing.T) {
+	s := newKVStore(2, 64, time.Minute, time.Hour)
+	_, _, _ = s.put("short", []byte("a"), "", time.Second)
+	_, _, _ = s.put("long", []byte("b"), "", time.Hour)
+	_, _, _ = s.put("new", []byte("c"), "", time.Minute)
+
+	if _, ok := s.get("short"); ok {
+		t.Errorf("expected key %q to be evicted", "short")
+	}
+	for _, k := range []string{"long", "new"} {
+		if _, ok := s.get(k); !ok {
+			t.Errorf("expected key %q to be present", k)
+		}
+	}
+}
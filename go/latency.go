@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// latencyDist describes a per-route delay distribution. All parameters are
+// in milliseconds:
+//
+//	constant:    ms
+//	uniform:     minMs, maxMs
+//	normal:      meanMs, stddevMs
+//	lognormal:   p50Ms, p99Ms (the shape most recorded latencies follow)
+//	exponential: meanMs
+//
+// capMs, if set, bounds every sample.
+type latencyDist struct {
+	Type     string  `json:"type"`
+	Ms       float64 `json:"ms,omitempty"`
+	MinMs    float64 `json:"minMs,omitempty"`
+	MaxMs    float64 `json:"maxMs,omitempty"`
+	MeanMs   float64 `json:"meanMs,omitempty"`
+	StddevMs float64 `json:"stddevMs,omitempty"`
+	P50Ms    float64 `json:"p50Ms,omitempty"`
+	P99Ms    float64 `json:"p99Ms,omitempty"`
+	CapMs    float64 `json:"capMs,omitempty"`
+}
+
+// latencyModel maps route patterns (as registered on the mux, e.g.
+// "/api/kv/{key}") to the distribution their injected delay is drawn from.
+type latencyModel struct {
+	Routes map[string]latencyDist `json:"routes"`
+}
+
+// z-score of the 99th percentile of the standard normal distribution.
+const z99 = 2.326
+
+// activeLatencyModel is swapped atomically so it can be reset at runtime.
+var activeLatencyModel atomic.Pointer[latencyModel]
+
+func loadLatencyModel(path string) (*latencyModel, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m latencyModel
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for route, d := range m.Routes {
+		if err := d.validate(); err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+	}
+	return &m, nil
+}
+
+func (d latencyDist) validate() error {
+	switch d.Type {
+	case "constant":
+		if d.Ms < 0 {
+			return fmt.Errorf("constant: ms must be >= 0")
+		}
+	case "uniform":
+		if d.MinMs < 0 || d.MaxMs < d.MinMs {
+			return fmt.Errorf("uniform: need 0 <= minMs <= maxMs")
+		}
+	case "normal":
+		if d.MeanMs < 0 || d.StddevMs < 0 {
+			return fmt.Errorf("normal: meanMs and stddevMs must be >= 0")
+		}
+	case "lognormal":
+		if d.P50Ms <= 0 || d.P99Ms < d.P50Ms {
+			return fmt.Errorf("lognormal: need 0 < p50Ms <= p99Ms")
+		}
+	case "exponential":
+		if d.MeanMs <= 0 {
+			return fmt.Errorf("exponential: meanMs must be > 0")
+		}
+	default:
+		return fmt.Errorf("unknown distribution type %q", d.Type)
+	}
+	return nil
+}
+
+// sample draws one delay from the distribution. Negative draws (possible
+// with normal) are clamped to zero.
+func (d latencyDist) sample() time.Duration {
+	var ms float64
+	switch d.Type {
+	case "constant":
+		ms = d.Ms
+	case "uniform":
+		ms = d.MinMs + rand.Float64()*(d.MaxMs-d.MinMs)
+	case "normal":
+		ms = d.MeanMs + rand.NormFloat64()*d.StddevMs
+	case "lognormal":
+		mu := math.Log(d.P50Ms)
+		sigma := math.Log(d.P99Ms/d.P50Ms) / z99
+		ms = math.Exp(mu + rand.NormFloat64()*sigma)
+	case "exponential":
+		ms = rand.ExpFloat64() * d.MeanMs
+	}
+	if d.CapMs > 0 && ms > d.CapMs {
+		ms = d.CapMs
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// withLatencyModel delays requests according to the active latency model.
+// The delay is abandoned if the client goes away.
+func withLatencyModel() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m := activeLatencyModel.Load(); m != nil {
+				if d, ok := m.Routes[r.Pattern]; ok {
+					t := time.NewTimer(d.sample())
+					select {
+					case <-t.C:
+					case <-r.Context().Done():
+						t.Stop()
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
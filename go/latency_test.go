@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadLatencyModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	model := `{"routes": {
+		"/api/info": {"type": "lognormal", "p50Ms": 20, "p99Ms": 250, "capMs": 1000},
+		"/api/kv/{key}": {"type": "uniform", "minMs": 5, "maxMs": 15}
+	}}`
+	if err := os.WriteFile(path, []byte(model), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadLatencyModel(path)
+	if err != nil {
+		t.Fatalf("loadLatencyModel returned error: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if d := m.Routes["/api/info"].sample(); d < 0 || d > time.Second {
+			t.Fatalf("lognormal sample %v outside [0, capMs]", d)
+		}
+		if d := m.Routes["/api/kv/{key}"].sample(); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("uniform sample %v outside [minMs, maxMs]", d)
+		}
+	}
+}
+
+func TestLoadLatencyModelRejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(`{"routes": {"/": {"type": "pareto"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadLatencyModel(path); err == nil {
+		t.Error("expected an error for an unknown distribution type")
+	}
+}
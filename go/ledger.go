@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The ledger is an in-memory, append-only record of notable things the app
+// did on its own or was told to do (remediations, admin actions, ...), so a
+// demo can show afterwards what happened and why. Every event published on
+// the event bus lands here. It keeps the most recent LEDGER_SIZE entries.
+type ledgerEntry struct {
+	ID      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject,omitempty"`
+	Message string    `json:"message"`
+	Details any       `json:"details,omitempty"`
+}
+
+type ledgerLog struct {
+	mu      sync.Mutex
+	entries []ledgerEntry
+	sizes   []int64 // approximate memory per entry, parallel to entries
+	bytes   int64
+	size    int
+	nextID  int64
+	// backend receives every new entry; see Store.
+	backend Store
+}
+
+var ledger = newLedger(getenvInt("LEDGER_SIZE", 500))
+
+func newLedger(size int) *ledgerLog {
+	return &ledgerLog{size: max(size, 1), backend: memoryStore{}}
+}
+
+// attach loads the newest retained entries from backend and appends to it
+// from then on. IDs continue from the last loaded entry.
+func (l *ledgerLog) attach(backend Store) error {
+	entries, err := backend.LoadLedger(l.size)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backend = backend
+	for _, e := range entries {
+		size := int64(len(e.Kind)+len(e.Subject)+len(e.Message)) + 96
+		if b, ok := e.Details.(json.RawMessage); ok {
+			size += int64(len(b))
+		}
+		l.entries = append(l.entries, e)
+		l.sizes = append(l.sizes, size)
+		l.bytes += size
+		l.nextID = max(l.nextID, e.ID)
+	}
+	l.dropOldestLocked(len(l.entries) - l.size)
+	return nil
+}
+
+func (l *ledgerLog) record(kind, subject, message string, details any) ledgerEntry {
+	size := int64(len(kind)+len(subject)+len(message)) + 96
+	if details != nil {
+		if b, err := json.Marshal(details); err == nil {
+			size += int64(len(b))
+		}
+	}
+
+	l.mu.Lock()
+	l.nextID++
+	e := ledgerEntry{ID: l.nextID, Time: time.Now().UTC(), Kind: kind, Subject: subject, Message: message, Details: details}
+	l.entries = append(l.entries, e)
+	l.sizes = append(l.sizes, size)
+	l.bytes += size
+	l.dropOldestLocked(len(l.entries) - l.size)
+	storeWrite("ledger-append", l.backend.AppendLedger(e))
+	l.mu.Unlock()
+
+	memBudget.nudge()
+	return e
+}
+
+func (l *ledgerLog) dropOldestLocked(n int) int64 {
+	n = min(n, len(l.entries))
+	if n <= 0 {
+		return 0
+	}
+	var freed int64
+	for _, s := range l.sizes[:n] {
+		freed += s
+	}
+	l.entries = append(l.entries[:0:0], l.entries[n:]...)
+	l.sizes = append(l.sizes[:0:0], l.sizes[n:]...)
+	l.bytes -= freed
+	return freed
+}
+
+func (l *ledgerLog) usage() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bytes
+}
+
+// shed drops the oldest entries until at least n bytes are freed.
+func (l *ledgerLog) shed(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	count := 0
+	for freed := int64(0); freed < n && count < len(l.sizes); count++ {
+		freed += l.sizes[count]
+	}
+	return l.dropOldestLocked(count)
+}
+
+// list returns up to limit of the newest entries (all when limit <= 0),
+// oldest first, optionally filtered by kind.
+func (l *ledgerLog) list(kind string, limit int) []ledgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := []ledgerEntry{}
+	for i := len(l.entries) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if kind == "" || l.entries[i].Kind == kind {
+			out = append(out, l.entries[i])
+		}
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// ledgerHandler serves GET /api/ledger?kind=&limit=.
+func ledgerHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	writeJSON(w, http.StatusOK, ledger.list(r.URL.Query().Get("kind"), limit))
+}
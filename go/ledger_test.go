@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withTestLedger(t *testing.T, size int) {
+	t.Helper()
+	prev := ledger
+	ledger = newLedger(size)
+	t.Cleanup(func() { ledger = prev })
+}
+
+func TestLedgerKeepsNewestEntries(t *testing.T) {
+	l := newLedger(3)
+	for i := 0; i < 5; i++ {
+		l.record("test", "", "entry", nil)
+	}
+	got := l.list("", 0)
+	if len(got) != 3 || got[0].ID != 3 || got[2].ID != 5 {
+		t.Errorf("expected entries 3..5 oldest first, got %+v", got)
+	}
+}
+
+func TestLedgerListFilters(t *testing.T) {
+	l := newLedger(10)
+	l.record("a", "", "1", nil)
+	l.record("b", "", "2", nil)
+	l.record("a", "", "3", nil)
+	l.record("a", "", "4", nil)
+
+	got := l.list("a", 2)
+	if len(got) != 2 || got[0].Message != "3" || got[1].Message != "4" {
+		t.Errorf("expected the two newest kind=a entries, got %+v", got)
+	}
+}
+
+func TestLedgerHandler(t *testing.T) {
+	withTestLedger(t, 10)
+	ledger.record("self-heal", "db", "reconnect", nil)
+
+	req := httptest.NewRequest("GET", "/api/ledger?kind=self-heal", nil)
+	rr := httptest.NewRecorder()
+	ledgerHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var entries []ledgerEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Subject != "db" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	rr = httptest.NewRecorder()
+	ledgerHandler(rr, httptest.NewRequest("GET", "/api/ledger?limit=-1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
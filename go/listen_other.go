@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func listenReusePort(addr string) (net.Listener, error) {
+	return nil, errors.New("worker mode requires SO_REUSEPORT, which is not supported on this platform")
+}
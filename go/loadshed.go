@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Load shedding. MAX_CONCURRENT_REQUESTS caps the requests served at once
+// across all routes (0, the default, means no cap). Unlike the per-route
+// limits, nothing queues: a request arriving at the cap is answered
+// straight away with 503 and Retry-After (SHED_RETRY_AFTER seconds,
+// default 1) and counted in requests_shed_total, so an overloaded instance
+// degrades by refusing work quickly rather than by slowing everyone down.
+// Probes are never shed, so an overloaded pod isn't also restarted.
+var loadShedder = newShedder(getenvInt("MAX_CONCURRENT_REQUESTS", 0), getenvInt("SHED_RETRY_AFTER", 1))
+
+type shedder struct {
+	// sem is nil when there is no cap.
+	sem        chan struct{}
+	retryAfter string
+}
+
+func newShedder(limit, retryAfter int) *shedder {
+	s := &shedder{retryAfter: strconv.Itoa(max(retryAfter, 1))}
+	if limit > 0 {
+		s.sem = make(chan struct{}, limit)
+	}
+	return s
+}
+
+func withLoadShedding() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s := loadShedder
+			if s.sem == nil || isProbePath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			select {
+			case s.sem <- struct{}{}:
+				defer func() { <-s.sem }()
+				next.ServeHTTP(w, r)
+			default:
+				requestsShed.WithLabelValues(routeLabel(r)).Inc()
+				w.Header().Set("Retry-After", s.retryAfter)
+				writeError(w, http.StatusServiceUnavailable, "server overloaded, request shed")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLoadSheddingRejectsOverCap(t *testing.T) {
+	prev := loadShedder
+	loadShedder = newShedder(1, 5)
+	t.Cleanup(func() { loadShedder = prev })
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/api/busy", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("hold") {
+			close(entered)
+			<-release
+		}
+	}), withLoadShedding()))
+	mux.Handle("/healthz", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withLoadShedding()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/busy?hold", nil))
+	}()
+	<-entered
+
+	before := testutil.ToFloat64(requestsShed.WithLabelValues("/api/busy"))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/busy", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") != "5" {
+		t.Errorf("over the cap: status %d Retry-After %q, want 503 and 5", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(requestsShed.WithLabelValues("/api/busy")); got != before+1 {
+		t.Errorf("requests_shed_total grew by %v, want 1", got-before)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("probe was shed: status %d", rr.Code)
+	}
+
+	close(release)
+	<-done
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/busy", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("after the slot freed: status %d, want 200", rr.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The log generator emits synthetic structured logs at a fixed byte rate so
+// log-pipeline capacity and cost demos can be driven with known volumes.
+// Rates are in MB/s (10^6 bytes). Every generated line carries
+// "loggen":true so it is easy to filter out downstream. LOGGEN_MB_PER_SEC
+// starts it at boot (for LOGGEN_DURATION, default until stopped); otherwise
+// it is driven through /api/admin/loggen.
+var (
+	loggen         = &logGenerator{out: os.Stdout}
+	loggenMaxMBps  = float64(getenvInt("LOGGEN_MAX_MB_PER_SEC", 50))
+	loggenTickRate = 10 * time.Millisecond
+)
+
+type logGenerator struct {
+	out io.Writer
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	run    *loggenRun
+}
+
+type loggenRun struct {
+	MBPerSec  float64    `json:"mbPerSec"`
+	StartedAt time.Time  `json:"startedAt"`
+	Until     *time.Time `json:"until,omitempty"`
+	Bytes     int64      `json:"bytes"`
+	Lines     int64      `json:"lines"`
+	Running   bool       `json:"running"`
+
+	bytes atomic.Int64
+	lines atomic.Int64
+	done  atomic.Bool
+}
+
+func startLogGenFromEnv() {
+	v := getenv("LOGGEN_MB_PER_SEC", "")
+	if v == "" {
+		return
+	}
+	mbps, err := strconv.ParseFloat(v, 64)
+	if err != nil || mbps <= 0 || mbps > loggenMaxMBps {
+		logger.Warn("invalid LOGGEN_MB_PER_SEC, log generator not started", "value", v, "max", loggenMaxMBps)
+		return
+	}
+	dur := getenvDuration("LOGGEN_DURATION", 0)
+	loggen.start(mbps, dur)
+	logger.Info("log generator started", "mbPerSec", mbps, "duration", dur.String())
+}
+
+// start replaces any running generation with one at mbps for dur (zero
+// means until stopped).
+func (g *logGenerator) start(mbps float64, dur time.Duration) *loggenRun {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel != nil {
+		g.cancel()
+	}
+
+	run := &loggenRun{MBPerSec: mbps, StartedAt: time.Now()}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if dur > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), dur)
+		until := run.StartedAt.Add(dur)
+		run.Until = &until
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	g.cancel, g.run = cancel, run
+	go g.generate(ctx, run)
+	return run
+}
+
+func (g *logGenerator) stop() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cancel == nil {
+		return false
+	}
+	g.cancel()
+	g.cancel = nil
+	return true
+}
+
+func (g *logGenerator) status() *loggenRun {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.run == nil {
+		return &loggenRun{}
+	}
+	snap := &loggenRun{
+		MBPerSec:  g.run.MBPerSec,
+		StartedAt: g.run.StartedAt,
+		Until:     g.run.Until,
+		Bytes:     g.run.bytes.Load(),
+		Lines:     g.run.lines.Load(),
+		Running:   !g.run.done.Load(),
+	}
+	return snap
+}
+
+// generate paces output against wall-clock time: on every tick it writes
+// lines until the bytes written catch up with rate × elapsed.
+func (g *logGenerator) generate(ctx context.Context, run *loggenRun) {
+	defer run.done.Store(true)
+	cw := &countingWriter{w: g.out}
+	l := slog.New(slog.NewJSONHandler(cw, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	rate := run.MBPerSec * 1e6
+
+	t := time.NewTicker(loggenTickRate)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		target := int64(rate * time.Since(run.StartedAt).Seconds())
+		for cw.n.Load() < target && ctx.Err() == nil {
+			before := cw.n.Load()
+			emitSyntheticLog(l)
+			n := cw.n.Load() - before
+			run.bytes.Add(n)
+			run.lines.Add(1)
+			loggenBytes.Add(float64(n))
+			loggenLines.Inc()
+		}
+	}
+}
+
+var (
+	loggenPaths   = []string{"/api/orders", "/api/cart", "/api/users/{id}", "/api/search", "/checkout", "/healthz"}
+	loggenMethods = []string{"GET", "GET", "GET", "POST", "PUT", "DELETE"}
+	loggenRegions = []string{"us-east-1", "us-west-2", "eu-west-1", "ap-southeast-2"}
+	loggenAgents  = []string{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5)", "curl/8.7.1", "okhttp/4.12.0", "kube-probe/1.30"}
+	loggenErrors  = []string{"context deadline exceeded", "connection reset by peer", "upstream returned 503", "invalid argument: quantity must be positive"}
+)
+
+// emitSyntheticLog writes one access-log-like record with a realistic mix of
+// levels, statuses and field shapes.
+func emitSyntheticLog(l *slog.Logger) {
+	status := 200
+	switch p := rand.Float64(); {
+	case p < 0.02:
+		status = 500 + rand.IntN(4)
+	case p < 0.08:
+		status = 400 + rand.IntN(5)
+	case p < 0.15:
+		status = 201
+	}
+	attrs := []any{
+		"loggen", true,
+		"method", loggenMethods[rand.IntN(len(loggenMethods))],
+		"path", loggenPaths[rand.IntN(len(loggenPaths))],
+		"status", status,
+		"dur_ms", int(rand.ExpFloat64() * 40),
+		"bytes", rand.IntN(64 << 10),
+		"user_id", fmt.Sprintf("u-%06d", rand.IntN(1_000_000)),
+		"trace_id", fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()),
+		"region", loggenRegions[rand.IntN(len(loggenRegions))],
+		"user_agent", loggenAgents[rand.IntN(len(loggenAgents))],
+	}
+	switch {
+	case status >= 500:
+		l.Error("request failed", append(attrs, "err", loggenErrors[rand.IntN(len(loggenErrors))])...)
+	case status >= 400:
+		l.Warn("request rejected", attrs...)
+	case rand.IntN(10) == 0:
+		l.Debug("cache lookup", append(attrs, "cache_hit", rand.IntN(2) == 0)...)
+	default:
+		l.Info("request", attrs...)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// loggenHandler controls the generator: GET reports status, PUT starts it
+// with {"mbPerSec": 2, "duration": "5m"}, DELETE stops it.
+func loggenHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, loggen.status())
+
+	case http.MethodPut:
+		var req struct {
+			MBPerSec float64 `json:"mbPerSec"`
+			Duration string  `json:"duration"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if req.MBPerSec <= 0 || req.MBPerSec > loggenMaxMBps {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("mbPerSec must be in (0, %g]", loggenMaxMBps))
+			return
+		}
+		var dur time.Duration
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d < 0 {
+				writeError(w, http.StatusBadRequest, "duration must be a non-negative duration")
+				return
+			}
+			dur = d
+		}
+		loggen.start(req.MBPerSec, dur)
+		loggerFrom(r.Context()).Info("log generator started", "mbPerSec", req.MBPerSec, "duration", dur.String())
+		writeJSON(w, http.StatusAccepted, loggen.status())
+
+	case http.MethodDelete:
+		if loggen.stop() {
+			loggerFrom(r.Context()).Info("log generator stopped")
+		}
+		writeJSON(w, http.StatusOK, loggen.status())
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
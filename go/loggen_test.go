@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (l *lockedBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *lockedBuffer) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.String()
+}
+
+func TestLogGeneratorRate(t *testing.T) {
+	buf := &lockedBuffer{}
+	g := &logGenerator{out: buf}
+	g.start(1, 300*time.Millisecond) // 1 MB/s for 0.3s ≈ 300 KB
+	time.Sleep(500 * time.Millisecond)
+
+	st := g.status()
+	if st.Running {
+		t.Fatal("generator still running after its duration")
+	}
+	if st.Bytes < 250_000 || st.Bytes > 350_000 {
+		t.Errorf("generated %d bytes, want ~300000", st.Bytes)
+	}
+	out := buf.String()
+	if int64(len(out)) != st.Bytes {
+		t.Errorf("status reports %d bytes, writer got %d", st.Bytes, len(out))
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	lines := int64(0)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %d is not JSON: %v", lines, err)
+		}
+		if rec["loggen"] != true {
+			t.Fatalf("line %d missing loggen marker: %s", lines, sc.Text())
+		}
+		lines++
+	}
+	if lines != st.Lines {
+		t.Errorf("status reports %d lines, output has %d", st.Lines, lines)
+	}
+}
+
+func TestLogGeneratorStop(t *testing.T) {
+	g := &logGenerator{out: &lockedBuffer{}}
+	if g.stop() {
+		t.Error("stop reported a running generator before start")
+	}
+	g.start(0.1, 0)
+	if !g.stop() {
+		t.Error("stop did not report the running generator")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if g.status().Running {
+		t.Error("generator still running after stop")
+	}
+}
+
+func TestLogGeneratorRestartStopsPreviousRun(t *testing.T) {
+	g := &logGenerator{out: &lockedBuffer{}}
+	first := g.start(0.1, time.Hour)
+	g.start(0.1, 0)
+	defer g.stop()
+	time.Sleep(50 * time.Millisecond)
+	if !first.done.Load() {
+		t.Error("first run still generating after a restart")
+	}
+}
+
+func TestLoggenHandlerValidation(t *testing.T) {
+	for _, body := range []string{`{"mbPerSec":0}`, `{"mbPerSec":1000}`, `{"mbPerSec":1,"duration":"soon"}`, `{`} {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/loggen", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		loggenHandler(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("body %s: handler returned wrong status code: got %v want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log format. LOG_FORMAT picks the handler for the app logger:
+//
+//	json:   one JSON object per line (the default)
+//	text:   slog's key=value text format, easier to read locally
+//	logfmt: key=value with the conventional logfmt keys ts, level
+//	        (lowercase) and msg, for aggregators that parse logfmt
+var logFormat = getenv("LOG_FORMAT", "json")
+
+func newLogger(w io.Writer) *slog.Logger {
+	h, _ := newLogHandler(w, logFormat, logLevel)
+	return slog.New(traceLogHandler{h})
+}
+
+// File logging. With LOG_FILE set, logs are also written to that file for
+// VM and bare-metal installs where nothing collects stdout. The file is
+// rotated once it reaches LOG_FILE_MAX_SIZE_MB (default 100); rotated files
+// are gzipped when LOG_FILE_COMPRESS is set and deleted once older than
+// LOG_FILE_MAX_AGE_DAYS or beyond the newest LOG_FILE_MAX_BACKUPS (zero
+// keeps them all). LOG_STDOUT=false stops writing to stdout. The Apache
+// access log formats and the audit stream go to the same output.
+var logOutput io.Writer = os.Stdout
+
+func logFileOutput() io.Writer {
+	path := getenv("LOG_FILE", "")
+	if path == "" {
+		return nil
+	}
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    getenvInt("LOG_FILE_MAX_SIZE_MB", 100),
+		MaxAge:     getenvInt("LOG_FILE_MAX_AGE_DAYS", 0),
+		MaxBackups: getenvInt("LOG_FILE_MAX_BACKUPS", 0),
+		Compress:   getenvBool("LOG_FILE_COMPRESS", false),
+	}
+	if !getenvBool("LOG_STDOUT", true) {
+		return file
+	}
+	return io.MultiWriter(os.Stdout, file)
+}
+
+// traceLogHandler adds trace_id and span_id to records logged with a
+// context that carries a span, so log lines written while serving a
+// request can be joined with its trace.
+type traceLogHandler struct{ slog.Handler }
+
+func (h traceLogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h traceLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceLogHandler) WithGroup(name string) slog.Handler {
+	return traceLogHandler{h.Handler.WithGroup(name)}
+}
+
+// newLogHandler returns the handler for format, or a JSON handler and false
+// if format is unknown.
+func newLogHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, bool) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.NewJSONHandler(w, opts), true
+	case "text":
+		return slog.NewTextHandler(w, opts), true
+	case "logfmt":
+		opts.ReplaceAttr = logfmtAttr
+		return slog.NewTextHandler(w, opts), true
+	}
+	return slog.NewJSONHandler(w, opts), false
+}
+
+func logfmtAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	switch a.Key {
+	case slog.TimeKey:
+		a.Key = "ts"
+	case slog.LevelKey:
+		a.Value = slog.StringValue(strings.ToLower(a.Value.String()))
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// withRequestLogger stores a logger carrying the request's ID, route and
+// method in its context, for loggerFrom.
+func withRequestLogger(r *http.Request) *http.Request {
+	l := slog.New(requestLogHandler{logger.Handler(), r.Context()}).With(
+		"request_id", requestIDFrom(r.Context()),
+		"route", routeLabel(r),
+		"method", r.Method,
+	)
+	return r.WithContext(context.WithValue(r.Context(), loggerKey{}, l))
+}
+
+// loggerFrom returns the request-scoped logger set by withLogging, so every
+// line logged while serving a request shares its attributes, or the app
+// logger outside a request.
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return logger
+}
+
+// requestLogHandler hands the request's context to the handler for records
+// logged without one, so they still get trace IDs.
+type requestLogHandler struct {
+	slog.Handler
+	ctx context.Context
+}
+
+func (h requestLogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = h.ctx
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h requestLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestLogHandler{h.Handler.WithAttrs(attrs), h.ctx}
+}
+
+func (h requestLogHandler) WithGroup(name string) slog.Handler {
+	return requestLogHandler{h.Handler.WithGroup(name), h.ctx}
+}
+
+// Log level. LOG_LEVEL (debug, info, warn or error; default info) sets the
+// level at startup, and PUT /api/admin/loglevel {"level":"debug"} changes it
+// on a running instance so verbose logging can be switched on during an
+// incident and back off afterwards. The level is held in a slog.LevelVar
+// shared by the app's handlers, so a change applies immediately.
+const eventLogLevel eventType = "log-level"
+
+func init() {
+	if w := logFileOutput(); w != nil {
+		logOutput = w
+		logger = newLogger(w)
+	}
+	accessLogOut = logOutput
+	auditLogger = newAuditLogger(getenv("AUDIT_LOG_FILE", ""))
+	if _, ok := newLogHandler(io.Discard, logFormat, nil); !ok {
+		invalidConfig("LOG_FORMAT", fmt.Errorf("%q is not json, text or logfmt", logFormat))
+	}
+	if v := getenv("LOG_LEVEL", ""); v != "" {
+		if err := logLevel.UnmarshalText([]byte(v)); err != nil {
+			invalidConfig("LOG_LEVEL", err)
+		}
+	}
+}
+
+// loglevelHandler reports the level on GET and sets it on PUT.
+func loglevelHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		prev := logLevel.Level()
+		if err := logLevel.UnmarshalText([]byte(strings.TrimSpace(req.Level))); err != nil {
+			writeError(w, http.StatusBadRequest, "level must be debug, info, warn or error")
+			return
+		}
+		by := adminPrincipal(r.Context())
+		loggerFrom(r.Context()).Warn("log level changed", "from", prev.String(), "to", logLevel.Level().String(), "by", by)
+		events.publish(event{Type: eventLogLevel, Message: "log level set to " + logLevel.Level().String(), Data: map[string]any{"from": prev.String(), "by": by}})
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"level": logLevel.Level().String()})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestLoglevelHandler(t *testing.T) {
+	withTestLedger(t, 10)
+	prev := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(prev) })
+	logLevel.Set(slog.LevelInfo)
+
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("debug enabled at info level")
+	}
+	rr := httptest.NewRecorder()
+	loglevelHandler(rr, httptest.NewRequest(http.MethodPut, "/api/admin/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"DEBUG"`) {
+		t.Fatalf("PUT: status = %d: %s", rr.Code, rr.Body)
+	}
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("debug not enabled after PUT")
+	}
+	if got := ledger.list(string(eventLogLevel), 0); len(got) != 1 {
+		t.Errorf("ledger has %d log-level entries, want 1", len(got))
+	}
+
+	rr = httptest.NewRecorder()
+	loglevelHandler(rr, httptest.NewRequest(http.MethodPut, "/api/admin/loglevel", strings.NewReader(`{"level":"loud"}`)))
+	if rr.Code != http.StatusBadRequest || logLevel.Level() != slog.LevelDebug {
+		t.Errorf("invalid level: status = %d, level = %v", rr.Code, logLevel.Level())
+	}
+
+	rr = httptest.NewRecorder()
+	loglevelHandler(rr, httptest.NewRequest(http.MethodGet, "/api/admin/loglevel", nil))
+	if !strings.Contains(rr.Body.String(), `"DEBUG"`) {
+		t.Errorf("GET = %s", rr.Body)
+	}
+}
+
+func TestNewLogHandler(t *testing.T) {
+	for format, want := range map[string]string{
+		"json":   `{"time":`,
+		"text":   `level=INFO msg=hello k=v`,
+		"logfmt": `level=info msg=hello k=v`,
+		"bogus":  `"msg":"hello"`,
+	} {
+		var buf strings.Builder
+		h, ok := newLogHandler(&buf, format, slog.LevelInfo)
+		if ok != (format != "bogus") {
+			t.Errorf("%s: ok = %v", format, ok)
+		}
+		l := slog.New(h)
+		l.Debug("hidden")
+		l.Info("hello", "k", "v")
+		if out := buf.String(); !strings.Contains(out, want) || strings.Contains(out, "hidden") {
+			t.Errorf("%s: output %q, want it to contain %q", format, out, want)
+		}
+		if format == "logfmt" && !strings.HasPrefix(buf.String(), "ts=") {
+			t.Errorf("logfmt output %q should start with ts=", buf.String())
+		}
+	}
+}
+
+func TestTraceLogHandler(t *testing.T) {
+	withTestTracer(t)
+	var buf strings.Builder
+	l := slog.New(traceLogHandler{slog.NewJSONHandler(&buf, nil)}).With("k", "v")
+
+	l.InfoContext(context.Background(), "outside")
+	if strings.Contains(buf.String(), "trace_id") {
+		t.Errorf("record without a span has trace fields: %s", buf.String())
+	}
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+	buf.Reset()
+	l.InfoContext(ctx, "inside")
+	sc := span.SpanContext()
+	for _, want := range []string{`"trace_id":"` + sc.TraceID().String() + `"`, `"span_id":"` + sc.SpanID().String() + `"`, `"k":"v"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("record %s missing %s", buf.String(), want)
+		}
+	}
+}
+
+func TestLoggerFrom(t *testing.T) {
+	withTestTracer(t)
+	prevLogger, prevDefault := logger, slog.Default()
+	t.Cleanup(func() { logger = prevLogger; slog.SetDefault(prevDefault) })
+	var buf strings.Builder
+	logger = slog.New(traceLogHandler{slog.NewJSONHandler(&buf, nil)})
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+
+	if loggerFrom(context.Background()) != logger {
+		t.Error("loggerFrom outside a request should return the app logger")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/things/{id}", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loggerFrom(r.Context()).Info("handled")
+	}), withRequestID(), withTracing(), withLogging()))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/things/7", nil))
+
+	for _, want := range []string{
+		`"request_id":"` + rr.Header().Get(requestIDHeader) + `"`,
+		`"route":"POST /api/things/{id}"`,
+		`"method":"POST"`,
+		`"trace_id":"` + rr.Header().Get("X-Trace-Id") + `"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("handler log %s missing %s", buf.String(), want)
+		}
+	}
+}
+
+func TestLogFileOutput(t *testing.T) {
+	t.Setenv("LOG_FILE", "")
+	if logFileOutput() != nil {
+		t.Error("no LOG_FILE: want stdout only")
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	t.Setenv("LOG_FILE", path)
+	t.Setenv("LOG_STDOUT", "false")
+	w := logFileOutput()
+	if c, ok := w.(io.Closer); ok {
+		defer c.Close()
+	}
+	newLogger(w).Info("to file", "k", "v")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "to file") {
+		t.Errorf("log file = %q", b)
+	}
+}
@@ -5,18 +5,23 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
-
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Embed everything under static/
@@ -29,32 +34,148 @@ var embeddedFS embed.FS
 //go:embed static/index.html
 var indexHTML []byte
 
+const appName = "Harness Demo App"
+
 type AppInfo struct {
-	Name        string `json:"name"`
-	Version     string `json:"version"`
-	Environment string `json:"environment"`
-	BuildTime   string `json:"buildTime"`
-	Uptime      string `json:"uptime"`
-	Hostname    string `json:"hostname"`
+	Name        string          `json:"name"`
+	Version     string          `json:"version"`
+	Environment string          `json:"environment"`
+	BuildTime   string          `json:"buildTime"`
+	Uptime      string          `json:"uptime"`
+	Hostname    string          `json:"hostname"`
+	Database    *dbInfo         `json:"database,omitempty"`
+	Replica     replicaIdentity `json:"replica"`
+	// AsOf is when the cached fields above were gathered.
+	AsOf string `json:"asOf"`
 }
 
 var (
-	startTime  = time.Now()
-	version    = getenv("APP_VERSION", "1.0.0")
-	env        = getenv("APP_ENV", "development")
-	buildTime  = os.Getenv("BUILD_TIME") // optionally set via ldflags
-	readyAfter = 2 * time.Second         // small warm-up for readiness
-	logger     = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	startTime    = time.Now()
+	version      = getenv("APP_VERSION", "1.0.0")
+	env          = getenv("APP_ENV", "development")
+	buildTime    = os.Getenv("BUILD_TIME") // optionally set via ldflags
+	readyAfter   = 2 * time.Second         // small warm-up before startup completes
+	prestopDelay = getenvDuration("PRESTOP_DELAY", 5*time.Second)
+	logLevel     = new(slog.LevelVar) // see logging.go
+	logger       = newLogger(os.Stdout)
 )
 
+// configKeys records every environment variable the app has looked up, so
+// the effective configuration can be fingerprinted.
+var configKeys sync.Map
+
+// lookupConfig reads k from the environment and records that it was read.
+func lookupConfig(k string) string {
+	configKeys.Store(k, struct{}{})
+	return os.Getenv(k)
+}
+
+// invalidConfig stops startup over a setting that doesn't parse. Settings
+// with a syntax of their own (lists, per-route maps, policies and named
+// choices) all go through here: ignoring one would quietly drop a limit or,
+// for TRUSTED_PROXIES and the security headers, change who is trusted and
+// what browsers are told. Plain numbers, booleans and durations read with
+// the getenv helpers below fall back to their defaults with a warning.
+func invalidConfig(key string, err error) {
+	log.Fatalf("invalid %s: %v", key, err)
+}
+
 func getenv(k, def string) string {
-	if v := os.Getenv(k); v != "" {
+	if v := lookupConfig(k); v != "" {
 		return v
 	}
 	return def
 }
 
+func getenvBool(k string, def bool) bool {
+	v := lookupConfig(k)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		logger.Warn("invalid boolean in environment, using default", "key", k, "value", v, "default", def)
+		return def
+	}
+	return b
+}
+
+func getenvInt(k string, def int) int {
+	v := lookupConfig(k)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Warn("invalid integer in environment, using default", "key", k, "value", v, "default", def)
+		return def
+	}
+	return n
+}
+
+func getenvFloat(k string, def float64) float64 {
+	v := lookupConfig(k)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		logger.Warn("invalid number in environment, using default", "key", k, "value", v, "default", def)
+		return def
+	}
+	return f
+}
+
+func getenvDuration(k string, def time.Duration) time.Duration {
+	v := lookupConfig(k)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("invalid duration in environment, using default", "key", k, "value", v, "default", def)
+		return def
+	}
+	return d
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "inspect":
+			err := runJob("inspect", newJobRegistry(), func() error { return runInspect(os.Args[2:], os.Stdout) })
+			if err != nil {
+				log.Fatalf("inspect failed: %v", err)
+			}
+			return
+		case "demo":
+			err := runJob("demo", newJobRegistry(demoSteps, demoLoadRequests), func() error { return runDemo(os.Args[2:], os.Stdout) })
+			if err != nil {
+				log.Fatalf("demo failed: %v", err)
+			}
+			return
+		}
+	}
+
+	workers := flag.Int("workers", 0, "run N worker processes sharing the listener (SO_REUSEPORT) under a supervisor")
+	flag.Parse()
+	if *workers > 0 && workerID == "" {
+		if err := runSupervisor(*workers); err != nil {
+			log.Fatalf("supervisor failed: %v", err)
+		}
+		return
+	}
+	otelLogsShutdown, err := startOTelLogs(context.Background())
+	if err != nil {
+		log.Fatalf("failed to configure OTLP log export: %v", err)
+	}
+	if workerID != "" {
+		logger = logger.With("worker", workerID)
+	}
+	// Access logs and other slog.Default users share the app logger's
+	// format, level and trace correlation.
+	slog.SetDefault(logger)
+
 	port := getenv("PORT", "8080")
 
 	// Serve /static/* from the embedded filesystem (rooted at "static")
@@ -62,37 +183,264 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to sub FS: %v", err)
 	}
-	staticHandler := http.FileServer(http.FS(sub))
+	staticHandler := chain(http.FileServer(http.FS(sub)), withStaticMetrics(sub))
+
+	if path := getenv("LATENCY_MODEL_FILE", ""); path != "" {
+		m, err := loadLatencyModel(path)
+		if err != nil {
+			log.Fatalf("failed to load latency model: %v", err)
+		}
+		activeLatencyModel.Store(m)
+		logger.Info("latency model loaded", "path", path, "routes", len(m.Routes))
+	}
+
+	if spec := getenv("DEPENDENCY_URLS", ""); spec != "" {
+		if err := registerDependencies(spec, getenvDuration("DEPENDENCY_TIMEOUT", 2*time.Second)); err != nil {
+			invalidConfig("DEPENDENCY_URLS", err)
+		}
+	}
+
+	if dsn := getenv("DB_DSN", ""); dsn != "" {
+		if err := openDB(dsn, metricsRegistry); err != nil {
+			log.Fatalf("failed to configure database: %v", err)
+		}
+		defer appDB.Close()
+	}
+
+	store, err := openStore(getenv("STORE_BACKEND", "memory"))
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	defer store.Close()
+	if err := kv.attach(store); err != nil {
+		log.Fatalf("failed to load KV from store: %v", err)
+	}
+	if err := ledger.attach(store); err != nil {
+		log.Fatalf("failed to load ledger from store: %v", err)
+	}
+
+	if appChangelog, err = loadChangelog(getenv("CHANGELOG_FILE", "")); err != nil {
+		log.Fatalf("failed to load changelog: %v", err)
+	}
+	previousVersion = recordDeploy(version)
+
+	if addr := getenv("REDIS_ADDR", ""); addr != "" {
+		registerRedis(addr)
+	}
+
+	if host := getenv("DNS_CHECK_HOST", ""); host != "" {
+		registerDNSCheck(host)
+	}
+
+	if path := getenv("DISK_CHECK_PATH", ""); path != "" {
+		if err := registerDiskCheck(path); err != nil {
+			invalidConfig("DISK_CHECK_MODE", err)
+		}
+	}
+
+	if err := startStatsDFromEnv(); err != nil {
+		log.Fatalf("failed to configure StatsD export: %v", err)
+	}
+
+	otelMetricsShutdown, err := startOTelMetrics(context.Background())
+	if err != nil {
+		log.Fatalf("failed to configure OTLP metrics export: %v", err)
+	}
+	otelTracingShutdown, err := startOTelTracing(context.Background())
+	if err != nil {
+		log.Fatalf("failed to configure OTLP trace export: %v", err)
+	}
 
-	mux := http.NewServeMux()
+	if spec := getenv("SELF_HEAL", ""); spec != "" {
+		if err := registerSelfHeal(spec); err != nil {
+			invalidConfig("SELF_HEAL", err)
+		}
+	}
+
+	ipRules, err := parseIPAccessRules(getenv("IP_ACCESS_RULES", ""))
+	if err != nil {
+		invalidConfig("IP_ACCESS_RULES", err)
+	}
+
+	mux := newRouteMux()
+	// routeMiddleware is the standard stack in front of every application
+	// route, outermost first.
+	routeMiddleware := []namedMiddleware{
+		{"requestID", withRequestID()},
+		{"tracing", withTracing()},
+		{"securityHeaders", withSecurityHeaders()},
+		{"sni", withSNI()},
+		{"logging", withLogging()},
+		{"metrics", withMetrics()},
+		{"audit", withAudit()},
+		{"recovery", withRecovery()},
+		{"allowedHosts", withAllowedHosts()},
+		{"ipAccess", withIPAccess(ipRules)},
+		{"methods", withAllowedMethods(mux)},
+		{"head", withHead()},
+		{"rateLimit", withRateLimit()},
+		{"loadShedding", withLoadShedding()},
+		{"bodyLimit", withBodyLimit()},
+		{"timeout", withTimeout()},
+		{"csrf", withCSRF()},
+		{"slowRequests", withSlowRequests()},
+		{"routeConcurrency", withRouteConcurrency()},
+		{"headerAnomalies", withHeaderAnomalies()},
+		{"readOnly", withReadOnly()},
+		{"budget", withBudget()},
+		{"maintenance", withMaintenance()},
+		{"trafficPause", withTrafficPause()},
+		{"syntheticFaults", withSyntheticFaults()},
+		{"latencyModel", withLatencyModel()},
+	}
+	// handle mounts an application route serving methods behind the
+	// standard middleware.
+	handle := func(pattern string, h http.Handler, methods ...string) {
+		for i := len(routeMiddleware) - 1; i >= 0; i-- {
+			h = routeMiddleware[i].wrap(h)
+		}
+		mux.HandleMethods(pattern, h, methods...)
+	}
 	mux.Handle("/static/", http.StripPrefix("/static/", staticHandler))
-	mux.Handle("/", chain(http.HandlerFunc(homeHandler), withSecurityHeaders(), withLogging()))
-	mux.Handle("/api/info", chain(http.HandlerFunc(infoHandler), withSecurityHeaders(), withLogging()))
-	mux.Handle("/health", chain(http.HandlerFunc(healthHandler), withSecurityHeaders(), withLogging()))
-	mux.Handle("/live", chain(http.HandlerFunc(liveHandler), withSecurityHeaders(), withLogging()))
-	mux.Handle("/ready", chain(http.HandlerFunc(readyHandler), withSecurityHeaders(), withLogging()))
-	mux.Handle("/metrics", promhttp.Handler())
+	handle("/", http.HandlerFunc(homeHandler), http.MethodGet)
+	handle("/api/info", http.HandlerFunc(infoHandler), http.MethodGet)
+	handle("/api/ip", http.HandlerFunc(ipHandler), http.MethodGet)
+	handle("/healthz", probeHandler(probeAll, "healthy"), http.MethodGet)
+	handle("/livez", probeHandler(probeLive, "alive"), http.MethodGet)
+	handle("/readyz", probeHandler(probeReady, "ready"), http.MethodGet)
+	handle("/startupz", http.HandlerFunc(startupHandler), http.MethodGet)
+	// Legacy probe paths, kept for existing manifests.
+	handle("/health", probeHandler(probeAll, "healthy"), http.MethodGet)
+	handle("/live", probeHandler(probeLive, "alive"), http.MethodGet)
+	handle("/ready", probeHandler(probeReady, "ready"), http.MethodGet)
+	handle("/api/health/history", http.HandlerFunc(healthHistoryHandler), http.MethodGet)
+	handle("/api/qr", http.HandlerFunc(qrHandler), http.MethodGet)
+	handle("/api/budgets", http.HandlerFunc(budgetsHandler), http.MethodGet)
+	handle("/api/kv/{key}", http.HandlerFunc(kvHandler), http.MethodGet, http.MethodPut, http.MethodDelete)
+	handle("/api/csrf", http.HandlerFunc(csrfHandler), http.MethodGet)
+	handle("/api/ledger", http.HandlerFunc(ledgerHandler), http.MethodGet)
+	handle("/api/degradation", http.HandlerFunc(degradationHandler), http.MethodGet)
+	handle("/api/evidence", chain(http.HandlerFunc(evidenceHandler), withDebugAccess(debugAccess)), http.MethodGet)
+	handle("/api/changelog", http.HandlerFunc(changelogHandler), http.MethodGet)
+	handle("/api/metrics/summary", chain(http.HandlerFunc(metricsSummaryHandler), withDebugAccess(debugAccess)), http.MethodGet)
+	handle("/api/stats", chain(http.HandlerFunc(statsHandler), withDebugAccess(debugAccess)), http.MethodGet)
+	handle("/api/stats/clients", chain(http.HandlerFunc(clientStatsHandler), withDebugAccess(debugAccess)), http.MethodGet)
+	handle("/api/runtime", chain(http.HandlerFunc(runtimeHandler), withDebugAccess(debugAccess)), http.MethodGet)
+	handle("/api/memory-budget", http.HandlerFunc(memoryBudgetHandler), http.MethodGet)
+	handle("/api/shard", http.HandlerFunc(shardHandler), http.MethodGet)
+	handle("/api/shard/ring", chain(http.HandlerFunc(shardRingHandler), withAdminAuthForWrites()), http.MethodGet, http.MethodPut)
+	handle("/api/webhooks", http.HandlerFunc(webhookHandler), http.MethodPost)
+	handle("/api/admin/session", chain(http.HandlerFunc(sessionHandler), withAdminAuth()), http.MethodPost)
+	handle("/api/admin/secrets", chain(http.HandlerFunc(secretsHandler), withAdminAuth()), http.MethodGet)
+	handle("/api/admin/secrets/{name}/rotate", chain(http.HandlerFunc(rotateSecretHandler), withAdminAuth()), http.MethodPost)
+	handle("/api/admin/pause-traffic", chain(http.HandlerFunc(pauseTrafficHandler), withAdminAuth()), http.MethodGet, http.MethodPost, http.MethodDelete)
+	handle("/api/admin/loggen", chain(http.HandlerFunc(loggenHandler), withAdminAuth()), http.MethodGet, http.MethodPut, http.MethodDelete)
+	handle("/api/admin/synthetic", chain(http.HandlerFunc(syntheticHandler), withAdminAuth()), http.MethodGet, http.MethodPut, http.MethodDelete)
+	handle("/api/admin/events", chain(http.HandlerFunc(annotationHandler), withAdminAuth()), http.MethodPost)
+	handle("/api/admin/loglevel", chain(http.HandlerFunc(loglevelHandler), withAdminAuth()), http.MethodGet, http.MethodPut)
+	mux.Handle("/metrics", chain(metricsHandler(metricsRegistry), withDebugAccess(debugAccess)))
+	debugHandler := newDebugHandler()
+	debugShutdown := func(context.Context) error { return nil }
+	if debugAddr != "" {
+		debugShutdown = startDebugServer(debugHandler)
+	} else {
+		mux.Handle("/debug/", debugHandler)
+	}
+
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	go kv.janitor(janitorCtx, 30*time.Second)
+	go appInfoCache.run()
+	go memBudget.run(5 * time.Second)
+	time.AfterFunc(readyAfter, func() { startup.complete("warmup") })
+	watchReadinessSignal()
+	startLogGenFromEnv()
+	startSyntheticFromEnv()
+
+	responseHeaders, err := parseHeaderList(getenv("RESPONSE_HEADERS", ""))
+	if err != nil {
+		invalidConfig("RESPONSE_HEADERS", err)
+	}
 
 	srv := &http.Server{
 		Addr:              ":" + port,
-		Handler:           mux,
+		Handler:           chain(mux, withCompression(compression), withReplicaHeaders(), withJourney(), withResponseHeaders(responseHeaders)),
 		ReadHeaderTimeout: 5 * time.Second,
+		ConnState:         conns.track,
+	}
+	if tlsEnabled() {
+		r, err := loadSNIRouter(tlsCertFile, tlsKeyFile, tlsSNIConfig)
+		if err != nil {
+			log.Fatalf("failed to configure TLS: %v", err)
+		}
+		sni = r
+		srv.TLSConfig = r.tlsConfig()
+	}
+	if err := registerCertExpiryCheck(sni, getenv("TLS_EXPIRY_PEERS", "")); err != nil {
+		log.Fatalf("failed to configure certificate expiry check: %v", err)
 	}
 
-	logger.Info("server starting", "port", port, "version", version, "env", env, "buildTime", buildTime)
+	logger.Info("server starting", "port", port, "tls", tlsEnabled(), "readOnly", readOnly, "debugAccessRestricted", debugAccess.enabled(), "version", version, "env", env, "buildTime", buildTime)
+	if u := externalURL(); u != "" && (workerID == "" || workerID == "0") {
+		if err := printQRBanner(os.Stdout, u); err != nil {
+			logger.Warn("failed to render QR banner", "url", u, "err", err)
+		}
+	}
 
 	// start server
+	ln, err := listen(srv.Addr)
+	if err != nil {
+		log.Fatalf("Error starting server: %v", err)
+	}
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		serve := srv.Serve
+		if srv.TLSConfig != nil {
+			serve = func(ln net.Listener) error { return srv.ServeTLS(ln, "", "") }
+		}
+		if err := serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Error starting server: %v", err)
 		}
 	}()
+	listeners := map[string]string{"http": ln.Addr().String()}
+	if srv.TLSConfig != nil {
+		listeners = map[string]string{"https": ln.Addr().String()}
+	}
+	if debugAddr != "" {
+		listeners["debug"] = debugAddr
+	}
+	redirectShutdown := func(context.Context) error { return nil }
+	if srv.TLSConfig != nil && httpRedirectAddr != "" {
+		redirectPort := httpsRedirectPort
+		if redirectPort == "" {
+			redirectPort = port
+		}
+		addr, shutdown, err := startHTTPSRedirect(httpRedirectAddr, redirectPort)
+		if err != nil {
+			log.Fatalf("Error starting HTTPS redirect listener: %v", err)
+		}
+		listeners["httpRedirect"] = addr
+		redirectShutdown = shutdown
+	}
+	if workerID != "" {
+		// Private per-worker metrics, aggregated by the supervisor.
+		id, _ := strconv.Atoi(workerID)
+		listeners["workerMetrics"] = workerMetricsAddr(id)
+		go func() {
+			if err := http.ListenAndServe(workerMetricsAddr(id), metricsHandler(metricsRegistry)); err != nil {
+				logger.Error("worker metrics listener failed", "err", err)
+			}
+		}()
+	}
+	newStartupDiagnostics(mux, routeMiddleware, listeners).emit()
 
 	// graceful shutdown
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
-	logger.Info("shutdown signal received")
+	// Fail readiness first and give kube-proxy/ingress time to drop this pod
+	// from endpoints; shutting down immediately races that and causes 502s.
+	shuttingDown.Store(true)
+	logger.Info("shutdown signal received", "prestopDelay", prestopDelay.String())
+	time.Sleep(prestopDelay)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
@@ -100,6 +448,23 @@ func main() {
 	} else {
 		logger.Info("server stopped cleanly")
 	}
+	// The store is closed on return; nothing should sweep into it after.
+	stopJanitor()
+	if err := redirectShutdown(ctx); err != nil {
+		logger.Warn("HTTPS redirect listener shutdown error", "err", err)
+	}
+	if err := debugShutdown(ctx); err != nil {
+		logger.Warn("debug listener shutdown error", "err", err)
+	}
+	if err := otelMetricsShutdown(ctx); err != nil {
+		logger.Warn("flushing OTLP metrics failed", "err", err)
+	}
+	if err := otelTracingShutdown(ctx); err != nil {
+		logger.Warn("flushing OTLP traces failed", "err", err)
+	}
+	if err := otelLogsShutdown(ctx); err != nil {
+		logger.Warn("flushing OTLP logs failed", "err", err)
+	}
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
@@ -112,43 +477,58 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func infoHandler(w http.ResponseWriter, r *http.Request) {
-	hostname, _ := os.Hostname()
-	info := AppInfo{
-		Name:        "Harness Demo App",
-		Version:     version,
-		Environment: env,
-		BuildTime:   buildTime,
-		Uptime:      time.Since(startTime).Truncate(time.Second).String(),
-		Hostname:    hostname,
-	}
+	info := appInfoCache.get()
+	info.Uptime = time.Since(startTime).Truncate(time.Second).String()
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(info)
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, `{"status":"healthy"}`)
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
 }
 
-func liveHandler(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, `{"status":"alive"}`)
+// writeError writes {"error": msg}, plus the request ID when withRequestID
+// has set one.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	body := map[string]string{"error": msg}
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		body["requestId"] = id
+	}
+	writeJSON(w, status, body)
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	if time.Since(startTime) < readyAfter {
-		writeJSON(w, http.StatusServiceUnavailable, `{"status":"warming"}`)
-		return
+// decodeJSON decodes a JSON request body into v. An empty body is not an
+// error and leaves v untouched.
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
 	}
-	writeJSON(w, http.StatusOK, `{"status":"ready"}`)
+	return nil
 }
 
-func writeJSON(w http.ResponseWriter, status int, body string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	fmt.Fprint(w, body)
+// requireMethod writes a 405 and returns false unless r uses one of
+// methods. GET implies HEAD.
+func requireMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	if slices.Contains(methods, r.Method) || r.Method == http.MethodHead && slices.Contains(methods, http.MethodGet) {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(withHeadMethod(methods), ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	return false
 }
 
 // --- helpers / middleware ---
 
+// listen opens the public listener. Workers share the port via SO_REUSEPORT.
+func listen(addr string) (net.Listener, error) {
+	if workerID != "" {
+		return listenReusePort(addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
 // fsSub returns an fs.FS rooted at subdir (e.g., "static") from embeddedFS
 func fsSub(dir string) (fs.FS, error) {
 	return fs.Sub(embeddedFS, dir)
@@ -156,28 +536,72 @@ func fsSub(dir string) (fs.FS, error) {
 
 func bytesReader(b []byte) io.Reader { return bytes.NewReader(b) }
 
-func withLogging() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			slog.Default().Info("request",
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote", r.RemoteAddr,
-				"dur_ms", time.Since(start).Milliseconds(),
-			)
-		})
+// rwCapture wraps a ResponseWriter to record the status code and body size,
+// and to run onHeader just before the headers are sent.
+type rwCapture struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	onHeader func(http.Header)
+}
+
+func (c *rwCapture) WriteHeader(code int) {
+	if c.status == 0 {
+		c.status = code
+		if c.onHeader != nil {
+			c.onHeader(c.Header())
+		}
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *rwCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	n, err := c.ResponseWriter.Write(b)
+	c.bytes += int64(n)
+	return n, err
+}
+
+func (c *rwCapture) Flush() {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (c *rwCapture) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// parseHeaderList parses "Name=value,Name2=value2" into canonical header
+// names and values.
+func parseHeaderList(spec string) (http.Header, error) {
+	h := make(http.Header)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid header %q, want Name=value", pair)
+		}
+		h.Set(k, strings.TrimSpace(v))
 	}
+	return h, nil
 }
 
-func withSecurityHeaders() func(http.Handler) http.Handler {
+// withResponseHeaders adds static headers (RESPONSE_HEADERS) to every
+// response so infrastructure context like region or cluster is visible.
+func withResponseHeaders(headers http.Header) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("X-Content-Type-Options", "nosniff")
-			w.Header().Set("X-Frame-Options", "DENY")
-			w.Header().Set("Referrer-Policy", "no-referrer")
-			w.Header().Set("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; script-src 'self'")
+			for k, v := range headers {
+				w.Header()[k] = v
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
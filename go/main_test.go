@@ -7,7 +7,7 @@ import (
 	"testing"
 )
 
-func TestHelloHandler(t *testing.T) {
+func TestHomeHandler(t *testing.T) {
 	// Create a request to pass to our handler
 	req, err := http.NewRequest("GET", "/", nil)
 	if err != nil {
@@ -16,7 +16,7 @@ func TestHelloHandler(t *testing.T) {
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(helloHandler)
+	handler := http.HandlerFunc(homeHandler)
 
 	// Call the handler directly, passing in the request and response recorder
 	handler.ServeHTTP(rr, req)
@@ -28,9 +28,33 @@ func TestHelloHandler(t *testing.T) {
 	}
 
 	// Check the response body
-	expected := "Hello, World!"
+	expected := string(indexHTML)
 	if rr.Body.String() != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v",
 			rr.Body.String(), expected)
 	}
 }
+
+func TestWithResponseHeaders(t *testing.T) {
+	headers, err := parseHeaderList("x-region=us-east-1, X-Cluster = blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	chain(http.HandlerFunc(homeHandler), withResponseHeaders(headers)).ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("X-Region"); got != "us-east-1" {
+		t.Errorf("X-Region header: got %q want %q", got, "us-east-1")
+	}
+	if got := rr.Header().Get("X-Cluster"); got != "blue" {
+		t.Errorf("X-Cluster header: got %q want %q", got, "blue")
+	}
+
+	if _, err := parseHeaderList("no-value"); err == nil {
+		t.Error("expected an error for an entry without '='")
+	}
+}
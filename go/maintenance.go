@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//go:embed static/maintenance.html
+var maintenanceHTML []byte
+
+var (
+	maintenanceFile = getenv("MAINTENANCE_FILE", "/tmp/maintenance")
+	maintenancePage = getenvBool("MAINTENANCE_PAGE", false)
+)
+
+func init() {
+	health.register("maintenance", probeReady, func(context.Context) error {
+		if inMaintenance() {
+			return errors.New("maintenance file " + maintenanceFile + " present")
+		}
+		return nil
+	})
+}
+
+// inMaintenance reports whether the maintenance file exists. Touching or
+// removing the file switches maintenance on or off without a restart.
+func inMaintenance() bool {
+	_, err := os.Stat(maintenanceFile)
+	return err == nil
+}
+
+// withMaintenance answers non-probe requests with a 503 while in maintenance
+// and MAINTENANCE_PAGE is enabled: a branded page for browsers, a JSON error
+// under /api/.
+func withMaintenance() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !maintenancePage || isProbePath(r.URL.Path) || !inMaintenance() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Retry-After", "60")
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeError(w, http.StatusServiceUnavailable, "down for maintenance")
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write(maintenanceHTML)
+		})
+	}
+}
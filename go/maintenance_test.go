@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithMaintenance(t *testing.T) {
+	prevFile, prevPage := maintenanceFile, maintenancePage
+	t.Cleanup(func() { maintenanceFile, maintenancePage = prevFile, prevPage })
+	maintenanceFile = filepath.Join(t.TempDir(), "maintenance")
+	maintenancePage = true
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withMaintenance())
+	serve := func(path string) int {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := serve("/api/info"); code != http.StatusOK {
+		t.Errorf("handler returned wrong status code before maintenance: got %v want %v", code, http.StatusOK)
+	}
+	if err := os.WriteFile(maintenanceFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if code := serve("/api/info"); code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code in maintenance: got %v want %v", code, http.StatusServiceUnavailable)
+	}
+	if code := serve("/readyz"); code != http.StatusOK {
+		t.Errorf("probe path was gated by maintenance: got %v want %v", code, http.StatusOK)
+	}
+}
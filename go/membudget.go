@@ -0,0 +1,149 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Soft memory budget. Features that buffer data in memory register as
+// components with their current footprint and a way to shed some of it.
+// When the combined footprint exceeds MEMORY_BUDGET_MB (default 64), the
+// largest components are asked to shed until usage is back under
+// MEMORY_BUDGET_LOW_WATER_PERCENT (default 90) of the budget. The limit is
+// soft: enforcement runs shortly after writes, not inside them, so usage can
+// briefly overshoot.
+type memoryComponent struct {
+	name  string
+	usage func() int64
+	shed  func(bytes int64) int64
+}
+
+type memoryBudget struct {
+	limit    int64
+	lowWater int64
+
+	mu         sync.Mutex
+	components []memoryComponent
+	shedTotal  map[string]int64
+
+	kick chan struct{}
+}
+
+type memoryBudgetStatus struct {
+	LimitBytes int64                   `json:"limitBytes"`
+	UsedBytes  int64                   `json:"usedBytes"`
+	Components []memoryComponentStatus `json:"components"`
+}
+
+type memoryComponentStatus struct {
+	Name      string `json:"name"`
+	Bytes     int64  `json:"bytes"`
+	ShedBytes int64  `json:"shedBytes"`
+}
+
+var memBudget = newMemoryBudget(
+	int64(max(getenvInt("MEMORY_BUDGET_MB", 64), 1))<<20,
+	getenvInt("MEMORY_BUDGET_LOW_WATER_PERCENT", 90),
+)
+
+func init() {
+	memBudget.register("kv", func() int64 { return kv.usage() }, func(n int64) int64 { return kv.shed(n) })
+	memBudget.register("ledger", func() int64 { return ledger.usage() }, func(n int64) int64 { return ledger.shed(n) })
+	memoryBudgetLimit.Set(float64(memBudget.limit))
+}
+
+func newMemoryBudget(limit int64, lowWaterPercent int) *memoryBudget {
+	lowWaterPercent = min(max(lowWaterPercent, 1), 100)
+	return &memoryBudget{
+		limit:     limit,
+		lowWater:  limit * int64(lowWaterPercent) / 100,
+		shedTotal: make(map[string]int64),
+		kick:      make(chan struct{}, 1),
+	}
+}
+
+func (b *memoryBudget) register(name string, usage func() int64, shed func(int64) int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.components = append(b.components, memoryComponent{name: name, usage: usage, shed: shed})
+}
+
+// nudge asks the enforcer to run soon. It never blocks, so components can
+// call it right after a write.
+func (b *memoryBudget) nudge() {
+	select {
+	case b.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run enforces the budget whenever nudged and at least every interval.
+func (b *memoryBudget) run(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-b.kick:
+		case <-t.C:
+		}
+		b.enforce()
+	}
+}
+
+// enforce sheds from the largest components first until usage drops to the
+// low-water mark, and returns the usage afterwards.
+func (b *memoryBudget) enforce() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage := make(map[string]int64, len(b.components))
+	var total int64
+	for _, c := range b.components {
+		u := c.usage()
+		usage[c.name] = u
+		total += u
+	}
+	if total > b.limit {
+		order := slices.Clone(b.components)
+		slices.SortFunc(order, func(x, y memoryComponent) int { return cmp.Compare(usage[y.name], usage[x.name]) })
+		for _, c := range order {
+			if total <= b.lowWater {
+				break
+			}
+			freed := c.shed(total - b.lowWater)
+			if freed > 0 {
+				b.shedTotal[c.name] += freed
+				memoryBudgetShed.WithLabelValues(c.name).Add(float64(freed))
+				usage[c.name] -= freed
+				total -= freed
+			}
+		}
+		logger.Warn("memory budget exceeded, shed component data", "limitBytes", b.limit, "usedBytes", total)
+	}
+	for name, u := range usage {
+		memoryBudgetUsage.WithLabelValues(name).Set(float64(u))
+	}
+	return total
+}
+
+func (b *memoryBudget) status() memoryBudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := memoryBudgetStatus{LimitBytes: b.limit, Components: []memoryComponentStatus{}}
+	for _, c := range b.components {
+		u := c.usage()
+		st.UsedBytes += u
+		st.Components = append(st.Components, memoryComponentStatus{Name: c.name, Bytes: u, ShedBytes: b.shedTotal[c.name]})
+	}
+	return st
+}
+
+func memoryBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, memBudget.status())
+}
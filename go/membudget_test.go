@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryBudgetShedsLargestFirst(t *testing.T) {
+	b := newMemoryBudget(1000, 80)
+	sizes := map[string]int64{"big": 900, "small": 300}
+	for _, name := range []string{"small", "big"} {
+		b.register(name, func() int64 { return sizes[name] }, func(n int64) int64 {
+			freed := min(n, sizes[name])
+			sizes[name] -= freed
+			return freed
+		})
+	}
+
+	if used := b.enforce(); used != 800 {
+		t.Errorf("usage after enforce: got %d want 800 (low water)", used)
+	}
+	if sizes["small"] != 300 || sizes["big"] != 500 {
+		t.Errorf("expected only the largest component to shed, got %v", sizes)
+	}
+	st := b.status()
+	if st.UsedBytes != 800 || st.Components[1].ShedBytes != 400 {
+		t.Errorf("unexpected status %+v", st)
+	}
+}
+
+func TestKVShedAndUsage(t *testing.T) {
+	s := newKVStore(10, 1024, time.Hour, time.Hour)
+	_, _, _ = s.put("a", make([]byte, 100), "", time.Minute)
+	_, _, _ = s.put("b", make([]byte, 100), "", time.Hour)
+	if got, want := s.usage(), 2*(1+100+kvItemOverhead); got != int64(want) {
+		t.Fatalf("usage: got %d want %d", got, want)
+	}
+	_, _, _ = s.put("a", make([]byte, 10), "", time.Minute)
+	if got, want := s.usage(), (1+10+kvItemOverhead)+(1+100+kvItemOverhead); got != int64(want) {
+		t.Errorf("usage after overwrite: got %d want %d", got, want)
+	}
+
+	s.shed(1)
+	if _, ok := s.get("a"); ok {
+		t.Error("shed should evict the soonest-expiring key first")
+	}
+	if _, ok := s.get("b"); !ok {
+		t.Error("shed evicted more than needed")
+	}
+}
+
+func TestLedgerShedDropsOldest(t *testing.T) {
+	l := newLedger(100)
+	for i := 0; i < 5; i++ {
+		l.record("test", "", "entry", nil)
+	}
+	per := l.usage() / 5
+	if freed := l.shed(per + 1); freed != 2*per {
+		t.Errorf("freed %d bytes, want %d", freed, 2*per)
+	}
+	if got := l.list("", 0); len(got) != 3 || got[0].ID != 3 {
+		t.Errorf("expected the two oldest entries gone, got %+v", got)
+	}
+}
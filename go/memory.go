@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Memory pressure check. The "memory" readiness check compares the Go heap
+// against MEMORY_HEAP_DEGRADED_MB / MEMORY_HEAP_UNHEALTHY_MB, and process RSS
+// against MEMORY_RSS_DEGRADED_PERCENT / MEMORY_RSS_UNHEALTHY_PERCENT of the
+// container's cgroup memory limit. Crossing a degraded threshold is reported
+// as a warning; crossing an unhealthy one fails readiness. Zero disables a
+// threshold, and the RSS thresholds only apply when a cgroup limit is set.
+type memoryCheck struct {
+	heapDegraded, heapUnhealthy uint64
+	rssDegraded, rssUnhealthy   float64
+
+	readHeap  func() uint64
+	readRSS   func() (uint64, error)
+	readLimit func() (uint64, error)
+
+	mu   sync.Mutex
+	last memoryStatus
+}
+
+type memoryStatus struct {
+	HeapBytes    uint64  `json:"heapBytes"`
+	RSSBytes     uint64  `json:"rssBytes,omitempty"`
+	LimitBytes   uint64  `json:"limitBytes,omitempty"`
+	LimitPercent float64 `json:"limitPercent,omitempty"`
+}
+
+var cgroupMemoryLimitFiles = []string{
+	"/sys/fs/cgroup/memory.max",                   // cgroup v2
+	"/sys/fs/cgroup/memory/memory.limit_in_bytes", // cgroup v1
+}
+
+func init() {
+	mc := newMemoryCheck()
+	health.registerDetailed("memory", probeReady, mc.check, mc.details)
+}
+
+func newMemoryCheck() *memoryCheck {
+	return &memoryCheck{
+		heapDegraded:  uint64(max(getenvInt("MEMORY_HEAP_DEGRADED_MB", 0), 0)) << 20,
+		heapUnhealthy: uint64(max(getenvInt("MEMORY_HEAP_UNHEALTHY_MB", 0), 0)) << 20,
+		rssDegraded:   float64(getenvInt("MEMORY_RSS_DEGRADED_PERCENT", 80)),
+		rssUnhealthy:  float64(getenvInt("MEMORY_RSS_UNHEALTHY_PERCENT", 95)),
+		readHeap: func() uint64 {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			return ms.HeapAlloc
+		},
+		readRSS: func() (uint64, error) {
+			rss, err := processRSS(os.Getpid())
+			return uint64(rss), err
+		},
+		readLimit: cgroupMemoryLimit,
+	}
+}
+
+func (mc *memoryCheck) check(context.Context) error {
+	st := memoryStatus{HeapBytes: mc.readHeap()}
+	if rss, err := mc.readRSS(); err == nil {
+		st.RSSBytes = rss
+	}
+	if limit, err := mc.readLimit(); err == nil && limit > 0 {
+		st.LimitBytes = limit
+		if st.RSSBytes > 0 {
+			st.LimitPercent = 100 * float64(st.RSSBytes) / float64(limit)
+		}
+	}
+	mc.mu.Lock()
+	mc.last = st
+	mc.mu.Unlock()
+
+	switch {
+	case mc.heapUnhealthy > 0 && st.HeapBytes >= mc.heapUnhealthy:
+		return fmt.Errorf("heap %d MiB at or above %d MiB", st.HeapBytes>>20, mc.heapUnhealthy>>20)
+	case mc.rssUnhealthy > 0 && st.LimitPercent >= mc.rssUnhealthy:
+		return fmt.Errorf("RSS at %.0f%% of %d MiB limit", st.LimitPercent, st.LimitBytes>>20)
+	case mc.heapDegraded > 0 && st.HeapBytes >= mc.heapDegraded:
+		return degraded(fmt.Errorf("heap %d MiB at or above %d MiB", st.HeapBytes>>20, mc.heapDegraded>>20))
+	case mc.rssDegraded > 0 && st.LimitPercent >= mc.rssDegraded:
+		return degraded(fmt.Errorf("RSS at %.0f%% of %d MiB limit", st.LimitPercent, st.LimitBytes>>20))
+	}
+	return nil
+}
+
+func (mc *memoryCheck) details() any {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.last
+}
+
+// cgroupMemoryLimit returns the container memory limit, or 0 if there is
+// none.
+func cgroupMemoryLimit() (uint64, error) {
+	var lastErr error
+	for _, path := range cgroupMemoryLimitFiles {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		v := strings.TrimSpace(string(b))
+		if v == "max" {
+			return 0, nil
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		// cgroup v1 reports "unlimited" as a huge page-aligned number.
+		if n >= 1<<62 {
+			return 0, nil
+		}
+		return n, nil
+	}
+	return 0, lastErr
+}
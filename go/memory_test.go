@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func fakeMemoryCheck(heap, rss, limit uint64) *memoryCheck {
+	return &memoryCheck{
+		heapDegraded:  100 << 20,
+		heapUnhealthy: 200 << 20,
+		rssDegraded:   80,
+		rssUnhealthy:  95,
+		readHeap:      func() uint64 { return heap },
+		readRSS:       func() (uint64, error) { return rss, nil },
+		readLimit:     func() (uint64, error) { return limit, nil },
+	}
+}
+
+func TestMemoryCheckThresholds(t *testing.T) {
+	tests := []struct {
+		name             string
+		heap, rss, limit uint64
+		want             string // ok, degraded, failed
+	}{
+		{"healthy", 10 << 20, 50 << 20, 1 << 30, "ok"},
+		{"no limit ignores rss", 10 << 20, 4 << 30, 0, "ok"},
+		{"heap degraded", 150 << 20, 50 << 20, 1 << 30, "degraded"},
+		{"heap unhealthy", 250 << 20, 50 << 20, 1 << 30, "failed"},
+		{"rss degraded", 10 << 20, 850 << 20, 1000 << 20, "degraded"},
+		{"rss unhealthy", 10 << 20, 960 << 20, 1000 << 20, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fakeMemoryCheck(tt.heap, tt.rss, tt.limit).check(context.Background())
+			got := "ok"
+			switch {
+			case errors.As(err, new(degradedError)):
+				got = "degraded"
+			case err != nil:
+				got = "failed"
+			}
+			if got != tt.want {
+				t.Errorf("got %s (%v), want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCheckDetails(t *testing.T) {
+	mc := fakeMemoryCheck(1<<20, 500<<20, 1000<<20)
+	_ = mc.check(context.Background())
+	st := mc.details().(memoryStatus)
+	if st.LimitPercent != 50 || st.HeapBytes != 1<<20 {
+		t.Errorf("unexpected details: %+v", st)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Method enforcement. Every application route declares the methods it
+// serves when it is registered, and withAllowedMethods answers any other
+// method with 405, an Allow header listing them and a JSON error before the
+// route's handler runs, so POST /healthz no longer gets a 200. A route
+// serving GET also serves HEAD, and OPTIONS is answered for every route
+// with 204 and its Allow set (and as a CORS preflight when CORS is on)
+// without reaching the handler.
+
+// HandleMethods registers h for pattern, serving only methods.
+func (m *routeMux) HandleMethods(pattern string, h http.Handler, methods ...string) {
+	if len(methods) > 0 && !slices.Contains(methods, http.MethodOptions) {
+		methods = append(withHeadMethod(methods), http.MethodOptions)
+	}
+	m.methods[pattern] = methods
+	m.Handle(pattern, h)
+}
+
+// withHeadMethod adds HEAD after GET in methods if it isn't there.
+func withHeadMethod(methods []string) []string {
+	if !slices.Contains(methods, http.MethodGet) || slices.Contains(methods, http.MethodHead) {
+		return methods
+	}
+	return slices.Insert(slices.Clone(methods), slices.Index(methods, http.MethodGet)+1, http.MethodHead)
+}
+
+// allowed returns the methods registered for pattern, or nil if it doesn't
+// restrict them.
+func (m *routeMux) allowed(pattern string) []string {
+	return m.methods[pattern]
+}
+
+func withAllowedMethods(mux *routeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methods := mux.allowed(r.Pattern)
+			if len(methods) == 0 {
+				cors.annotate(w, r)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.Method == http.MethodOptions {
+				w.Header().Set("Allow", strings.Join(methods, ", "))
+				if r.Header.Get("Access-Control-Request-Method") != "" {
+					cors.preflight(w, r, methods)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			cors.annotate(w, r)
+			if !requireMethod(w, r, methods...) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
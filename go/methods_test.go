@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedMethods(t *testing.T) {
+	mux := newRouteMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleMethods("/healthz", chain(ok, withAllowedMethods(mux)), http.MethodGet)
+	mux.HandleMethods("/api/kv/{key}", chain(ok, withAllowedMethods(mux)), http.MethodGet, http.MethodPut, http.MethodDelete)
+	mux.Handle("/api/any", chain(ok, withAllowedMethods(mux)))
+
+	cases := []struct {
+		method, path string
+		want         int
+		allow        string
+	}{
+		{"GET", "/healthz", http.StatusOK, ""},
+		{"HEAD", "/healthz", http.StatusOK, ""},
+		{"POST", "/healthz", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"DELETE", "/api/kv/a", http.StatusOK, ""},
+		{"POST", "/api/kv/a", http.StatusMethodNotAllowed, "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{"PATCH", "/api/any", http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if rr.Code != tc.want || rr.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: status %d Allow %q, want %d %q", tc.method, tc.path, rr.Code, rr.Header().Get("Allow"), tc.want, tc.allow)
+		}
+		if tc.want == http.StatusMethodNotAllowed && rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s %s: Content-Type %q, want JSON", tc.method, tc.path, rr.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Application metrics. Everything here is registered on metricsRegistry,
+// which the app owns instead of the global default registry, and exposed
+// via /metrics.
+var (
+	appBuildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "app_build_info",
+		Help: "Always 1; labels identify the running build.",
+	}, []string{"version", "commit", "build_time", "go_version"})
+	appReady = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "app_ready",
+		Help: "Whether the last unfiltered readiness probe passed (1) or failed (0).",
+	})
+	appHealthCheckStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "app_health_check_status",
+		Help: "Last result of each health check: 1 ok, 0.5 degraded, 0 failed.",
+	}, []string{"check"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to serve HTTP requests, by route pattern, method and status class.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status_class"})
+	httpResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "Size of HTTP response bodies, by route pattern.",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 9), // 64B .. 4MiB
+	}, []string{"route"})
+	httpRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpRouteConcurrency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_route_in_flight_requests",
+		Help: "Requests currently being served, by route pattern.",
+	}, []string{"route"})
+	httpRouteQueued = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_route_queued_requests",
+		Help: "Requests waiting for a slot on a concurrency-limited route.",
+	}, []string{"route"})
+	httpRouteQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_route_queue_wait_seconds",
+		Help:    "Time requests to concurrency-limited routes waited for a slot.",
+		Buckets: []float64{0, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"route"})
+	httpRouteRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_route_rejections_total",
+		Help: "Requests rejected with 503 after queueing for a concurrency-limited route.",
+	}, []string{"route"})
+	httpRequestHeaderCount = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "http_request_header_count",
+		Help:    "Number of header fields on incoming requests.",
+		Buckets: []float64{5, 10, 15, 20, 30, 40, 60, 80, 120},
+	})
+	httpRequestHeaderBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "http_request_header_bytes",
+		Help:    "Approximate size of incoming request headers on the wire.",
+		Buckets: prometheus.ExponentialBuckets(256, 2, 9), // 256B .. 64KiB
+	})
+	httpHeaderAnomalies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_request_header_anomalies_total",
+		Help: "Requests whose headers exceeded the anomaly thresholds, by reason (count or size).",
+	}, []string{"reason"})
+	httpHeaderRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_request_header_rejections_total",
+		Help: "Requests rejected with 431 for exceeding the header limits, by reason (count or size).",
+	}, []string{"reason"})
+
+	kvKeys = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "kv_keys",
+		Help: "Number of keys currently held in the KV scratchpad.",
+	})
+	kvEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kv_evictions_total",
+		Help: "Keys removed from the KV scratchpad without an explicit DELETE, by reason.",
+	}, []string{"reason"})
+	storeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "store_write_errors_total",
+		Help: "Failed writes to the STORE_BACKEND, by operation.",
+	}, []string{"op"})
+
+	dependencyUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dependency_up",
+		Help: "Whether the last check of a downstream dependency succeeded (1) or failed (0).",
+	}, []string{"dependency"})
+	dependencyLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dependency_latency_seconds",
+		Help: "Latency of the last check of a downstream dependency.",
+	}, []string{"dependency"})
+	dependencyFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dependency_consecutive_failures",
+		Help: "Consecutive failed checks of a downstream dependency.",
+	}, []string{"dependency"})
+	budgetExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dependency_budget_exceeded_total",
+		Help: "Downstream calls that took longer than the dependency's latency budget.",
+	}, []string{"dependency"})
+
+	redisPingLatency = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "redis_ping_latency_seconds",
+		Help: "Round-trip time of the last Redis health check PING.",
+	})
+
+	dnsLookupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dns_lookup_duration_seconds",
+		Help:    "Duration of DNS health check lookups.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"result"})
+
+	diskFreeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "disk_free_bytes",
+		Help: "Free bytes on the filesystem watched by the disk health check.",
+	}, []string{"path"})
+
+	tlsCertExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tls_cert_expiry_timestamp_seconds",
+		Help: "Expiry time (NotAfter) of each certificate watched by the tls-expiry check, as a Unix timestamp.",
+	}, []string{"certificate", "source"})
+
+	shardRingSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "shard_ring_size",
+		Help: "Number of simulated shards on the consistent hash ring.",
+	})
+	shardRebalances = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "shard_rebalances_total",
+		Help: "Times the consistent hash ring was resized.",
+	})
+	shardMovedRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "shard_rebalance_moved_ratio",
+		Help: "Fraction of sampled keys whose primary shard changed in the last resize.",
+	})
+	shardLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shard_lookups_total",
+		Help: "Shard lookups served by /api/shard, by primary shard.",
+	}, []string{"shard"})
+
+	memoryBudgetLimit = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "memory_budget_limit_bytes",
+		Help: "Soft memory budget shared by in-memory buffers and caches.",
+	})
+	memoryBudgetUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "memory_budget_usage_bytes",
+		Help: "Approximate memory held by each component under the memory budget.",
+	}, []string{"component"})
+	memoryBudgetShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "memory_budget_shed_bytes_total",
+		Help: "Bytes each component dropped to get back under the memory budget.",
+	}, []string{"component"})
+
+	eventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Events published on the internal event bus, by type.",
+	}, []string{"type"})
+
+	statsdDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "statsd_dropped_total",
+		Help: "StatsD lines dropped because the send queue was full.",
+	})
+
+	httpConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_connections",
+		Help: "Open client connections by state (new, active, idle).",
+	}, []string{"state"})
+	httpConnectionsOpened = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "http_connections_opened_total",
+		Help: "Client connections accepted.",
+	})
+	httpConnectionsClosed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_connections_closed_total",
+		Help: "Client connections finished, by the state they ended in (closed or hijacked) and the state they were in before.",
+	}, []string{"state", "from"})
+	httpConnectionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "http_connection_duration_seconds",
+		Help:    "Lifetime of client connections, from accept to close or hijack.",
+		Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	})
+	httpConnectionRequests = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "http_connection_requests",
+		Help:    "Requests served per client connection, i.e. keep-alive reuse.",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100, 250, 1000},
+	})
+
+	syntheticRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "synthetic_requests_total",
+		Help: "Simulated requests recorded by the synthetic signal generator, by outcome.",
+	}, []string{"outcome"})
+	syntheticDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "synthetic_request_duration_seconds",
+		Help:    "Simulated latency recorded by the synthetic signal generator.",
+		Buckets: prometheus.DefBuckets,
+	})
+	syntheticProfileGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "synthetic_profile",
+		Help: "Effective synthetic profile parameters, including any ramp in progress.",
+	}, []string{"param"})
+
+	staticRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "static_file_requests_total",
+		Help: "Requests for embedded static files, by file and status code (304s are cache revalidation hits).",
+	}, []string{"file", "code"})
+	staticBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "static_file_bytes_total",
+		Help: "Response body bytes sent for embedded static files, by file.",
+	}, []string{"file"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_total",
+		Help: "Requests rejected with 429 by the per-client rate limiter, by route.",
+	}, []string{"route"})
+
+	requestsShed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "requests_shed_total",
+		Help: "Requests rejected with 503 because MAX_CONCURRENT_REQUESTS were already in flight, by route.",
+	}, []string{"route"})
+
+	requestTimeoutsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "request_timeouts_total",
+		Help: "Requests answered with 504 because they outran their REQUEST_TIMEOUT, by route.",
+	}, []string{"route"})
+
+	csrfRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csrf_rejections_total",
+		Help: "State-changing requests refused with 403 for a missing or mismatched CSRF token, by route.",
+	}, []string{"route"})
+
+	hostRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "host_rejections_total",
+		Help: "Requests refused for a Host header outside ALLOWED_HOSTS (reason host) or differing from the TLS server name (reason sni).",
+	}, []string{"reason"})
+
+	ipAccessDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ip_access_denied_total",
+		Help: "Requests refused with 403 by IP_ACCESS_RULES, by the path prefix of the rule group.",
+	}, []string{"group"})
+
+	panicsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "panics_total",
+		Help: "Handler panics recovered by withRecovery, by route.",
+	}, []string{"route"})
+
+	accessLogsSampledOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "access_logs_sampled_out_total",
+		Help: "Access log lines skipped by ACCESS_LOG_SAMPLE, by route.",
+	}, []string{"route"})
+
+	debugAccessDenied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "debug_endpoint_access_denied_total",
+		Help: "Requests to /metrics and other debug endpoints refused, by reason (ip or auth).",
+	}, []string{"reason"})
+
+	// Demo runner metrics live on the job registry (see pushgateway.go),
+	// not metricsRegistry: they describe a `demo run`, not the server.
+	demoSteps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "demo_steps_total",
+		Help: "Demo scenario steps reported, by kind and result.",
+	}, []string{"kind", "result"})
+	demoLoadRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "demo_load_requests_total",
+		Help: "Requests sent by demo load steps, by response status (or error).",
+	}, []string{"status"})
+
+	loggenBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loggen_bytes_total",
+		Help: "Bytes of synthetic log output written by the log generator.",
+	})
+	loggenLines = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "loggen_lines_total",
+		Help: "Synthetic log lines written by the log generator.",
+	})
+)
+
+// metricsRegistry backs /metrics. Collectors added at runtime (the DB pool
+// stats, for example) are registered on it explicitly.
+var metricsRegistry = newMetricsRegistry()
+
+// goMutexWaitRule adds the cumulative time goroutines spent blocked on
+// sync.Mutex and sync.RWMutex, which none of the built-in groups include.
+var goMutexWaitRule = collectors.GoRuntimeMetricsRule{Matcher: regexp.MustCompile(`^/sync/mutex/wait/total:seconds$`)}
+
+// newGoCollector exports the runtime/metrics scheduler, GC and mutex wait
+// series (go_sched_latencies_seconds, go_gc_pauses_seconds,
+// go_sync_mutex_wait_total_seconds_total, ...) on top of the classic
+// go_memstats_* set, for validating Go runtime dashboards.
+// GO_RUNTIME_METRICS=all exports every runtime/metrics series instead and
+// =off keeps only the classic set.
+func newGoCollector(mode string) prometheus.Collector {
+	switch mode {
+	case "off":
+		return collectors.NewGoCollector()
+	case "all":
+		return collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll))
+	default:
+		return collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsScheduler, collectors.MetricsGC, goMutexWaitRule))
+	}
+}
+
+// newMetricsRegistry returns a registry holding the Go runtime and process
+// collectors plus every application metric. Each call builds a fresh
+// registry, so tests can gather from their own without tripping over
+// duplicate registrations.
+func newMetricsRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		newGoCollector(getenv("GO_RUNTIME_METRICS", "")),
+		newProcessCollector(),
+		appBuildInfo,
+		appReady,
+		appHealthCheckStatus,
+		httpRequestDuration,
+		httpResponseSize,
+		httpRequestsInFlight,
+		httpRequestsTotal,
+		httpRouteConcurrency,
+		httpRouteQueued,
+		httpRouteQueueWait,
+		httpRouteRejections,
+		httpRequestHeaderCount,
+		httpRequestHeaderBytes,
+		httpHeaderAnomalies,
+		httpHeaderRejections,
+		kvKeys,
+		kvEvictions,
+		storeErrors,
+		dependencyUp,
+		dependencyLatency,
+		dependencyFailures,
+		budgetExceeded,
+		redisPingLatency,
+		dnsLookupDuration,
+		diskFreeBytes,
+		tlsCertExpiry,
+		shardRingSize,
+		shardRebalances,
+		shardMovedRatio,
+		shardLookups,
+		memoryBudgetLimit,
+		memoryBudgetUsage,
+		memoryBudgetShed,
+		eventsPublished,
+		statsdDropped,
+		debugAccessDenied,
+		staticRequests,
+		staticBytes,
+		accessLogsSampledOut,
+		panicsTotal,
+		rateLimited,
+		requestsShed,
+		requestTimeoutsTotal,
+		csrfRejections,
+		hostRejections,
+		ipAccessDenied,
+		clients,
+		syntheticRequests,
+		syntheticDuration,
+		syntheticProfileGauge,
+		httpConnections,
+		httpConnectionsOpened,
+		httpConnectionsClosed,
+		httpConnectionDuration,
+		httpConnectionRequests,
+		loggenBytes,
+		loggenLines,
+	)
+	return reg
+}
+
+// metricsHandler serves reg, with the handler's own promhttp_* metrics
+// recorded on reg as well. Scrapers that ask for OpenMetrics get it,
+// including exemplars and _created series for counters and histograms;
+// everyone else gets the classic text format.
+func metricsHandler(reg *prometheus.Registry) http.Handler {
+	return promhttp.InstrumentMetricHandler(reg, promhttp.HandlerFor(reg, promhttp.HandlerOpts{
+		Registry:                            reg,
+		EnableOpenMetrics:                   true,
+		EnableOpenMetricsTextCreatedSamples: true,
+		ProcessStartTime:                    startTime,
+	}))
+}
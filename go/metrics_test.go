@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsHandlerServesAppRegistry(t *testing.T) {
+	reg := newMetricsRegistry()
+	rr := httptest.NewRecorder()
+	metricsHandler(reg).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body := rr.Body.String()
+	for _, name := range []string{"app_build_info", "go_goroutines", "process_cpu_seconds_total", "promhttp_metric_handler_requests_total"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("/metrics is missing %s", name)
+		}
+	}
+}
+
+func TestRegistriesAreIndependent(t *testing.T) {
+	withTestHealth(t)
+	t.Cleanup(func() {
+		if appDB != nil {
+			appDB.Close()
+		}
+		appDB, appDBDriver = nil, ""
+	})
+
+	// Opening the database against two fresh registries must not panic
+	// with a duplicate registration, and each sees only its own pool.
+	a, b := newMetricsRegistry(), newMetricsRegistry()
+	if err := openDB("postgres://app@127.0.0.1:1/app", a); err != nil {
+		t.Fatal(err)
+	}
+	defer appDB.Close()
+	if err := openDB("postgres://app@127.0.0.1:1/app", b); err != nil {
+		t.Fatal(err)
+	}
+	if err := openDB("postgres://app@127.0.0.1:1/app", b); err == nil {
+		t.Error("expected an error registering a second pool on the same registry")
+	}
+	if n, err := testutil.GatherAndCount(a, "go_sql_max_open_connections"); err != nil || n != 1 {
+		t.Errorf("go_sql_max_open_connections series on registry a = %d, %v", n, err)
+	}
+}
+
+func TestMetricsHandlerNegotiatesOpenMetrics(t *testing.T) {
+	reg := newMetricsRegistry()
+	h := metricsHandler(reg)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Fatalf("Content-Type = %q, want OpenMetrics", ct)
+	}
+	body := rr.Body.String()
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("OpenMetrics body should end with # EOF")
+	}
+	if !strings.Contains(body, "promhttp_metric_handler_requests_created") {
+		t.Error("expected _created series for counters")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type without Accept = %q, want text/plain", ct)
+	}
+}
+
+func TestGoCollectorRuntimeMetrics(t *testing.T) {
+	names := func(mode string) string {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(newGoCollector(mode))
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		for _, f := range families {
+			b.WriteString(f.GetName() + "\n")
+		}
+		return b.String()
+	}
+
+	detailed := names("")
+	for _, name := range []string{"go_sched_latencies_seconds", "go_gc_pauses_seconds", "go_sync_mutex_wait_total_seconds_total", "go_memstats_heap_alloc_bytes"} {
+		if !strings.Contains(detailed, name+"\n") {
+			t.Errorf("default Go collector is missing %s", name)
+		}
+	}
+	if strings.Contains(names("off"), "go_sched_latencies_seconds\n") {
+		t.Error("GO_RUNTIME_METRICS=off still exports scheduler latencies")
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// OTLP log export. When an OTLP endpoint is configured (as for metrics and
+// traces) and OTEL_LOGS_EXPORTER isn't "none", every record written to the
+// app logger is also sent to the collector, so logs, metrics and traces
+// arrive at one endpoint with the same resource. Records logged with a
+// request context carry its trace and span IDs natively. Stdout output is
+// unchanged.
+func startOTelLogs(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if getenv("OTEL_LOGS_EXPORTER", "otlp") == "none" ||
+		getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" && getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "") == "" {
+		return noop, nil
+	}
+	exp, err := otlploggrpc.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+	res, err := otelResource(ctx)
+	if err != nil {
+		return noop, err
+	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exp)),
+	)
+	logger = slog.New(teeHandler{logger.Handler(), otelLogHandler{logger: lp.Logger("sample-apps-go")}})
+	logger.Info("OTLP log export enabled")
+	return lp.Shutdown, nil
+}
+
+// teeHandler sends each record to every handler that wants it.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, rec slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, rec.Level) {
+			errs = append(errs, h.Handle(ctx, rec.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// otelLogHandler bridges slog records to an OpenTelemetry logger. Groups
+// are flattened into dotted attribute keys.
+type otelLogHandler struct {
+	logger otellog.Logger
+	prefix string
+	attrs  []otellog.KeyValue
+}
+
+func (h otelLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= logLevel.Level()
+}
+
+func (h otelLogHandler) Handle(ctx context.Context, rec slog.Record) error {
+	var r otellog.Record
+	r.SetTimestamp(rec.Time)
+	r.SetObservedTimestamp(time.Now())
+	r.SetSeverity(otelSeverity(rec.Level))
+	r.SetSeverityText(rec.Level.String())
+	r.SetBody(otellog.StringValue(rec.Message))
+	r.AddAttributes(h.attrs...)
+	rec.Attrs(func(a slog.Attr) bool {
+		r.AddAttributes(otelKeyValues(h.prefix, a)...)
+		return true
+	})
+	h.logger.Emit(ctx, r)
+	return nil
+}
+
+func (h otelLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	kvs := make([]otellog.KeyValue, 0, len(h.attrs)+len(attrs))
+	kvs = append(kvs, h.attrs...)
+	for _, a := range attrs {
+		kvs = append(kvs, otelKeyValues(h.prefix, a)...)
+	}
+	h.attrs = kvs
+	return h
+}
+
+func (h otelLogHandler) WithGroup(name string) slog.Handler {
+	if name != "" {
+		h.prefix += name + "."
+	}
+	return h
+}
+
+// otelSeverity maps slog levels onto OpenTelemetry severity numbers:
+// DEBUG is 5, INFO 9, WARN 13 and ERROR 17, with levels in between kept.
+func otelSeverity(l slog.Level) otellog.Severity {
+	return otellog.Severity(min(max(int(l)+int(otellog.SeverityInfo), 1), 24))
+}
+
+func otelKeyValues(prefix string, a slog.Attr) []otellog.KeyValue {
+	v := a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return nil
+	}
+	if v.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		var out []otellog.KeyValue
+		for _, ga := range v.Group() {
+			out = append(out, otelKeyValues(prefix, ga)...)
+		}
+		return out
+	}
+	return []otellog.KeyValue{{Key: prefix + a.Key, Value: otelValue(v)}}
+}
+
+func otelValue(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return otellog.Int64Value(int64(u))
+		}
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindTime:
+		return otellog.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return otellog.StringValue(err.Error())
+		}
+		return otellog.StringValue(fmt.Sprint(v.Any()))
+	}
+	return otellog.StringValue(v.String())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+type recordingLogExporter struct {
+	mu   sync.Mutex
+	recs []sdklog.Record
+}
+
+func (e *recordingLogExporter) Export(_ context.Context, recs []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range recs {
+		e.recs = append(e.recs, r.Clone())
+	}
+	return nil
+}
+
+func (e *recordingLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *recordingLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestOTelLogHandler(t *testing.T) {
+	withTestTracer(t)
+	prev := logLevel.Level()
+	t.Cleanup(func() { logLevel.Set(prev) })
+	logLevel.Set(slog.LevelInfo)
+
+	exp := &recordingLogExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exp)))
+	var stdout strings.Builder
+	l := slog.New(teeHandler{slog.NewJSONHandler(&stdout, nil), otelLogHandler{logger: lp.Logger("test")}})
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "op")
+	l.With("worker", "2").WithGroup("req").DebugContext(ctx, "hidden")
+	l.With("worker", "2").WithGroup("req").WarnContext(ctx, "slow", "dur_ms", 1200, "err", errors.New("timeout"))
+	span.End()
+
+	if !strings.Contains(stdout.String(), `"msg":"slow"`) {
+		t.Errorf("stdout handler missed the record: %s", stdout.String())
+	}
+	if len(exp.recs) != 1 {
+		t.Fatalf("exported %d records, want 1", len(exp.recs))
+	}
+	r := exp.recs[0]
+	if r.Body().AsString() != "slow" || r.Severity() != otellog.SeverityWarn || r.SeverityText() != "WARN" {
+		t.Errorf("record = %q %v %q", r.Body().AsString(), r.Severity(), r.SeverityText())
+	}
+	if r.TraceID() != span.SpanContext().TraceID() || r.SpanID() != span.SpanContext().SpanID() {
+		t.Error("record is not correlated with the active span")
+	}
+	attrs := map[string]string{}
+	r.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = kv.Value.String()
+		return true
+	})
+	if attrs["worker"] != "2" || attrs["req.dur_ms"] != "1200" || attrs["req.err"] != "timeout" {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
+
+func TestOTelSeverity(t *testing.T) {
+	for level, want := range map[slog.Level]otellog.Severity{
+		slog.LevelDebug:     otellog.SeverityDebug,
+		slog.LevelInfo:      otellog.SeverityInfo,
+		slog.LevelWarn:      otellog.SeverityWarn,
+		slog.LevelError:     otellog.SeverityError,
+		slog.LevelError + 1: otellog.SeverityError2,
+		-20:                 otellog.SeverityTrace1,
+	} {
+		if got := otelSeverity(level); got != want {
+			t.Errorf("%v: severity = %v, want %v", level, got, want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// OTLP metrics export. When OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_METRICS_ENDPOINT) is set, OpenTelemetry instruments
+// mirroring the main Prometheus metrics are pushed over OTLP/gRPC. The
+// exporter reads the standard OTEL_EXPORTER_OTLP_* variables (headers,
+// TLS, timeout, compression), the reader honours
+// OTEL_METRIC_EXPORT_INTERVAL, and OTEL_SERVICE_NAME /
+// OTEL_RESOURCE_ATTRIBUTES override the resource. OTEL_METRICS_EXPORTER=none
+// turns it off. /metrics keeps working either way.
+//
+// Instruments are created against the global meter provider at startup, so
+// they are no-ops until startOTelMetrics installs a real one.
+var otelHTTP = newOTelHTTPInstruments(otel.Meter("sample-apps-go"))
+
+type otelHTTPInstruments struct {
+	duration metric.Float64Histogram
+	active   metric.Int64UpDownCounter
+	bodySize metric.Int64Histogram
+}
+
+func newOTelHTTPInstruments(m metric.Meter) *otelHTTPInstruments {
+	i := &otelHTTPInstruments{}
+	// Instrument creation only fails on invalid names, which these are not.
+	i.duration, _ = m.Float64Histogram("http.server.request.duration",
+		metric.WithDescription("Duration of HTTP server requests."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10))
+	i.active, _ = m.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of active HTTP server requests."),
+		metric.WithUnit("{request}"))
+	i.bodySize, _ = m.Int64Histogram("http.server.response.body.size",
+		metric.WithDescription("Size of HTTP server response bodies."),
+		metric.WithUnit("By"))
+	_, _ = m.Int64ObservableGauge("app.kv.keys",
+		metric.WithDescription("Number of keys currently held in the KV scratchpad."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			kv.mu.Lock()
+			n := len(kv.items)
+			kv.mu.Unlock()
+			o.Observe(int64(n))
+			return nil
+		}))
+	_, _ = m.Float64ObservableGauge("app.health.check.status",
+		metric.WithDescription("Last result of each health check: 1 ok, 0.5 degraded, 0 failed."),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			for name, res := range health.lastResults() {
+				o.Observe(checkStatusValue(res.Status), metric.WithAttributes(attribute.String("check", name)))
+			}
+			return nil
+		}))
+	return i
+}
+
+func (i *otelHTTPInstruments) start(ctx context.Context, method string) {
+	i.active.Add(ctx, 1, metric.WithAttributes(attribute.String("http.request.method", method)))
+}
+
+func (i *otelHTTPInstruments) finish(ctx context.Context, route, method string, status int, elapsed time.Duration, bytes int64) {
+	i.active.Add(ctx, -1, metric.WithAttributes(attribute.String("http.request.method", method)))
+	attrs := metric.WithAttributes(
+		attribute.String("http.route", route),
+		attribute.String("http.request.method", method),
+		attribute.Int("http.response.status_code", status),
+	)
+	i.duration.Record(ctx, elapsed.Seconds(), attrs)
+	i.bodySize.Record(ctx, bytes, attrs)
+}
+
+// otelResource describes this process; OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES take precedence over the defaults.
+func otelResource(ctx context.Context) (*resource.Resource, error) {
+	return resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "sample-apps-go"),
+			attribute.String("service.version", version),
+			attribute.String("service.instance.id", replica.ID),
+			attribute.String("deployment.environment.name", env),
+		),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+}
+
+// startOTelMetrics installs an OTLP-exporting meter provider if configured
+// and returns a function that flushes and stops it.
+func startOTelMetrics(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	if getenv("OTEL_METRICS_EXPORTER", "otlp") == "none" ||
+		getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "") == "" && getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "") == "" {
+		return noop, nil
+	}
+	exp, err := otlpmetricgrpc.New(ctx)
+	if err != nil {
+		return noop, err
+	}
+	res, err := otelResource(ctx)
+	if err != nil {
+		return noop, err
+	}
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)),
+	)
+	otel.SetMeterProvider(mp)
+	logger.Info("OTLP metrics export enabled")
+	return mp.Shutdown, nil
+}
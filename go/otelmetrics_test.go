@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestOTelHTTPInstruments(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	i := newOTelHTTPInstruments(mp.Meter("test"))
+
+	ctx := context.Background()
+	i.start(ctx, "GET")
+	i.finish(ctx, "/api/kv/{key}", "GET", 404, 20*time.Millisecond, 42)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			if m.Name != "http.server.request.duration" {
+				continue
+			}
+			h := m.Data.(metricdata.Histogram[float64])
+			if len(h.DataPoints) != 1 || h.DataPoints[0].Count != 1 {
+				t.Fatalf("duration data points = %+v", h.DataPoints)
+			}
+			if v, _ := h.DataPoints[0].Attributes.Value(attribute.Key("http.route")); v.AsString() != "/api/kv/{key}" {
+				t.Errorf("http.route = %q", v.AsString())
+			}
+		}
+	}
+	for _, name := range []string{"http.server.request.duration", "http.server.active_requests", "http.server.response.body.size", "app.kv.keys"} {
+		if !found[name] {
+			t.Errorf("missing instrument %s", name)
+		}
+	}
+}
+
+func TestStartOTelMetricsDisabledWithoutEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	shutdown, err := startOTelMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
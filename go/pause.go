@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Traffic pause. POST /api/admin/pause-traffic?duration=30s makes the app
+// answer non-probe requests with 503 and Retry-After until the duration
+// runs out (at most PAUSE_MAX_DURATION, default 5m), so ingress retry and
+// error budget behavior during a short outage can be exercised safely.
+// DELETE ends the pause early. Admin routes stay reachable so a pause can
+// always be cancelled.
+var (
+	pausedUntil      atomic.Int64 // Unix nanoseconds; zero when not paused
+	pauseMaxDuration = getenvDuration("PAUSE_MAX_DURATION", 5*time.Minute)
+)
+
+// pauseRemaining returns how long the current pause has left, or zero.
+func pauseRemaining() time.Duration {
+	until := pausedUntil.Load()
+	if until == 0 {
+		return 0
+	}
+	return max(time.Until(time.Unix(0, until)), 0)
+}
+
+func withTrafficPause() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			left := pauseRemaining()
+			if left == 0 || isProbePath(r.URL.Path) || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(left.Seconds()))))
+			writeError(w, http.StatusServiceUnavailable, "traffic paused")
+		})
+	}
+}
+
+type pauseStatus struct {
+	Paused    bool   `json:"paused"`
+	Until     string `json:"until,omitempty"`
+	Remaining string `json:"remaining,omitempty"`
+}
+
+func currentPauseStatus() pauseStatus {
+	left := pauseRemaining()
+	if left == 0 {
+		return pauseStatus{}
+	}
+	return pauseStatus{
+		Paused:    true,
+		Until:     time.Unix(0, pausedUntil.Load()).UTC().Format(time.RFC3339),
+		Remaining: left.Round(time.Second).String(),
+	}
+}
+
+// pauseTrafficHandler serves GET (status), POST ?duration= and DELETE on
+// /api/admin/pause-traffic.
+func pauseTrafficHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete) {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "duration must be a positive duration such as 30s")
+			return
+		}
+		if d > pauseMaxDuration {
+			writeError(w, http.StatusBadRequest, "duration exceeds PAUSE_MAX_DURATION ("+pauseMaxDuration.String()+")")
+			return
+		}
+		pausedUntil.Store(time.Now().Add(d).UnixNano())
+		loggerFrom(r.Context()).Warn("traffic paused", "duration", d.String(), "by", adminPrincipal(r.Context()))
+		events.publish(event{Type: eventTrafficPause, Message: "traffic paused for " + d.String(), Data: map[string]any{"by": adminPrincipal(r.Context())}})
+	case http.MethodDelete:
+		if pausedUntil.Swap(0) != 0 {
+			loggerFrom(r.Context()).Info("traffic pause cancelled", "by", adminPrincipal(r.Context()))
+			events.publish(event{Type: eventTrafficPause, Message: "traffic pause cancelled", Data: map[string]any{"by": adminPrincipal(r.Context())}})
+		}
+	}
+	writeJSON(w, http.StatusOK, currentPauseStatus())
+}
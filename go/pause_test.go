@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTrafficPause(t *testing.T) {
+	withTestLedger(t, 10)
+	t.Cleanup(func() { pausedUntil.Store(0) })
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withTrafficPause())
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+
+	rr := httptest.NewRecorder()
+	pauseTrafficHandler(rr, httptest.NewRequest("POST", "/api/admin/pause-traffic?duration=30s", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = get("/api/info")
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if ra := rr.Header().Get("Retry-After"); ra != "30" && ra != "29" {
+		t.Errorf("unexpected Retry-After %q", ra)
+	}
+	for _, path := range []string{"/readyz", "/api/admin/pause-traffic"} {
+		if rr := get(path); rr.Code != http.StatusOK {
+			t.Errorf("%s should bypass the pause, got %v", path, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	pauseTrafficHandler(rr, httptest.NewRequest("DELETE", "/api/admin/pause-traffic", nil))
+	if rr := get("/api/info"); rr.Code != http.StatusOK {
+		t.Errorf("traffic still paused after DELETE: %v", rr.Code)
+	}
+	if n := len(ledger.list("traffic-pause", 0)); n != 2 {
+		t.Errorf("expected pause and cancel in the ledger, got %d entries", n)
+	}
+}
+
+func TestTrafficPauseExpires(t *testing.T) {
+	t.Cleanup(func() { pausedUntil.Store(0) })
+	pausedUntil.Store(time.Now().Add(-time.Second).UnixNano())
+	if pauseRemaining() != 0 {
+		t.Error("an elapsed pause should have no time remaining")
+	}
+}
+
+func TestPauseTrafficRejectsBadDurations(t *testing.T) {
+	for _, q := range []string{"", "?duration=soon", "?duration=-1s", "?duration=1h"} {
+		rr := httptest.NewRecorder()
+		pauseTrafficHandler(rr, httptest.NewRequest("POST", "/api/admin/pause-traffic"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: handler returned wrong status code: got %v want %v", q, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build (darwin && !cgo) || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// client_golang reads process metrics from /proc, which these platforms
+// lack, or, on macOS, needs cgo for memory; use getrusage instead.
+func newProcessCollector() prometheus.Collector {
+	return newRusageProcessCollector()
+}
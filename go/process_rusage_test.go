@@ -0,0 +1,37 @@
+//go:build unix
+
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRusageProcessCollector(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(newRusageProcessCollector())
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, f := range families {
+		m := f.GetMetric()[0]
+		if m.GetCounter() != nil {
+			got[f.GetName()] = m.GetCounter().GetValue()
+		} else {
+			got[f.GetName()] = m.GetGauge().GetValue()
+		}
+	}
+	for _, name := range []string{"process_cpu_seconds_total", "process_start_time_seconds", "process_open_fds", "process_max_fds", "process_resident_memory_peak_bytes"} {
+		if got[name] <= 0 {
+			t.Errorf("%s = %v, want > 0", name, got[name])
+		}
+	}
+	// A Go test binary's peak RSS is well over a megabyte; catches a
+	// kilobytes/bytes mix-up.
+	if got["process_resident_memory_peak_bytes"] < 1<<20 {
+		t.Errorf("peak RSS = %v bytes, implausibly small", got["process_resident_memory_peak_bytes"])
+	}
+}
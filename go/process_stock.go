@@ -0,0 +1,14 @@
+//go:build !((darwin && !cgo) || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Linux, Windows and macOS with cgo get the full client_golang process
+// collector.
+func newProcessCollector() prometheus.Collector {
+	return collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})
+}
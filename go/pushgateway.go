@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Job mode. The short-lived subcommands (inspect, demo run) exit before
+// anything could scrape them, so when PUSHGATEWAY_URL is set their final
+// metrics are pushed to a Prometheus Pushgateway on exit instead, grouped
+// by job (PUSHGATEWAY_JOB, default the subcommand name) and instance
+// (PUSHGATEWAY_INSTANCE, default the replica ID). Pushes replace metrics of
+// the same name in the group and always include the standard batch-job
+// series: duration, last completion and whether the run succeeded. The
+// last-success timestamp is only pushed on success, so a failed run leaves
+// the previous one in place for staleness alerts.
+var (
+	pushgatewayURL = getenv("PUSHGATEWAY_URL", "")
+
+	jobDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "job_duration_seconds",
+		Help: "How long the last run of the job took.",
+	})
+	jobLastCompletion = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "job_last_completion_timestamp_seconds",
+		Help: "When the job last finished, successfully or not, as a Unix timestamp.",
+	})
+	jobLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "job_last_success_timestamp_seconds",
+		Help: "When the job last finished successfully, as a Unix timestamp.",
+	})
+	jobSucceeded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "job_succeeded",
+		Help: "Whether the last run of the job succeeded (1) or failed (0).",
+	})
+)
+
+// newJobRegistry returns a registry for a job run: the batch-job series,
+// the job's own collectors, and app_build_info to identify the binary.
+func newJobRegistry(cs ...prometheus.Collector) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(appBuildInfo, jobDuration, jobLastCompletion, jobSucceeded)
+	reg.MustRegister(cs...)
+	return reg
+}
+
+// runJob runs fn and, if a Pushgateway is configured, pushes reg once fn
+// returns. A failed push is logged but does not change fn's result.
+func runJob(name string, reg *prometheus.Registry, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	jobDuration.Set(time.Since(start).Seconds())
+	jobLastCompletion.SetToCurrentTime()
+	if err == nil {
+		jobSucceeded.Set(1)
+		jobLastSuccess.SetToCurrentTime()
+		reg.MustRegister(jobLastSuccess)
+	} else {
+		jobSucceeded.Set(0)
+	}
+	if pushgatewayURL != "" {
+		if perr := pushJobMetrics(pushgatewayURL, getenv("PUSHGATEWAY_JOB", name), getenv("PUSHGATEWAY_INSTANCE", replica.ID), reg); perr != nil {
+			logger.Warn("pushing job metrics failed", "url", pushgatewayURL, "err", perr)
+		}
+	}
+	return err
+}
+
+func pushJobMetrics(url, job, instance string, reg prometheus.Gatherer) error {
+	p := push.New(url, job).Gatherer(reg)
+	if instance != "" {
+		p = p.Grouping("instance", instance)
+	}
+	return p.Add()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunJobPushesToPushgateway(t *testing.T) {
+	var method, path, body string
+	pg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer pg.Close()
+
+	old := pushgatewayURL
+	pushgatewayURL = pg.URL
+	defer func() { pushgatewayURL = old }()
+	t.Setenv("PUSHGATEWAY_INSTANCE", "ci-1")
+
+	if err := runJob("inspect", newJobRegistry(), func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || path != "/metrics/job/inspect/instance/ci-1" {
+		t.Fatalf("push = %s %s, want POST /metrics/job/inspect/instance/ci-1", method, path)
+	}
+	// The body is protobuf-delimited; metric names appear verbatim.
+	for _, name := range []string{"job_succeeded", "job_last_success_timestamp_seconds", "app_build_info"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("pushed body is missing %s", name)
+		}
+	}
+}
+
+func TestRunJobFailureKeepsLastSuccess(t *testing.T) {
+	old := pushgatewayURL
+	pushgatewayURL = ""
+	defer func() { pushgatewayURL = old }()
+
+	reg := newJobRegistry()
+	want := errors.New("boom")
+	if err := runJob("demo", reg, func() error { return want }); err != want {
+		t.Fatalf("runJob = %v, want %v", err, want)
+	}
+	if v := testutil.ToFloat64(jobSucceeded); v != 0 {
+		t.Errorf("job_succeeded = %v, want 0", v)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() == "job_last_success_timestamp_seconds" {
+			t.Error("failed run should not push job_last_success_timestamp_seconds")
+		}
+	}
+}
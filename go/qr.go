@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var (
+	externalURLEnv        = getenv("EXTERNAL_URL", "")
+	podAnnotationsFile    = getenv("PODINFO_ANNOTATIONS", "/etc/podinfo/annotations")
+	externalURLAnnotation = getenv("EXTERNAL_URL_ANNOTATION", "harness.io/external-url")
+)
+
+// externalURL resolves the URL demo audiences should use to reach this
+// instance. EXTERNAL_URL wins; otherwise the ingress URL annotation is read
+// from the pod's downward API annotations file. Returns "" if neither is set.
+func externalURL() string {
+	if externalURLEnv != "" {
+		return externalURLEnv
+	}
+	f, err := os.Open(podAnnotationsFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	return annotationValue(f, externalURLAnnotation)
+}
+
+// annotationValue finds key in a downward API annotations file, where each
+// line has the form key="quoted value".
+func annotationValue(r io.Reader, key string) string {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if !ok || k != key {
+			continue
+		}
+		if uq, err := strconv.Unquote(v); err == nil {
+			return uq
+		}
+		return v
+	}
+	return ""
+}
+
+// printQRBanner writes an ASCII QR code for url so live-demo audiences can
+// scan it straight from the pod logs.
+func printQRBanner(w io.Writer, url string) error {
+	q, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\nScan to open %s\n%s\n", url, q.ToSmallString(false))
+	return err
+}
+
+// qrHandler serves a PNG QR code for the external URL, falling back to the
+// URL the request arrived on. ?size= sets the image size in pixels.
+func qrHandler(w http.ResponseWriter, r *http.Request) {
+	url := externalURL()
+	if url == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		url = scheme + "://" + r.Host + "/"
+	}
+
+	size := 256
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 64 || n > 1024 {
+			writeError(w, http.StatusBadRequest, "size must be between 64 and 1024")
+			return
+		}
+		size = n
+	}
+
+	png, err := qrcode.Encode(url, qrcode.Medium, size)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to encode QR code")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(png)
+}
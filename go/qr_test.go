@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnotationValue(t *testing.T) {
+	file := "app=\"go-demo-app\"\nharness.io/external-url=\"https://demo.example.com/\"\n"
+	got := annotationValue(strings.NewReader(file), "harness.io/external-url")
+	if want := "https://demo.example.com/"; got != want {
+		t.Errorf("annotationValue returned %q want %q", got, want)
+	}
+	if got := annotationValue(strings.NewReader(file), "missing"); got != "" {
+		t.Errorf("annotationValue returned %q for a missing key", got)
+	}
+}
+
+func TestQRHandlerServesPNG(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/qr?size=128", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(qrHandler).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("\x89PNG")) {
+		t.Error("handler did not return a PNG image")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Per-client rate limiting. With RATE_LIMIT_RPS set, each client gets a
+// token bucket refilling at that rate and holding RATE_LIMIT_BURST tokens
+// (default twice the rate, at least 1). Clients are keyed by IP, or by the
+// RATE_LIMIT_KEY_HEADER header when set (e.g. an API key or X-Client-Id).
+// A request with no token left gets 429 with Retry-After set to when one
+// will be, and is counted in rate_limited_total. Probes are exempt. At most
+// RATE_LIMIT_MAX_KEYS buckets are kept; full buckets are dropped first,
+// since a new one starts full anyway.
+var rateLimits = newRateLimiter(
+	getenvFloat("RATE_LIMIT_RPS", 0),
+	getenvInt("RATE_LIMIT_BURST", 0),
+	getenv("RATE_LIMIT_KEY_HEADER", ""),
+	getenvInt("RATE_LIMIT_MAX_KEYS", 10000),
+)
+
+type rateLimiter struct {
+	rps     rate.Limit
+	burst   int
+	header  string
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*rate.Limiter
+}
+
+func newRateLimiter(rps float64, burst int, header string, maxKeys int) *rateLimiter {
+	if burst <= 0 {
+		burst = max(int(math.Ceil(2*rps)), 1)
+	}
+	return &rateLimiter{rps: rate.Limit(rps), burst: burst, header: header, maxKeys: max(maxKeys, 1), buckets: make(map[string]*rate.Limiter)}
+}
+
+func (l *rateLimiter) enabled() bool { return l.rps > 0 }
+
+// key identifies the client r came from.
+func (l *rateLimiter) key(r *http.Request) string {
+	if l.header != "" {
+		if v := r.Header.Get(l.header); v != "" {
+			return v
+		}
+	}
+	return clientIP(r)
+}
+
+// allow takes a token from key's bucket, or returns how long until one is
+// available.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= l.maxKeys {
+			l.evict(now)
+		}
+		b = rate.NewLimiter(l.rps, l.burst)
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+
+	res := b.ReserveN(now, 1)
+	if d := res.DelayFrom(now); d > 0 {
+		res.CancelAt(now)
+		return false, d
+	}
+	return true, 0
+}
+
+// evict drops full buckets, or an arbitrary one if none are full. l.mu
+// must be held.
+func (l *rateLimiter) evict(now time.Time) {
+	for k, b := range l.buckets {
+		if b.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, k)
+		}
+	}
+	for k := range l.buckets {
+		if len(l.buckets) < l.maxKeys {
+			break
+		}
+		delete(l.buckets, k)
+	}
+}
+
+func withRateLimit() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !rateLimits.enabled() || isProbePath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, wait := rateLimits.allow(rateLimits.key(r), time.Now()); !ok {
+				rateLimited.WithLabelValues(routeLabel(r)).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
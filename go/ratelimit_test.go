@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimiterAllowsBurstThenWaits(t *testing.T) {
+	l := newRateLimiter(2, 3, "", 10)
+	now := time.Now()
+	for i := range 3 {
+		if ok, _ := l.allow("10.0.0.1", now); !ok {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+	ok, wait := l.allow("10.0.0.1", now)
+	if ok || wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("over the burst: ok=%v wait=%v, want limited for up to 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("10.0.0.2", now); !ok {
+		t.Error("another client shares the first client's bucket")
+	}
+	if ok, _ := l.allow("10.0.0.1", now.Add(wait)); !ok {
+		t.Error("still limited after the advertised wait")
+	}
+}
+
+func TestRateLimiterDefaultsAndEviction(t *testing.T) {
+	if l := newRateLimiter(0.2, 0, "", 10); l.burst != 1 {
+		t.Errorf("burst for 0.2 rps = %d, want 1", l.burst)
+	}
+	if l := newRateLimiter(5, 0, "", 10); l.burst != 10 {
+		t.Errorf("burst for 5 rps = %d, want 10", l.burst)
+	}
+
+	l := newRateLimiter(1, 1, "", 2)
+	now := time.Now()
+	l.allow("a", now)
+	l.allow("b", now)
+	l.allow("c", now)
+	if len(l.buckets) > 2 {
+		t.Errorf("kept %d buckets, want at most 2", len(l.buckets))
+	}
+}
+
+func TestRateLimiterKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/info", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	if got := newRateLimiter(1, 1, "", 1).key(r); got != "192.0.2.7" {
+		t.Errorf("key = %q, want the client IP", got)
+	}
+	l := newRateLimiter(1, 1, "X-Client-Id", 1)
+	if got := l.key(r); got != "192.0.2.7" {
+		t.Errorf("key without the header = %q, want the client IP", got)
+	}
+	r.Header.Set("X-Client-Id", "tenant-a")
+	if got := l.key(r); got != "tenant-a" {
+		t.Errorf("key = %q, want the header value", got)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	prev := rateLimits
+	rateLimits = newRateLimiter(0.5, 1, "", 10)
+	t.Cleanup(func() { rateLimits = prev })
+
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/api/limited", chain(ok, withRateLimit()))
+	mux.Handle("/healthz", chain(ok, withRateLimit()))
+
+	before := testutil.ToFloat64(rateLimited.WithLabelValues("/api/limited"))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/limited", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/limited", nil))
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "2" {
+		t.Errorf("second request: status %d Retry-After %q, want 429 and 2", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(rateLimited.WithLabelValues("/api/limited")); got != before+1 {
+		t.Errorf("rate_limited_total grew by %v, want 1", got-before)
+	}
+	for range 3 {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("probe was rate limited: status %d", rr.Code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// READ_ONLY=true makes the app safe to expose on public demo URLs: every
+// route still answers reads, but anything that changes state (non-GET
+// methods, and the whole admin API, chaos controls included) gets a 403.
+var readOnly = getenvBool("READ_ONLY", false)
+
+func init() {
+	registerSubsystem(func() []subsystemStatus {
+		if readOnly {
+			return []subsystemStatus{{"mutations", "disabled", "READ_ONLY", "READ_ONLY is set; mutating and admin APIs answer 403"}}
+		}
+		return []subsystemStatus{{"mutations", "ok", "MUTATIONS_OK", ""}}
+	})
+}
+
+// allowedWhenReadOnly reports whether r may be served in read-only mode.
+func allowedWhenReadOnly(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return !strings.HasPrefix(r.URL.Path, "/api/admin/")
+	}
+	return false
+}
+
+func withReadOnly() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if readOnly && !allowedWhenReadOnly(r) {
+				writeError(w, http.StatusForbidden, "the app is in read-only mode")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
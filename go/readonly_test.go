@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnlyRejectsMutations(t *testing.T) {
+	readOnly = true
+	t.Cleanup(func() { readOnly = false })
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withReadOnly())
+
+	tests := []struct {
+		method, target string
+		want           int
+	}{
+		{"GET", "/api/info", http.StatusOK},
+		{"HEAD", "/api/kv/k", http.StatusOK},
+		{"PUT", "/api/kv/k", http.StatusForbidden},
+		{"DELETE", "/api/kv/k", http.StatusForbidden},
+		{"POST", "/api/admin/pause-traffic", http.StatusForbidden},
+		{"GET", "/api/admin/loggen", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: got %d want %d", tt.method, tt.target, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestReadOnlyOffPassesEverything(t *testing.T) {
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withReadOnly())
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/kv/k", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("PUT with READ_ONLY unset: got %d", rr.Code)
+	}
+}
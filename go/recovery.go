@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// withRecovery turns a panicking handler into a JSON 500 carrying the
+// request ID, instead of net/http dropping the connection. The panic and
+// its stack are logged and counted in panics_total. If the handler had
+// already started the response, the connection is aborted so the client
+// sees a truncated reply rather than a bogus success. http.ErrAbortHandler
+// is passed through.
+func withRecovery() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cw := &rwCapture{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(v)
+				}
+				panicsTotal.WithLabelValues(routeLabel(r)).Inc()
+				loggerFrom(r.Context()).ErrorContext(r.Context(), "handler panicked",
+					"panic", fmt.Sprint(v),
+					"stack", string(debug.Stack()),
+				)
+				if cw.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				writeError(cw, http.StatusInternalServerError, "internal server error")
+			}()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithRecovery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/boom", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("kaboom")
+	}), withRequestID(), withRecovery()))
+	mux.Handle("/late", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("kaboom")
+	}), withRecovery()))
+	before := testutil.ToFloat64(panicsTotal.WithLabelValues("/boom"))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/boom", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rr.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["requestId"] == "" || body["requestId"] != rr.Header().Get(requestIDHeader) {
+		t.Errorf("body = %v, want the request ID", body)
+	}
+	if got := testutil.ToFloat64(panicsTotal.WithLabelValues("/boom")) - before; got != 1 {
+		t.Errorf("panics_total rose by %v, want 1", got)
+	}
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("panic after the response started: recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/late", nil))
+}
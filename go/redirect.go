@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPS redirect and HSTS. With TLS enabled, HTTP_REDIRECT_ADDR (e.g.
+// ":80"; empty, the default, disables it) runs a plain-HTTP listener that
+// answers every request with a 301 to the same host and path over HTTPS.
+// The redirect targets the app's port unless HTTPS_REDIRECT_PORT says
+// otherwise (an ingress on 443 in front of the app, say); port 443 is left
+// out of the URL. HSTS_MAX_AGE (default 0, off) adds
+// Strict-Transport-Security to responses served over TLS, with
+// includeSubDomains and preload when HSTS_INCLUDE_SUBDOMAINS and
+// HSTS_PRELOAD are set.
+var (
+	httpRedirectAddr  = getenv("HTTP_REDIRECT_ADDR", "")
+	httpsRedirectPort = getenv("HTTPS_REDIRECT_PORT", "")
+	hstsHeader        = hstsValue(
+		getenvDuration("HSTS_MAX_AGE", 0),
+		getenvBool("HSTS_INCLUDE_SUBDOMAINS", false),
+		getenvBool("HSTS_PRELOAD", false),
+	)
+)
+
+// hstsValue returns the Strict-Transport-Security value, or "" for none.
+func hstsValue(maxAge time.Duration, subdomains, preload bool) string {
+	if maxAge <= 0 {
+		return ""
+	}
+	v := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if subdomains {
+		v += "; includeSubDomains"
+	}
+	if preload {
+		v += "; preload"
+	}
+	return v
+}
+
+// httpsRedirectHandler redirects to the HTTPS URL for the request on
+// port, the port the HTTPS listener is reached on.
+func httpsRedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" && port != "443" {
+			host += ":" + port
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
+
+// startHTTPSRedirect starts the redirect listener and returns its address
+// and shutdown function.
+func startHTTPSRedirect(addr, port string) (string, func(context.Context) error, error) {
+	ln, err := listen(addr)
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: httpsRedirectHandler(port), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTPS redirect listener failed", "addr", addr, "err", err)
+		}
+	}()
+	logger.Info("HTTPS redirect listener starting", "addr", ln.Addr().String(), "httpsPort", port)
+	return ln.Addr().String(), srv.Shutdown, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPSRedirect(t *testing.T) {
+	cases := []struct {
+		port, host, target, want string
+	}{
+		{"443", "demo.example.com", "/api/info?x=1", "https://demo.example.com/api/info?x=1"},
+		{"8443", "demo.example.com:8080", "/", "https://demo.example.com:8443/"},
+		{"8443", "[::1]:80", "/a", "https://[::1]:8443/a"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", tc.target, nil)
+		r.Host = tc.host
+		rr := httptest.NewRecorder()
+		httpsRedirectHandler(tc.port).ServeHTTP(rr, r)
+		if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != tc.want {
+			t.Errorf("%s%s on %s: status %d Location %q, want 301 %q", tc.host, tc.target, tc.port, rr.Code, rr.Header().Get("Location"), tc.want)
+		}
+	}
+}
+
+func TestHSTS(t *testing.T) {
+	if got := hstsValue(0, true, true); got != "" {
+		t.Errorf("zero max-age gave %q", got)
+	}
+	if got := hstsValue(365*24*time.Hour, true, true); got != "max-age=31536000; includeSubDomains; preload" {
+		t.Errorf("hsts = %q", got)
+	}
+
+	prev := hstsHeader
+	hstsHeader = hstsValue(time.Hour, false, false)
+	t.Cleanup(func() { hstsHeader = prev })
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withSecurityHeaders())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS sent over plain HTTP")
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, r)
+	if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=3600" {
+		t.Errorf("HSTS over TLS = %q", got)
+	}
+}
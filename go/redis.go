@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Optional Redis dependency. When REDIS_ADDR is set a "redis" readiness check
+// sends PING (after AUTH if REDIS_PASSWORD is set) over a fresh connection.
+// This speaks just enough RESP for a health check, so no client library is
+// needed.
+type redisCheck struct {
+	addr     string
+	username string
+	password string
+	timeout  time.Duration
+
+	mu        sync.Mutex
+	latency   time.Duration
+	lastError string
+}
+
+type redisStatus struct {
+	Addr      string `json:"addr"`
+	LatencyMs int64  `json:"latencyMs"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+func registerRedis(addr string) {
+	rc := &redisCheck{
+		addr:     addr,
+		username: getenv("REDIS_USERNAME", ""),
+		password: getenv("REDIS_PASSWORD", ""),
+		timeout:  getenvDuration("REDIS_TIMEOUT", time.Second),
+	}
+	health.registerDetailed("redis", probeReady, rc.check, rc.details)
+}
+
+func (rc *redisCheck) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := rc.ping(ctx)
+	latency := time.Since(start)
+
+	rc.mu.Lock()
+	rc.latency = latency
+	rc.lastError = ""
+	if err != nil {
+		rc.lastError = err.Error()
+	}
+	rc.mu.Unlock()
+
+	redisPingLatency.Set(latency.Seconds())
+	trackDependencyCall(ctx, "redis", latency)
+	return err
+}
+
+func (rc *redisCheck) ping(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", rc.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	br := bufio.NewReader(conn)
+
+	if rc.password != "" {
+		args := []string{"AUTH", rc.password}
+		if rc.username != "" {
+			args = []string{"AUTH", rc.username, rc.password}
+		}
+		if _, err := redisCommand(conn, br, args...); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	reply, err := redisCommand(conn, br, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected PING reply %q", reply)
+	}
+	return nil
+}
+
+// redisCommand sends args as a RESP array and returns a simple-string reply.
+func redisCommand(conn net.Conn, br *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", err
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case strings.HasPrefix(line, "+"):
+		return line[1:], nil
+	case strings.HasPrefix(line, "-"):
+		return "", errors.New(line[1:])
+	default:
+		return "", fmt.Errorf("unexpected reply %q", line)
+	}
+}
+
+func (rc *redisCheck) details() any {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return redisStatus{Addr: rc.addr, LatencyMs: rc.latency.Milliseconds(), LastError: rc.lastError}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers PING with PONG and AUTH according to password.
+func fakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					var args []string
+					header, err := br.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n := 0
+					for _, c := range strings.TrimSpace(header[1:]) {
+						n = n*10 + int(c-'0')
+					}
+					for i := 0; i < n; i++ {
+						_, _ = br.ReadString('\n')
+						arg, _ := br.ReadString('\n')
+						args = append(args, strings.TrimSpace(arg))
+					}
+					switch {
+					case args[0] == "AUTH" && args[len(args)-1] == password:
+						conn.Write([]byte("+OK\r\n"))
+					case args[0] == "AUTH":
+						conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+					case args[0] == "PING":
+						conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestRedisCheck(t *testing.T) {
+	addr := fakeRedis(t, "hunter2")
+
+	rc := &redisCheck{addr: addr, password: "hunter2", timeout: time.Second}
+	if err := rc.check(context.Background()); err != nil {
+		t.Errorf("check returned error: %v", err)
+	}
+
+	rc = &redisCheck{addr: addr, password: "wrong", timeout: time.Second}
+	if err := rc.check(context.Background()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected auth failure, got %v", err)
+	}
+	if st := rc.details().(redisStatus); st.LastError == "" {
+		t.Error("expected last error to be reported in details")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"os"
+)
+
+// Replica identity. Every instance gets a short ID and a color derived from
+// its pod name (POD_NAME, falling back to the hostname), so the same pod
+// keeps its identity across restarts and load-balancing across replicas is
+// easy to see in demos. REPLICA_ID overrides the derived ID.
+type replicaIdentity struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	ColorName string `json:"colorName"`
+}
+
+// replicaPalette holds colors that stay distinguishable on the dark UI.
+var replicaPalette = []struct{ name, hex string }{
+	{"blue", "#4f8cff"},
+	{"green", "#3ecf8e"},
+	{"orange", "#ff9f43"},
+	{"pink", "#ff6bcb"},
+	{"purple", "#a78bfa"},
+	{"teal", "#2dd4bf"},
+	{"yellow", "#facc15"},
+	{"red", "#f87171"},
+	{"lime", "#a3e635"},
+	{"cyan", "#38bdf8"},
+	{"rose", "#fb7185"},
+	{"amber", "#fbbf24"},
+}
+
+var replica = newReplicaIdentity(replicaName(), getenv("REPLICA_ID", ""))
+
+func replicaName() string {
+	if n := getenv("POD_NAME", ""); n != "" {
+		return n
+	}
+	h, _ := os.Hostname()
+	return h
+}
+
+func newReplicaIdentity(name, id string) replicaIdentity {
+	sum := sha256.Sum256([]byte(name))
+	if id == "" {
+		id = hex.EncodeToString(sum[:3])
+	}
+	c := replicaPalette[binary.BigEndian.Uint32(sum[:4])%uint32(len(replicaPalette))]
+	return replicaIdentity{ID: id, Name: name, Color: c.hex, ColorName: c.name}
+}
+
+// withReplicaHeaders stamps every response with the replica's identity.
+func withReplicaHeaders() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Replica-Id", replica.ID)
+			w.Header().Set("X-Replica-Color", replica.Color)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
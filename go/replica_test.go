@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReplicaIdentityIsStable(t *testing.T) {
+	a := newReplicaIdentity("go-demo-app-7d9f8c-x2k4p", "")
+	b := newReplicaIdentity("go-demo-app-7d9f8c-x2k4p", "")
+	if a != b {
+		t.Errorf("identity not stable: %+v vs %+v", a, b)
+	}
+	if len(a.ID) != 6 || a.Color == "" || a.ColorName == "" {
+		t.Errorf("incomplete identity: %+v", a)
+	}
+	if c := newReplicaIdentity("go-demo-app-7d9f8c-x2k4p", "blue-1"); c.ID != "blue-1" || c.Color != a.Color {
+		t.Errorf("REPLICA_ID should override only the ID: %+v", c)
+	}
+}
+
+func TestReplicaColorsSpread(t *testing.T) {
+	seen := map[string]bool{}
+	for _, name := range []string{"pod-a", "pod-b", "pod-c", "pod-d", "pod-e", "pod-f", "pod-g", "pod-h"} {
+		seen[newReplicaIdentity(name, "").Color] = true
+	}
+	if len(seen) < 3 {
+		t.Errorf("8 pods only produced %d distinct colors", len(seen))
+	}
+}
+
+func TestWithReplicaHeaders(t *testing.T) {
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withReplicaHeaders())
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if got := rr.Header().Get("X-Replica-Id"); got != replica.ID {
+		t.Errorf("X-Replica-Id: got %q want %q", got, replica.ID)
+	}
+	if got := rr.Header().Get("X-Replica-Color"); got != replica.Color {
+		t.Errorf("X-Replica-Color: got %q want %q", got, replica.Color)
+	}
+}
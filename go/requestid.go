@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// Request IDs. Every request carries an X-Request-ID: the caller's, if it
+// sent a sane one, or a fresh UUIDv7 (time-ordered, so IDs sort by arrival).
+// The ID is stored in the request context, set on the request for anything
+// forwarding it, echoed in the response header, logged with the access log
+// and included in JSON error bodies as "requestId".
+//
+// X-Correlation-ID is passed through the same way and defaults to the
+// request ID. Outbound calls made with outboundClient while serving a
+// request forward both, plus the trace context, so a chain of these apps
+// can be followed end to end.
+const (
+	requestIDHeader     = "X-Request-ID"
+	correlationIDHeader = "X-Correlation-ID"
+)
+
+type (
+	requestIDKey     struct{}
+	correlationIDKey struct{}
+)
+
+// requestIDFrom returns the request ID stored by withRequestID, or "".
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts up to 128 printable ASCII characters without
+// spaces, so a client can't inject anything odd into logs or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// correlationIDFrom returns the correlation ID stored by withRequestID, or
+// "".
+func correlationIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	if id, err := uuid.NewV7(); err == nil {
+		return id.String()
+	}
+	return uuid.NewString()
+}
+
+func withRequestID() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+				r.Header.Set(requestIDHeader, id)
+			}
+			cid := r.Header.Get(correlationIDHeader)
+			if !validRequestID(cid) {
+				cid = id
+				r.Header.Set(correlationIDHeader, cid)
+			}
+			w.Header().Set(requestIDHeader, id)
+			w.Header().Set(correlationIDHeader, cid)
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = context.WithValue(ctx, correlationIDKey{}, cid)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// correlationTransport forwards the current request's request and
+// correlation IDs on outbound calls.
+type correlationTransport struct {
+	base http.RoundTripper
+}
+
+func (t correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, cid := requestIDFrom(req.Context()), correlationIDFrom(req.Context())
+	if id != "" || cid != "" {
+		req = req.Clone(req.Context())
+		if id != "" {
+			req.Header.Set(requestIDHeader, id)
+		}
+		if cid != "" {
+			req.Header.Set(correlationIDHeader, cid)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections lets outboundClient.CloseIdleConnections reach the
+// wrapped transport.
+func (t correlationTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWithRequestID(t *testing.T) {
+	var seen string
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFrom(r.Context())
+		writeError(w, http.StatusBadRequest, "nope")
+	}), withRequestID())
+
+	for _, tc := range []struct {
+		name, in string
+		keep     bool
+	}{
+		{"generated", "", false},
+		{"passed through", "abc-123", true},
+		{"invalid replaced", "bad id\n", false},
+		{"too long replaced", strings.Repeat("x", 200), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.in != "" {
+				req.Header.Set(requestIDHeader, tc.in)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(requestIDHeader)
+			if got != seen {
+				t.Errorf("header %q != context %q", got, seen)
+			}
+			if tc.keep && got != tc.in {
+				t.Errorf("request ID = %q, want %q", got, tc.in)
+			}
+			if !tc.keep {
+				if u, err := uuid.Parse(got); err != nil || u.Version() != 7 {
+					t.Errorf("request ID = %q, want a UUIDv7", got)
+				}
+			}
+			var body map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["requestId"] != got {
+				t.Errorf("error body requestId = %q, want %q", body["requestId"], got)
+			}
+		})
+	}
+}
+
+func TestOutboundClientForwardsCorrelation(t *testing.T) {
+	withTestTracer(t)
+	var got http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer downstream.Close()
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, downstream.URL, nil)
+		resp, err := outboundClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}), withRequestID(), withTracing())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/info", nil)
+	req.Header.Set(correlationIDHeader, "order-77")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if got.Get(requestIDHeader) == "" || got.Get(requestIDHeader) != rr.Header().Get(requestIDHeader) {
+		t.Errorf("forwarded request ID %q, response has %q", got.Get(requestIDHeader), rr.Header().Get(requestIDHeader))
+	}
+	if got.Get(correlationIDHeader) != "order-77" {
+		t.Errorf("forwarded correlation ID = %q, want order-77", got.Get(correlationIDHeader))
+	}
+	tc, ok := parseTraceparent(got.Get("traceparent"))
+	if !ok || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("forwarded traceparent = %q, want the same trace", got.Get("traceparent"))
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Per-route saturation. Every route's in-flight requests are tracked, and
+// ROUTE_CONCURRENCY ("/api/kv/{key}=10,/api/info=50", keyed by mux pattern)
+// caps individual routes with a semaphore. Requests over the cap queue for
+// up to ROUTE_QUEUE_TIMEOUT and are then rejected with 503. Concurrency,
+// queue wait and rejections are exported per route and summarized,
+// together with traffic, errors and latency, at /api/metrics/summary for
+// canary analysis.
+var routeLimits = newRouteLimits(
+	mustParseRouteConcurrency(getenv("ROUTE_CONCURRENCY", "")),
+	getenvDuration("ROUTE_QUEUE_TIMEOUT", time.Second),
+)
+
+type routeLimitSet struct {
+	queueTimeout time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeLimiter
+	limits map[string]int
+}
+
+type routeLimiter struct {
+	// sem is nil for routes without a limit.
+	sem        chan struct{}
+	mu         sync.Mutex
+	inFlight   int
+	peak       int
+	queued     int
+	rejections int64
+}
+
+func parseRouteConcurrency(spec string) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		// Split on the last '=' in case a pattern contains one.
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid route limit %q, want pattern=limit", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(pair[i+1:]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid route limit %q, want a positive integer", pair)
+		}
+		out[strings.TrimSpace(pair[:i])] = n
+	}
+	return out, nil
+}
+
+func mustParseRouteConcurrency(spec string) map[string]int {
+	l, err := parseRouteConcurrency(spec)
+	if err != nil {
+		invalidConfig("ROUTE_CONCURRENCY", err)
+	}
+	return l
+}
+
+func newRouteLimits(limits map[string]int, queueTimeout time.Duration) *routeLimitSet {
+	return &routeLimitSet{queueTimeout: queueTimeout, limits: limits, routes: make(map[string]*routeLimiter)}
+}
+
+func (s *routeLimitSet) get(route string) *routeLimiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.routes[route]
+	if !ok {
+		l = &routeLimiter{}
+		if n := s.limits[route]; n > 0 {
+			l.sem = make(chan struct{}, n)
+		}
+		s.routes[route] = l
+	}
+	return l
+}
+
+// acquire takes a slot on a limited route, waiting up to the queue timeout
+// or until the request is cancelled. It reports whether a slot was taken.
+func (s *routeLimitSet) acquire(r *http.Request, route string, l *routeLimiter) bool {
+	if l.sem == nil {
+		return true
+	}
+	select {
+	case l.sem <- struct{}{}:
+		httpRouteQueueWait.WithLabelValues(route).Observe(0)
+		return true
+	default:
+	}
+
+	l.mu.Lock()
+	l.queued++
+	l.mu.Unlock()
+	httpRouteQueued.WithLabelValues(route).Inc()
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+		httpRouteQueued.WithLabelValues(route).Dec()
+	}()
+
+	start := time.Now()
+	t := time.NewTimer(s.queueTimeout)
+	defer t.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		httpRouteQueueWait.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		return true
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+	l.mu.Lock()
+	l.rejections++
+	l.mu.Unlock()
+	httpRouteRejections.WithLabelValues(route).Inc()
+	return false
+}
+
+func withRouteConcurrency() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeLabel(r)
+			l := routeLimits.get(route)
+			if !routeLimits.acquire(r, route, l) {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable, "route is at its concurrency limit")
+				return
+			}
+			if l.sem != nil {
+				defer func() { <-l.sem }()
+			}
+
+			l.mu.Lock()
+			l.inFlight++
+			l.peak = max(l.peak, l.inFlight)
+			l.mu.Unlock()
+			httpRouteConcurrency.WithLabelValues(route).Inc()
+			defer func() {
+				l.mu.Lock()
+				l.inFlight--
+				l.mu.Unlock()
+				httpRouteConcurrency.WithLabelValues(route).Dec()
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeSummary is one route's golden signals. Traffic, error and latency
+// figures are cumulative since start; latency quantiles are estimated from
+// the http_request_duration_seconds buckets.
+type routeSummary struct {
+	Route        string  `json:"route"`
+	Requests     uint64  `json:"requests"`
+	Errors       uint64  `json:"errors"`
+	ErrorRatio   float64 `json:"errorRatio"`
+	LatencyP50Ms float64 `json:"latencyP50Ms"`
+	LatencyP95Ms float64 `json:"latencyP95Ms"`
+	LatencyP99Ms float64 `json:"latencyP99Ms"`
+	InFlight     int     `json:"inFlight"`
+	PeakInFlight int     `json:"peakInFlight"`
+	Limit        int     `json:"limit,omitempty"`
+	Queued       int     `json:"queued"`
+	Rejections   int64   `json:"rejections"`
+}
+
+// requestLatency is http_request_duration_seconds folded over status
+// classes: total and 5xx counts plus cumulative bucket counts by bound.
+type requestLatency struct {
+	count, errors uint64
+	buckets       map[float64]uint64
+}
+
+func (a *requestLatency) add(b *requestLatency) {
+	a.count += b.count
+	a.errors += b.errors
+	for bound, c := range b.buckets {
+		a.buckets[bound] += c
+	}
+}
+
+// requestLatencyByRoute gathers http_request_duration_seconds from
+// metricsRegistry and aggregates it per route.
+func requestLatencyByRoute() (map[string]*requestLatency, error) {
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	byRoute := make(map[string]*requestLatency)
+	for _, f := range families {
+		if f.GetName() != "http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			var route, class string
+			for _, lp := range m.GetLabel() {
+				switch lp.GetName() {
+				case "route":
+					route = lp.GetValue()
+				case "status_class":
+					class = lp.GetValue()
+				}
+			}
+			a, ok := byRoute[route]
+			if !ok {
+				a = &requestLatency{buckets: make(map[float64]uint64)}
+				byRoute[route] = a
+			}
+			h := m.GetHistogram()
+			a.count += h.GetSampleCount()
+			if class == "5xx" {
+				a.errors += h.GetSampleCount()
+			}
+			for _, b := range h.GetBucket() {
+				a.buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+			}
+		}
+	}
+	return byRoute, nil
+}
+
+func routeSummaries() ([]routeSummary, error) {
+	byRoute, err := requestLatencyByRoute()
+	if err != nil {
+		return nil, err
+	}
+
+	routeLimits.mu.Lock()
+	limiters := make(map[string]*routeLimiter, len(routeLimits.routes))
+	for route, l := range routeLimits.routes {
+		limiters[route] = l
+		if _, ok := byRoute[route]; !ok {
+			byRoute[route] = &requestLatency{}
+		}
+	}
+	limits := routeLimits.limits
+	routeLimits.mu.Unlock()
+
+	out := make([]routeSummary, 0, len(byRoute))
+	for route, a := range byRoute {
+		s := routeSummary{Route: route, Requests: a.count, Errors: a.errors, Limit: limits[route]}
+		if a.count > 0 {
+			s.ErrorRatio = float64(a.errors) / float64(a.count)
+			s.LatencyP50Ms = bucketQuantile(0.5, a.buckets, a.count) * 1000
+			s.LatencyP95Ms = bucketQuantile(0.95, a.buckets, a.count) * 1000
+			s.LatencyP99Ms = bucketQuantile(0.99, a.buckets, a.count) * 1000
+		}
+		if l := limiters[route]; l != nil {
+			l.mu.Lock()
+			s.InFlight, s.PeakInFlight, s.Queued, s.Rejections = l.inFlight, l.peak, l.queued, l.rejections
+			l.mu.Unlock()
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out, nil
+}
+
+// bucketQuantile estimates quantile q from cumulative histogram buckets by
+// linear interpolation within the bucket it falls in, like PromQL's
+// histogram_quantile. Observations beyond the last bucket report its bound.
+func bucketQuantile(q float64, buckets map[float64]uint64, count uint64) float64 {
+	bounds := make([]float64, 0, len(buckets))
+	for b := range buckets {
+		bounds = append(bounds, b)
+	}
+	sort.Float64s(bounds)
+	rank := q * float64(count)
+	var prevBound float64
+	var prevCount uint64
+	for _, b := range bounds {
+		c := buckets[b]
+		if float64(c) >= rank {
+			if math.IsInf(b, 1) || c == prevCount {
+				return prevBound
+			}
+			return prevBound + (b-prevBound)*(rank-float64(prevCount))/float64(c-prevCount)
+		}
+		prevBound, prevCount = b, c
+	}
+	return prevBound
+}
+
+// metricsSummaryHandler serves GET /api/metrics/summary.
+func metricsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	routes, err := routeSummaries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "gather metrics: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"routes": routes})
+}
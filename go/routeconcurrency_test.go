@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseRouteConcurrency(t *testing.T) {
+	got, err := parseRouteConcurrency("/api/kv/{key}=10, GET /api/info = 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["/api/kv/{key}"] != 10 || got["GET /api/info"] != 5 {
+		t.Errorf("parsed %v", got)
+	}
+	for _, bad := range []string{"/api/info", "/api/info=0", "/api/info=many"} {
+		if _, err := parseRouteConcurrency(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestRouteConcurrencyQueuesThenRejects(t *testing.T) {
+	prev := routeLimits
+	routeLimits = newRouteLimits(map[string]int{"/api/slow": 1}, 50*time.Millisecond)
+	t.Cleanup(func() { routeLimits = prev })
+
+	release := make(chan struct{})
+	entered := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.Handle("/api/slow", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}), withRouteConcurrency()))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/slow", nil))
+	}()
+	<-entered
+
+	if got := testutil.ToFloat64(httpRouteConcurrency.WithLabelValues("/api/slow")); got != 1 {
+		t.Errorf("in-flight gauge = %v, want 1", got)
+	}
+	before := testutil.ToFloat64(httpRouteRejections.WithLabelValues("/api/slow"))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/slow", nil))
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("over the limit: status %d Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if got := testutil.ToFloat64(httpRouteRejections.WithLabelValues("/api/slow")); got != before+1 {
+		t.Errorf("rejections grew by %v, want 1", got-before)
+	}
+
+	// A queued request gets the slot once it frees up.
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/slow", nil))
+		done <- rr.Code
+	}()
+	time.Sleep(10 * time.Millisecond)
+	release <- struct{}{}
+	<-entered
+	release <- struct{}{}
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("queued request: status %d, want 200", code)
+	}
+	wg.Wait()
+}
+
+func TestBucketQuantile(t *testing.T) {
+	buckets := map[float64]uint64{0.1: 50, 0.5: 90, 1: 100}
+	if got := bucketQuantile(0.5, buckets, 100); got != 0.1 {
+		t.Errorf("p50 = %v, want 0.1", got)
+	}
+	if got := bucketQuantile(0.7, buckets, 100); got < 0.29 || got > 0.31 {
+		t.Errorf("p70 = %v, want 0.3", got)
+	}
+	if got := bucketQuantile(0.99, map[float64]uint64{0.1: 1}, 2); got != 0.1 {
+		t.Errorf("beyond the last bucket = %v, want 0.1", got)
+	}
+}
+
+func TestMetricsSummaryHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/api/summarized", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}), withMetrics(), withRouteConcurrency()))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/summarized", nil))
+
+	rr := httptest.NewRecorder()
+	metricsSummaryHandler(rr, httptest.NewRequest("GET", "/api/metrics/summary", nil))
+	var resp struct {
+		Routes []routeSummary `json:"routes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range resp.Routes {
+		if s.Route == "/api/summarized" {
+			if s.Requests == 0 || s.Errors != s.Requests || s.ErrorRatio != 1 || s.PeakInFlight != 1 {
+				t.Errorf("summary = %+v", s)
+			}
+			return
+		}
+	}
+	t.Errorf("no summary for /api/summarized in %+v", resp.Routes)
+}
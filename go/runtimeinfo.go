@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GET /api/runtime reports what the Go runtime actually sees, for
+// debugging container sizing: GOMAXPROCS against the CPU count and cgroup
+// CPU quota, the effective GOGC and GOMEMLIMIT against the cgroup memory
+// limit, plus goroutines, GC and heap figures. The raw GOMAXPROCS, GOGC,
+// GOMEMLIMIT and GODEBUG environment values are included so a mismatch
+// between what was configured and what took effect is easy to spot. Like
+// /api/stats it is subject to the debug access policy.
+type runtimeInfo struct {
+	GoVersion  string            `json:"goVersion"`
+	GOMAXPROCS int               `json:"gomaxprocs"`
+	NumCPU     int               `json:"numCPU"`
+	Goroutines int               `json:"goroutines"`
+	GC         runtimeGC         `json:"gc"`
+	Heap       runtimeHeap       `json:"heap"`
+	Cgroup     runtimeCgroup     `json:"cgroup"`
+	Env        map[string]string `json:"env"`
+}
+
+type runtimeGC struct {
+	GOGCPercent      int64      `json:"gogcPercent"`
+	MemoryLimitBytes *uint64    `json:"memoryLimitBytes"` // null when unlimited
+	Cycles           uint32     `json:"cycles"`
+	ForcedCycles     uint32     `json:"forcedCycles"`
+	LastGC           *time.Time `json:"lastGC,omitempty"`
+	PauseTotalMs     float64    `json:"pauseTotalMs"`
+	NextGCBytes      uint64     `json:"nextGCBytes"`
+	CPUFraction      float64    `json:"cpuFraction"`
+}
+
+type runtimeHeap struct {
+	InuseBytes    uint64 `json:"inuseBytes"`
+	AllocBytes    uint64 `json:"allocBytes"`
+	SysBytes      uint64 `json:"sysBytes"`
+	ReleasedBytes uint64 `json:"releasedBytes"`
+	Objects       uint64 `json:"objects"`
+}
+
+// runtimeCgroup limits are null when unlimited or not in a cgroup.
+type runtimeCgroup struct {
+	CPULimitCores    *float64 `json:"cpuLimitCores"`
+	MemoryLimitBytes *uint64  `json:"memoryLimitBytes"`
+}
+
+var (
+	cgroupCPUMaxFile  = "/sys/fs/cgroup/cpu.max" // cgroup v2
+	cgroupCPUQuotaDir = "/sys/fs/cgroup/cpu"     // cgroup v1: cpu.cfs_quota_us, cpu.cfs_period_us
+)
+
+// cgroupCPULimit returns the container's CPU quota in cores, or 0 if there
+// is none.
+func cgroupCPULimit() (float64, error) {
+	if b, err := os.ReadFile(cgroupCPUMaxFile); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) == 0 || fields[0] == "max" {
+			return 0, nil
+		}
+		period := 100000.0
+		if len(fields) > 1 {
+			if period, err = strconv.ParseFloat(fields[1], 64); err != nil {
+				return 0, err
+			}
+		}
+		quota, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, err
+		}
+		return quota / period, nil
+	}
+	qb, err := os.ReadFile(cgroupCPUQuotaDir + "/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, err
+	}
+	quota, err := strconv.ParseFloat(strings.TrimSpace(string(qb)), 64)
+	if err != nil || quota <= 0 { // -1 means no quota
+		return 0, err
+	}
+	pb, err := os.ReadFile(cgroupCPUQuotaDir + "/cpu.cfs_period_us")
+	if err != nil {
+		return 0, err
+	}
+	period, err := strconv.ParseFloat(strings.TrimSpace(string(pb)), 64)
+	if err != nil || period <= 0 {
+		return 0, err
+	}
+	return quota / period, nil
+}
+
+func collectRuntimeInfo() runtimeInfo {
+	samples := []metrics.Sample{
+		{Name: "/gc/gogc:percent"},
+		{Name: "/gc/gomemlimit:bytes"},
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}
+	metrics.Read(samples)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	info := runtimeInfo{
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		GC: runtimeGC{
+			Cycles:       ms.NumGC,
+			ForcedCycles: ms.NumForcedGC,
+			PauseTotalMs: float64(ms.PauseTotalNs) / 1e6,
+			NextGCBytes:  ms.NextGC,
+		},
+		Heap: runtimeHeap{
+			InuseBytes:    ms.HeapInuse,
+			AllocBytes:    ms.HeapAlloc,
+			SysBytes:      ms.HeapSys,
+			ReleasedBytes: ms.HeapReleased,
+			Objects:       ms.HeapObjects,
+		},
+		Env: map[string]string{},
+	}
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		// GOGC=off reads back as 0.
+		info.GC.GOGCPercent = int64(samples[0].Value.Uint64())
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		if limit := samples[1].Value.Uint64(); limit < math.MaxInt64 {
+			info.GC.MemoryLimitBytes = &limit
+		}
+	}
+	if samples[2].Value.Kind() == metrics.KindFloat64 && samples[3].Value.Kind() == metrics.KindFloat64 {
+		if total := samples[3].Value.Float64(); total > 0 {
+			info.GC.CPUFraction = samples[2].Value.Float64() / total
+		}
+	}
+	if ms.LastGC > 0 {
+		t := time.Unix(0, int64(ms.LastGC)).UTC()
+		info.GC.LastGC = &t
+	}
+	if cores, err := cgroupCPULimit(); err == nil && cores > 0 {
+		info.Cgroup.CPULimitCores = &cores
+	}
+	if limit, err := cgroupMemoryLimit(); err == nil && limit > 0 {
+		info.Cgroup.MemoryLimitBytes = &limit
+	}
+	for _, k := range []string{"GOMAXPROCS", "GOGC", "GOMEMLIMIT", "GODEBUG"} {
+		if v, ok := os.LookupEnv(k); ok {
+			info.Env[k] = v
+		}
+	}
+	return info
+}
+
+func runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, collectRuntimeInfo())
+}
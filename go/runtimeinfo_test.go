@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCgroupCPULimit(t *testing.T) {
+	prevMax, prevDir := cgroupCPUMaxFile, cgroupCPUQuotaDir
+	t.Cleanup(func() { cgroupCPUMaxFile, cgroupCPUQuotaDir = prevMax, prevDir })
+	dir := t.TempDir()
+	write := func(name, v string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(v), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cgroupCPUMaxFile = filepath.Join(dir, "cpu.max")
+	cgroupCPUQuotaDir = dir
+	for v, want := range map[string]float64{"150000 100000\n": 1.5, "max 100000\n": 0, "50000\n": 0.5} {
+		write("cpu.max", v)
+		if got, err := cgroupCPULimit(); err != nil || got != want {
+			t.Errorf("cpu.max %q: got %v, %v; want %v", v, got, err, want)
+		}
+	}
+
+	// cgroup v1 fallback.
+	cgroupCPUMaxFile = filepath.Join(dir, "missing")
+	write("cpu.cfs_quota_us", "200000\n")
+	write("cpu.cfs_period_us", "100000\n")
+	if got, err := cgroupCPULimit(); err != nil || got != 2 {
+		t.Errorf("v1 quota: got %v, %v; want 2", got, err)
+	}
+	write("cpu.cfs_quota_us", "-1\n")
+	if got, err := cgroupCPULimit(); err != nil || got != 0 {
+		t.Errorf("v1 no quota: got %v, %v; want 0", got, err)
+	}
+}
+
+func TestRuntimeHandler(t *testing.T) {
+	t.Setenv("GOGC", "150")
+	rr := httptest.NewRecorder()
+	runtimeHandler(rr, httptest.NewRequest("GET", "/api/runtime", nil))
+	var info runtimeInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.GOMAXPROCS != runtime.GOMAXPROCS(0) || info.NumCPU == 0 || info.Goroutines == 0 {
+		t.Errorf("info = %+v", info)
+	}
+	if info.GC.GOGCPercent == 0 || info.Heap.InuseBytes == 0 {
+		t.Errorf("gc/heap = %+v %+v", info.GC, info.Heap)
+	}
+	if info.Env["GOGC"] != "150" {
+		t.Errorf("env = %v, want the raw GOGC value", info.Env)
+	}
+}
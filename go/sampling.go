@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace sampling. Head-based sampling is chosen with TRACE_SAMPLER:
+//
+//	always: record every trace
+//	never:  record none
+//	ratio:  keep TRACE_SAMPLE_RATIO (0..1) of traces, by trace ID
+//
+// TRACE_SAMPLER_PARENT_BASED (default true) makes a request carrying a
+// traceparent follow the caller's decision instead, so a trace is kept or
+// dropped as a whole. TRACE_SAMPLE_ERRORS=true also exports spans that end
+// with an error status (such as a 5xx server span) even when the trace was
+// not sampled; only the failing spans are kept, not the rest of their
+// trace. With TRACE_SAMPLER unset, the SDK default applies, including
+// OTEL_TRACES_SAMPLER.
+func traceSampler() (sdktrace.Sampler, error) {
+	var s sdktrace.Sampler
+	switch kind := getenv("TRACE_SAMPLER", ""); kind {
+	case "":
+		if !getenvBool("TRACE_SAMPLE_ERRORS", false) {
+			return nil, nil
+		}
+		s = sdktrace.AlwaysSample()
+	case "always":
+		s = sdktrace.AlwaysSample()
+	case "never":
+		s = sdktrace.NeverSample()
+	case "ratio":
+		ratio := getenvFloat("TRACE_SAMPLE_RATIO", 1)
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("TRACE_SAMPLE_RATIO %v is outside 0..1", ratio)
+		}
+		s = sdktrace.TraceIDRatioBased(ratio)
+	default:
+		return nil, fmt.Errorf("unknown trace sampler %q, want always, never or ratio", kind)
+	}
+	if getenvBool("TRACE_SAMPLER_PARENT_BASED", true) {
+		s = sdktrace.ParentBased(s)
+	}
+	if getenvBool("TRACE_SAMPLE_ERRORS", false) {
+		s = recordUnsampled{s}
+	}
+	return s, nil
+}
+
+// recordUnsampled turns the wrapped sampler's drops into record-only
+// decisions, so unsampled spans still reach keepErrors.
+type recordUnsampled struct{ sdktrace.Sampler }
+
+func (s recordUnsampled) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.Sampler.ShouldSample(p)
+	if res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s recordUnsampled) Description() string {
+	return "RecordUnsampled{" + s.Sampler.Description() + "}"
+}
+
+// keepErrors passes sampled spans through and exports unsampled ones only
+// if they ended with an error.
+type keepErrors struct{ sdktrace.SpanProcessor }
+
+func (p keepErrors) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		if s.Status().Code != codes.Error {
+			return
+		}
+		s = sampledSpan{s}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// sampledSpan marks a span as sampled so exporting processors accept it.
+type sampledSpan struct{ sdktrace.ReadOnlySpan }
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
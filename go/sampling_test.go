@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceSampler(t *testing.T) {
+	for _, tc := range []struct {
+		sampler, ratio, parent, errors string
+		want                           string
+	}{
+		{want: ""},
+		{sampler: "always", want: "ParentBased{root:AlwaysOnSampler,remoteParentSampled:AlwaysOnSampler,remoteParentNotSampled:AlwaysOffSampler,localParentSampled:AlwaysOnSampler,localParentNotSampled:AlwaysOffSampler}"},
+		{sampler: "ratio", ratio: "0.25", parent: "false", want: "TraceIDRatioBased{0.25}"},
+		{sampler: "never", parent: "false", errors: "true", want: "RecordUnsampled{AlwaysOffSampler}"},
+		{errors: "true", parent: "false", want: "RecordUnsampled{AlwaysOnSampler}"},
+	} {
+		t.Setenv("TRACE_SAMPLER", tc.sampler)
+		t.Setenv("TRACE_SAMPLE_RATIO", tc.ratio)
+		t.Setenv("TRACE_SAMPLER_PARENT_BASED", tc.parent)
+		t.Setenv("TRACE_SAMPLE_ERRORS", tc.errors)
+		s, err := traceSampler()
+		if err != nil {
+			t.Errorf("%+v: %v", tc, err)
+			continue
+		}
+		got := ""
+		if s != nil {
+			got = s.Description()
+		}
+		if got != tc.want {
+			t.Errorf("%+v: sampler = %s, want %s", tc, got, tc.want)
+		}
+	}
+
+	t.Setenv("TRACE_SAMPLE_ERRORS", "")
+	for _, bad := range [][2]string{{"sometimes", ""}, {"ratio", "1.5"}} {
+		t.Setenv("TRACE_SAMPLER", bad[0])
+		t.Setenv("TRACE_SAMPLE_RATIO", bad[1])
+		if _, err := traceSampler(); err == nil {
+			t.Errorf("%v: want an error", bad)
+		}
+	}
+}
+
+func TestKeepErrors(t *testing.T) {
+	withTestTracer(t) // restores the global provider afterwards
+	exported := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(recordUnsampled{sdktrace.NeverSample()}),
+		sdktrace.WithSpanProcessor(keepErrors{sdktrace.NewSimpleSpanProcessor(exported)}),
+	)
+	t.Cleanup(func() { tp.Shutdown(t.Context()) })
+	otel.SetTracerProvider(tp)
+
+	mux := http.NewServeMux()
+	mux.Handle("/ok", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withTracing()))
+	mux.Handle("/fail", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}), withTracing()))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	spans := exported.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "GET /fail" {
+		t.Fatalf("exported %d spans, want only the failed request", len(spans))
+	}
+	if !spans[0].SpanContext.IsSampled() {
+		t.Error("kept error span is not marked sampled")
+	}
+}
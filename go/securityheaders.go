@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Security headers. Every application response carries
+// X-Content-Type-Options: nosniff plus a policy set through:
+//
+//	SECURITY_CSP                 Content-Security-Policy
+//	SECURITY_FRAME_OPTIONS       X-Frame-Options (DENY or SAMEORIGIN)
+//	SECURITY_REFERRER_POLICY     Referrer-Policy
+//	SECURITY_PERMISSIONS_POLICY  Permissions-Policy
+//
+// Defaults depend on APP_ENV: production denies framing and sends no
+// referrer, while other profiles allow same-origin framing and send the
+// origin cross-site, which local tooling tends to need. "off" omits a
+// header. A value that doesn't parse stops startup (see invalidConfig).
+// Strict-Transport-Security is configured with the HTTPS redirect.
+var securityHeaders = mustSecurityHeadersFromEnv(env)
+
+type securityPolicy struct {
+	CSP               string
+	FrameOptions      string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+}
+
+func defaultSecurityPolicy(profile string) securityPolicy {
+	p := securityPolicy{
+		CSP:               "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; script-src 'self'",
+		FrameOptions:      "DENY",
+		ReferrerPolicy:    "no-referrer",
+		PermissionsPolicy: "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+	}
+	if profile != "production" {
+		p.FrameOptions = "SAMEORIGIN"
+		p.ReferrerPolicy = "strict-origin-when-cross-origin"
+	}
+	return p
+}
+
+// headers returns the policy's response headers.
+func (p securityPolicy) headers() http.Header {
+	h := http.Header{"X-Content-Type-Options": {"nosniff"}}
+	for k, v := range map[string]string{
+		"Content-Security-Policy": p.CSP,
+		"X-Frame-Options":         p.FrameOptions,
+		"Referrer-Policy":         p.ReferrerPolicy,
+		"Permissions-Policy":      p.PermissionsPolicy,
+	} {
+		if v != "" && v != "off" {
+			h.Set(k, v)
+		}
+	}
+	return h
+}
+
+var (
+	cspDirectiveName = regexp.MustCompile(`^[a-z][a-z-]*$`)
+	permissionsEntry = regexp.MustCompile(`^[a-z][a-z-]*=(\*|self|\(\s*((self|\*|src|"[^"\s]+")(\s+(self|\*|src|"[^"\s]+"))*)?\s*\))$`)
+	referrerPolicies = []string{
+		"no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin",
+		"same-origin", "strict-origin", "strict-origin-when-cross-origin", "unsafe-url",
+	}
+)
+
+func validateCSP(v string) error {
+	seen := map[string]bool{}
+	for _, d := range strings.Split(v, ";") {
+		fields := strings.Fields(d)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if !cspDirectiveName.MatchString(name) {
+			return fmt.Errorf("invalid CSP directive %q", fields[0])
+		}
+		if seen[name] {
+			return fmt.Errorf("CSP directive %q repeated", name)
+		}
+		seen[name] = true
+	}
+	if len(seen) == 0 {
+		return fmt.Errorf("CSP has no directives")
+	}
+	return nil
+}
+
+func validateFrameOptions(v string) error {
+	if !slices.Contains([]string{"DENY", "SAMEORIGIN"}, strings.ToUpper(v)) {
+		return fmt.Errorf("X-Frame-Options %q, want DENY or SAMEORIGIN", v)
+	}
+	return nil
+}
+
+func validateReferrerPolicy(v string) error {
+	// A comma-separated list is allowed; browsers use the last they know.
+	for _, p := range strings.Split(v, ",") {
+		if !slices.Contains(referrerPolicies, strings.TrimSpace(strings.ToLower(p))) {
+			return fmt.Errorf("unknown Referrer-Policy %q", strings.TrimSpace(p))
+		}
+	}
+	return nil
+}
+
+func validatePermissionsPolicy(v string) error {
+	for _, e := range strings.Split(v, ",") {
+		if !permissionsEntry.MatchString(strings.TrimSpace(e)) {
+			return fmt.Errorf("invalid Permissions-Policy entry %q, want feature=(allowlist)", strings.TrimSpace(e))
+		}
+	}
+	return nil
+}
+
+func mustSecurityHeadersFromEnv(profile string) http.Header {
+	p := defaultSecurityPolicy(profile)
+	for _, f := range []struct {
+		key   string
+		field *string
+		check func(string) error
+	}{
+		{"SECURITY_CSP", &p.CSP, validateCSP},
+		{"SECURITY_FRAME_OPTIONS", &p.FrameOptions, validateFrameOptions},
+		{"SECURITY_REFERRER_POLICY", &p.ReferrerPolicy, validateReferrerPolicy},
+		{"SECURITY_PERMISSIONS_POLICY", &p.PermissionsPolicy, validatePermissionsPolicy},
+	} {
+		v := getenv(f.key, *f.field)
+		if v == "off" {
+			*f.field = v
+			continue
+		}
+		if err := f.check(v); err != nil {
+			invalidConfig(f.key, err)
+		}
+		*f.field = v
+	}
+	return p.headers()
+}
+
+func withSecurityHeaders() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for k := range securityHeaders {
+				w.Header().Set(k, securityHeaders.Get(k))
+			}
+			if r.TLS != nil && hstsHeader != "" {
+				w.Header().Set("Strict-Transport-Security", hstsHeader)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDefaultSecurityPoliciesParse(t *testing.T) {
+	for _, profile := range []string{"production", "development", "staging"} {
+		p := defaultSecurityPolicy(profile)
+		for _, c := range []struct {
+			name, v string
+			check   func(string) error
+		}{
+			{"CSP", p.CSP, validateCSP},
+			{"X-Frame-Options", p.FrameOptions, validateFrameOptions},
+			{"Referrer-Policy", p.ReferrerPolicy, validateReferrerPolicy},
+			{"Permissions-Policy", p.PermissionsPolicy, validatePermissionsPolicy},
+		} {
+			if err := c.check(c.v); err != nil {
+				t.Errorf("%s default %s: %v", profile, c.name, err)
+			}
+		}
+	}
+	if got := defaultSecurityPolicy("production").FrameOptions; got != "DENY" {
+		t.Errorf("production X-Frame-Options %q, want DENY", got)
+	}
+}
+
+func TestSecurityPolicyValidation(t *testing.T) {
+	valid := map[string][]string{
+		"csp":         {"default-src 'none'; frame-ancestors 'none'", "upgrade-insecure-requests"},
+		"frame":       {"deny", "SAMEORIGIN"},
+		"referrer":    {"same-origin", "no-referrer, strict-origin-when-cross-origin"},
+		"permissions": {"geolocation=()", `camera=(self "https://meet.example.com"), fullscreen=*`},
+	}
+	invalid := map[string][]string{
+		"csp":         {"", "default-src 'self'; default-src *", "Default_Src 'self'"},
+		"frame":       {"ALLOW-FROM https://a.example", "none"},
+		"referrer":    {"nope"},
+		"permissions": {"geolocation", "camera=(https://x.example)", "camera=()  microphone=()"},
+	}
+	checks := map[string]func(string) error{
+		"csp":         validateCSP,
+		"frame":       validateFrameOptions,
+		"referrer":    validateReferrerPolicy,
+		"permissions": validatePermissionsPolicy,
+	}
+	for kind, vs := range valid {
+		for _, v := range vs {
+			if err := checks[kind](v); err != nil {
+				t.Errorf("%s %q: %v", kind, v, err)
+			}
+		}
+	}
+	for kind, vs := range invalid {
+		for _, v := range vs {
+			if checks[kind](v) == nil {
+				t.Errorf("%s %q: expected an error", kind, v)
+			}
+		}
+	}
+}
+
+func TestSecurityHeadersFromEnv(t *testing.T) {
+	t.Setenv("SECURITY_FRAME_OPTIONS", "off")
+	t.Setenv("SECURITY_PERMISSIONS_POLICY", "camera=(self)")
+	h := mustSecurityHeadersFromEnv("production")
+	if h.Get("X-Frame-Options") != "" {
+		t.Errorf("X-Frame-Options %q, want it omitted", h.Get("X-Frame-Options"))
+	}
+	if h.Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("Referrer-Policy %q, want the production default", h.Get("Referrer-Policy"))
+	}
+	if h.Get("Permissions-Policy") != "camera=(self)" || h.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("headers %v", h)
+	}
+
+	prev := securityHeaders
+	securityHeaders = h
+	t.Cleanup(func() { securityHeaders = prev })
+	rr := httptest.NewRecorder()
+	chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withSecurityHeaders()).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Header().Get("Permissions-Policy") != "camera=(self)" || rr.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("response headers %v", rr.Header())
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Self-healing hooks. SELF_HEAL lists remediations to run after a health
+// check fails a number of times in a row, as check:action[:failures]
+// (failures defaults to 3), e.g.
+//
+//	SELF_HEAL=db:reconnect:3,payments:reconnect,ping:clear-kv:5
+//
+// A remediation runs once the check has failed N probes in a row and again
+// every N failures after that while it stays down, rather than on every
+// probe. Remediations run in the background so probes never wait on them;
+// each rule has at most one attempt in flight, bounded by SELF_HEAL_TIMEOUT
+// (default 10s). Every attempt and its outcome is written to the ledger.
+var healActions = map[string]func(context.Context) (string, error){
+	"clear-kv": func(context.Context) (string, error) {
+		return fmt.Sprintf("cleared %d keys", kv.clear("self-heal")), nil
+	},
+	"reset-latency": func(context.Context) (string, error) {
+		if activeLatencyModel.Swap(nil) == nil {
+			return "no latency model active", nil
+		}
+		return "latency model removed", nil
+	},
+	"reconnect": func(ctx context.Context) (string, error) {
+		outboundClient.CloseIdleConnections()
+		msg := "closed idle outbound HTTP connections"
+		if appDB != nil {
+			// Dropping the idle cap to zero closes pooled connections; the
+			// next query dials fresh ones.
+			appDB.SetMaxIdleConns(0)
+			appDB.SetMaxIdleConns(getenvInt("DB_MAX_IDLE_CONNS", 2))
+			if err := pingDB(ctx); err != nil {
+				return msg + " and database pool", fmt.Errorf("database still unreachable: %w", err)
+			}
+			msg += " and database pool"
+		}
+		return msg, nil
+	},
+}
+
+type healRule struct {
+	check  string
+	action string
+	after  int
+}
+
+type selfHealer struct {
+	rules   []healRule
+	actions map[string]func(context.Context) (string, error)
+	timeout time.Duration
+
+	mu       sync.Mutex
+	failures map[string]int
+	running  map[healRule]bool
+	wg       sync.WaitGroup
+}
+
+func parseHealRules(spec string) ([]healRule, error) {
+	var rules []healRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("self-heal rule %q: want check:action[:failures]", entry)
+		}
+		if _, ok := healActions[parts[1]]; !ok {
+			return nil, fmt.Errorf("self-heal rule %q: unknown action %q", entry, parts[1])
+		}
+		r := healRule{check: parts[0], action: parts[1], after: 3}
+		if len(parts) == 3 {
+			n, err := strconv.Atoi(parts[2])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("self-heal rule %q: failures must be a positive integer", entry)
+			}
+			r.after = n
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// registerSelfHeal wires the rules in spec into the health registry.
+func registerSelfHeal(spec string) error {
+	rules, err := parseHealRules(spec)
+	if err != nil {
+		return err
+	}
+	h := &selfHealer{
+		rules:    rules,
+		actions:  healActions,
+		timeout:  getenvDuration("SELF_HEAL_TIMEOUT", 10*time.Second),
+		failures: make(map[string]int),
+		running:  make(map[healRule]bool),
+	}
+	health.observe(h.observe)
+	return nil
+}
+
+func (h *selfHealer) observe(res checkResult) {
+	h.mu.Lock()
+	if res.Status != "failed" {
+		delete(h.failures, res.Name)
+		h.mu.Unlock()
+		return
+	}
+	h.failures[res.Name]++
+	n := h.failures[res.Name]
+	for _, r := range h.rules {
+		if r.check != res.Name || n%r.after != 0 {
+			continue
+		}
+		if h.running[r] {
+			logger.Debug("self-heal action still running, skipping", "check", r.check, "action", r.action)
+			continue
+		}
+		h.running[r] = true
+		h.wg.Add(1)
+		go h.remediate(r, n, res.Error)
+	}
+	h.mu.Unlock()
+}
+
+func (h *selfHealer) remediate(r healRule, failures int, cause string) {
+	defer h.wg.Done()
+	defer func() {
+		h.mu.Lock()
+		delete(h.running, r)
+		h.mu.Unlock()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	msg, err := h.actions[r.action](ctx)
+	details := map[string]any{"action": r.action, "failures": failures, "cause": cause, "result": "ok"}
+	if err != nil {
+		details["result"], details["error"] = "failed", err.Error()
+		logger.Warn("self-heal action failed", "check", r.check, "action", r.action, "err", err)
+	} else {
+		logger.Info("self-heal action ran", "check", r.check, "action", r.action, "result", msg)
+	}
+	events.publish(event{
+		Type:    eventSelfHeal,
+		Subject: r.check,
+		Message: fmt.Sprintf("%s after %d consecutive failures: %s", r.action, failures, msg),
+		Data:    details,
+	})
+}
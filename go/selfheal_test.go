@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseHealRules(t *testing.T) {
+	rules, err := parseHealRules("db:reconnect:2, cache:clear-kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []healRule{{"db", "reconnect", 2}, {"cache", "clear-kv", 3}}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Errorf("got %+v want %+v", rules, want)
+	}
+	for _, bad := range []string{"db", "db:explode", "db:reconnect:0", ":clear-kv", "db:reconnect:1:2"} {
+		if _, err := parseHealRules(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSelfHealRunsAfterConsecutiveFailures(t *testing.T) {
+	withTestHealth(t)
+	withTestLedger(t, 10)
+
+	runs := 0
+	h := &selfHealer{
+		rules:    []healRule{{check: "flaky", action: "fix", after: 2}},
+		actions:  map[string]func(context.Context) (string, error){"fix": func(context.Context) (string, error) { runs++; return "fixed", nil }},
+		timeout:  time.Second,
+		failures: make(map[string]int),
+		running:  make(map[healRule]bool),
+	}
+	health.observe(h.observe)
+	failing := true
+	health.register("flaky", probeReady, func(context.Context) error {
+		if failing {
+			return errors.New("down")
+		}
+		return nil
+	})
+
+	probeOnce := func() {
+		health.run(context.Background(), probeReady, nil, nil)
+		h.wg.Wait()
+	}
+	probeOnce()
+	if runs != 0 {
+		t.Fatal("remediation ran before the failure threshold")
+	}
+	probeOnce()
+	if runs != 1 {
+		t.Fatalf("expected one remediation after 2 failures, got %d", runs)
+	}
+
+	failing = false
+	probeOnce()
+	failing = true
+	probeOnce()
+	if runs != 1 {
+		t.Error("a success did not reset the consecutive failure count")
+	}
+
+	entries := ledger.list("self-heal", 0)
+	if len(entries) != 1 || entries[0].Subject != "flaky" {
+		t.Errorf("expected one self-heal ledger entry, got %+v", entries)
+	}
+}
+
+func TestHealActionClearKV(t *testing.T) {
+	prev := kv
+	kv = newKVStore(10, 1024, time.Hour, time.Hour)
+	t.Cleanup(func() { kv = prev })
+	_, _, _ = kv.put("a", []byte("1"), "", 0)
+
+	if _, err := healActions["clear-kv"](context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kv.get("a"); ok {
+		t.Error("clear-kv left keys behind")
+	}
+}
+
+func TestSelfHealSingleFlight(t *testing.T) {
+	withTestLedger(t, 10)
+
+	release := make(chan struct{})
+	var runs atomic.Int32
+	h := &selfHealer{
+		rules: []healRule{{check: "flaky", action: "fix", after: 1}},
+		actions: map[string]func(context.Context) (string, error){"fix": func(ctx context.Context) (string, error) {
+			runs.Add(1)
+			select {
+			case <-release:
+				return "fixed", nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}},
+		timeout:  time.Second,
+		failures: make(map[string]int),
+		running:  make(map[healRule]bool),
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			h.observe(checkResult{Name: "flaky", Status: "failed", Error: "down"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("observe blocked on a running remediation")
+	}
+	close(release)
+	h.wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("remediation ran %d times while one was in flight, want 1", n)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Demo session tokens are short-lived, HMAC-signed bearer tokens minted from
+// the admin token. Handing one to a browser UI instead of the admin token
+// means control access lapses on its own once a demo is over.
+var (
+	sessionKey    = newRotatingSecret(getenv("SESSION_SIGNING_KEY", deriveSessionKey(getenv("ADMIN_TOKEN", ""))))
+	sessionTTL    = getenvDuration("SESSION_TTL", 30*time.Minute)
+	sessionMaxTTL = getenvDuration("SESSION_MAX_TTL", 2*time.Hour)
+)
+
+func init() {
+	secrets["session-key"] = sessionKey
+}
+
+// deriveSessionKey is the signing key used when SESSION_SIGNING_KEY is unset.
+// It is derived from the admin token so that every worker and replica
+// sharing that token accepts each other's sessions. Without an admin token
+// no sessions can be minted, so a random key will do.
+func deriveSessionKey(adminToken string) string {
+	if adminToken == "" {
+		return randomSecret()
+	}
+	mac := hmac.New(sha256.New, []byte(adminToken))
+	mac.Write([]byte("session-signing-key"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type sessionClaims struct {
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var errInvalidSession = errors.New("invalid session token")
+
+func signSession(c sessionClaims, key string) string {
+	payload, _ := json.Marshal(c)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySession checks the signature against the current session key and,
+// during a rotation grace window, the previous one.
+func verifySession(token string, now time.Time) (sessionClaims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return sessionClaims{}, errInvalidSession
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return sessionClaims{}, errInvalidSession
+	}
+	valid := false
+	for _, key := range sessionKey.accepted() {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(body))
+		if hmac.Equal(got, mac.Sum(nil)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return sessionClaims{}, errInvalidSession
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return sessionClaims{}, errInvalidSession
+	}
+	var c sessionClaims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return sessionClaims{}, errInvalidSession
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return sessionClaims{}, errors.New("session token expired")
+	}
+	return c, nil
+}
+
+// sessionHandler mints a session token. Only the admin token itself may do
+// this, so a session can't be used to extend its own lifetime.
+func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if adminPrincipal(r.Context()) != principalAdminToken {
+		writeError(w, http.StatusForbidden, "sessions can only be issued with the admin token")
+		return
+	}
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	ttl := sessionTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "ttl must be a positive duration")
+			return
+		}
+		ttl = d
+	}
+	ttl = min(ttl, sessionMaxTTL)
+
+	now := time.Now()
+	c := sessionClaims{ID: randomSecret()[:16], IssuedAt: now.Unix(), ExpiresAt: now.Add(ttl).Unix()}
+	loggerFrom(r.Context()).Info("admin session issued", "session", c.ID, "ttl", ttl.String(), "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"token":     signSession(c, sessionKey.value()),
+		"expiresAt": time.Unix(c.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeriveSessionKey(t *testing.T) {
+	a, b := deriveSessionKey("s3cret"), deriveSessionKey("s3cret")
+	if a != b {
+		t.Error("processes sharing an admin token derived different session keys")
+	}
+	if a == "s3cret" || a == deriveSessionKey("other") {
+		t.Errorf("derived key %q is not tied to the admin token", a)
+	}
+	if deriveSessionKey("") == deriveSessionKey("") {
+		t.Error("expected a random key without an admin token")
+	}
+}
+
+func TestVerifySession(t *testing.T) {
+	now := time.Now()
+	c := sessionClaims{ID: "abc", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	tok := signSession(c, sessionKey.value())
+
+	if got, err := verifySession(tok, now); err != nil || got.ID != "abc" {
+		t.Errorf("verifySession returned %+v, %v", got, err)
+	}
+	if _, err := verifySession(tok, now.Add(2*time.Minute)); err == nil {
+		t.Error("expected an expired token to be rejected")
+	}
+	if _, err := verifySession(tok+"x", now); err == nil {
+		t.Error("expected a tampered token to be rejected")
+	}
+	if _, err := verifySession(signSession(c, "some other key"), now); err == nil {
+		t.Error("expected a token signed with an unknown key to be rejected")
+	}
+}
+
+func TestSessionTokenGrantsAdminAccess(t *testing.T) {
+	prev := adminToken.value()
+	t.Cleanup(func() { adminToken.rotate(prev, 0) })
+	adminToken.rotate("s3cret", 0)
+
+	do := func(h http.HandlerFunc, method, token string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, "/", strings.NewReader(`{"ttl":"1m"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		chain(h, withAdminAuth()).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(sessionHandler, "POST", "s3cret")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var resp struct{ Token string }
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr := do(secretsHandler, "GET", resp.Token); rr.Code != http.StatusOK {
+		t.Errorf("session token was not accepted: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if rr := do(sessionHandler, "POST", resp.Token); rr.Code != http.StatusForbidden {
+		t.Errorf("session token minted another session: got %v want %v", rr.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Consistent hashing demo. /api/shard?key=... maps a key onto a ring of
+// simulated shards (SHARD_COUNT, default 4), each placed SHARD_VNODES times
+// (default 64), and lists the SHARD_REPLICAS (default 2) distinct shards
+// that would hold it. PUT /api/shard/ring (admin only) resizes the ring and
+// reports what fraction of keys moved, which is the point of the demo: with
+// consistent hashing only about 1/N of keys move when one shard is added.
+type hashRing struct {
+	shards int
+	vnodes int
+	points []uint64
+	owner  map[uint64]int
+}
+
+// rebalanceSampleKeys is how many synthetic keys are used to measure key
+// movement on a resize.
+const rebalanceSampleKeys = 10000
+
+// Ring size limits, which keep a rebuild to at most 16k hashes.
+const (
+	shardMaxCount  = 64
+	shardMaxVNodes = 256
+)
+
+func newHashRing(shards, vnodes int) *hashRing {
+	r := &hashRing{shards: shards, vnodes: vnodes, owner: make(map[uint64]int, shards*vnodes)}
+	for s := 0; s < shards; s++ {
+		for v := 0; v < vnodes; v++ {
+			h := ringHash(fmt.Sprintf("shard-%d#%d", s, v))
+			if _, taken := r.owner[h]; taken {
+				continue
+			}
+			r.owner[h] = s
+			r.points = append(r.points, h)
+		}
+	}
+	slices.Sort(r.points)
+	return r
+}
+
+func ringHash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// lookup returns up to n distinct shards for key, walking the ring
+// clockwise from the key's hash. The first is the primary.
+func (r *hashRing) lookup(key string, n int) []int {
+	n = min(n, r.shards)
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+	h := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	out := make([]int, 0, n)
+	for j := 0; len(out) < n && j < len(r.points); j++ {
+		s := r.owner[r.points[(i+j)%len(r.points)]]
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// movedFraction estimates the share of keys whose primary shard differs
+// between two rings.
+func movedFraction(from, to *hashRing) float64 {
+	moved := 0
+	for i := 0; i < rebalanceSampleKeys; i++ {
+		k := "key-" + strconv.Itoa(i)
+		if from.lookup(k, 1)[0] != to.lookup(k, 1)[0] {
+			moved++
+		}
+	}
+	return float64(moved) / rebalanceSampleKeys
+}
+
+var shardRing = struct {
+	sync.RWMutex
+	ring     *hashRing
+	replicas int
+}{
+	ring: newHashRing(
+		min(max(getenvInt("SHARD_COUNT", 4), 1), shardMaxCount),
+		min(max(getenvInt("SHARD_VNODES", 64), 1), shardMaxVNodes),
+	),
+	replicas: max(getenvInt("SHARD_REPLICAS", 2), 1),
+}
+
+func init() {
+	shardRingSize.Set(float64(shardRing.ring.shards))
+}
+
+type shardResponse struct {
+	Key      string   `json:"key"`
+	Hash     string   `json:"hash"`
+	Shard    string   `json:"shard"`
+	Replicas []string `json:"replicas"`
+	Ring     int      `json:"ringSize"`
+}
+
+func shardName(s int) string { return "shard-" + strconv.Itoa(s) }
+
+// shardHandler serves GET /api/shard?key=....
+func shardHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+	shardRing.RLock()
+	ring, replicas := shardRing.ring, shardRing.replicas
+	shardRing.RUnlock()
+
+	owners := ring.lookup(key, replicas)
+	resp := shardResponse{Key: key, Hash: fmt.Sprintf("%016x", ringHash(key)), Shard: shardName(owners[0]), Ring: ring.shards}
+	for _, s := range owners {
+		resp.Replicas = append(resp.Replicas, shardName(s))
+	}
+	shardLookups.WithLabelValues(resp.Shard).Inc()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// shardRingHandler serves GET and PUT /api/shard/ring. PUT takes
+// {"shards": N, "vnodes": M}; vnodes is optional. The new ring is built
+// without holding the lock and swapped in only if no other resize landed
+// in the meantime.
+func shardRingHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	shardRing.RLock()
+	cur, replicas := shardRing.ring, shardRing.replicas
+	shardRing.RUnlock()
+	if r.Method != http.MethodPut {
+		writeJSON(w, http.StatusOK, map[string]int{"shards": cur.shards, "vnodes": cur.vnodes, "replicas": replicas})
+		return
+	}
+
+	req := struct {
+		Shards int `json:"shards"`
+		VNodes int `json:"vnodes"`
+	}{VNodes: cur.vnodes}
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if req.Shards < 1 || req.Shards > shardMaxCount || req.VNodes < 1 || req.VNodes > shardMaxVNodes {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("shards must be between 1 and %d and vnodes between 1 and %d", shardMaxCount, shardMaxVNodes))
+		return
+	}
+
+	next := newHashRing(req.Shards, req.VNodes)
+	moved := movedFraction(cur, next)
+	shardRing.Lock()
+	if shardRing.ring != cur {
+		shardRing.Unlock()
+		writeError(w, http.StatusConflict, "the ring was resized concurrently; retry")
+		return
+	}
+	shardRing.ring = next
+	shardRing.Unlock()
+	shardRingSize.Set(float64(next.shards))
+	shardRebalances.Inc()
+	shardMovedRatio.Set(moved)
+	events.publish(event{
+		Type:    eventShardRebalance,
+		Message: fmt.Sprintf("ring resized from %d to %d shards, %.1f%% of keys moved", cur.shards, next.shards, 100*moved),
+		Data:    map[string]any{"from": cur.shards, "to": next.shards, "vnodes": next.vnodes, "movedRatio": moved},
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"previousShards": cur.shards,
+		"shards":         next.shards,
+		"vnodes":         next.vnodes,
+		"movedRatio":     moved,
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHashRingLookupDistinctReplicas(t *testing.T) {
+	r := newHashRing(4, 32)
+	got := r.lookup("user:42", 3)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 replicas, got %v", got)
+	}
+	seen := map[int]bool{}
+	for _, s := range got {
+		if seen[s] {
+			t.Errorf("duplicate shard in replicas %v", got)
+		}
+		seen[s] = true
+	}
+	if again := r.lookup("user:42", 3); again[0] != got[0] {
+		t.Error("lookup is not deterministic")
+	}
+	if n := len(r.lookup("user:42", 10)); n != 4 {
+		t.Errorf("replicas should be capped at the ring size, got %d", n)
+	}
+}
+
+func TestMovedFractionOnGrowth(t *testing.T) {
+	moved := movedFraction(newHashRing(4, 64), newHashRing(5, 64))
+	// Ideal is 1/5; allow for vnode imbalance.
+	if moved < 0.1 || moved > 0.3 {
+		t.Errorf("moved fraction %.3f, want about 0.2", moved)
+	}
+}
+
+func TestShardHandlers(t *testing.T) {
+	withTestLedger(t, 10)
+	prev := shardRing.ring
+	t.Cleanup(func() { shardRing.ring = prev })
+
+	rr := httptest.NewRecorder()
+	shardHandler(rr, httptest.NewRequest("GET", "/api/shard", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	rr = httptest.NewRecorder()
+	shardRingHandler(rr, httptest.NewRequest("PUT", "/api/shard/ring", strings.NewReader(`{"shards":6}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	if len(ledger.list("shard-rebalance", 0)) != 1 {
+		t.Error("resize was not recorded in the ledger")
+	}
+
+	rr = httptest.NewRecorder()
+	shardHandler(rr, httptest.NewRequest("GET", "/api/shard?key=order-1", nil))
+	var resp shardResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Ring != 6 || resp.Shard == "" || resp.Replicas[0] != resp.Shard {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	for _, body := range []string{`{"shards":0}`, `{"shards":1000}`, `{"shards":4,"vnodes":1024}`} {
+		rr = httptest.NewRecorder()
+		shardRingHandler(rr, httptest.NewRequest("PUT", "/api/shard/ring", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestShardRingResizeRequiresAdmin(t *testing.T) {
+	prev := adminToken.value()
+	t.Cleanup(func() { adminToken.rotate(prev, 0) })
+	adminToken.rotate("s3cret", 0)
+
+	h := chain(http.HandlerFunc(shardRingHandler), withAdminAuthForWrites())
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/api/shard/ring", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("GET without a token: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/shard/ring", strings.NewReader(`{"shards":6}`)))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("PUT without a token: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
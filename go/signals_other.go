@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// watchReadinessSignal is a no-op on platforms without SIGUSR1.
+func watchReadinessSignal() {}
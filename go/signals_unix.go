@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchReadinessSignal toggles readiness on every SIGUSR1, e.g.
+// `kubectl exec <pod> -- kill -USR1 1`.
+func watchReadinessSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			ready := toggleReadiness()
+			logger.Warn("readiness toggled by SIGUSR1", "ready", ready)
+			auditAction("signal:SIGUSR1", "toggle-readiness", "ready", ready)
+		}
+	}()
+}
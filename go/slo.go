@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// SLO tracking. Every non-probe request is counted into one-minute buckets
+// covering the last 24 hours, so an availability and latency report can be
+// produced for any recent window without a metrics backend. A request is an
+// error if it returned 5xx and slow if it took longer than
+// SLO_LATENCY_THRESHOLD.
+var slo = newSLOTracker(
+	float64(getenvInt("SLO_AVAILABILITY_TARGET_PERMILLE", 999))/1000,
+	float64(getenvInt("SLO_LATENCY_TARGET_PERMILLE", 990))/1000,
+	getenvDuration("SLO_LATENCY_THRESHOLD", 300*time.Millisecond),
+)
+
+const sloRetention = 24 * time.Hour
+
+type sloTracker struct {
+	availabilityTarget float64
+	latencyTarget      float64
+	latencyThreshold   time.Duration
+
+	mu      sync.Mutex
+	buckets [int(sloRetention / time.Minute)]sloBucket
+}
+
+type sloBucket struct {
+	minute int64 // Unix minute the counts belong to
+	total  int64
+	errors int64
+	slow   int64
+}
+
+type sloReport struct {
+	Window             string  `json:"window"`
+	Requests           int64   `json:"requests"`
+	Errors             int64   `json:"errors"`
+	Slow               int64   `json:"slow"`
+	Availability       float64 `json:"availability"`
+	AvailabilityTarget float64 `json:"availabilityTarget"`
+	LatencyCompliance  float64 `json:"latencyCompliance"`
+	LatencyTarget      float64 `json:"latencyTarget"`
+	LatencyThresholdMs int64   `json:"latencyThresholdMs"`
+	// ErrorBudgetRemaining is the share of the window's error budget left,
+	// negative once it is overspent.
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+	Met                  bool    `json:"met"`
+}
+
+func newSLOTracker(availability, latency float64, threshold time.Duration) *sloTracker {
+	return &sloTracker{availabilityTarget: availability, latencyTarget: latency, latencyThreshold: threshold}
+}
+
+func (s *sloTracker) record(status int, d time.Duration) {
+	s.recordAt(time.Now(), status, d)
+}
+
+func (s *sloTracker) recordAt(now time.Time, status int, d time.Duration) {
+	minute := now.Unix() / 60
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[minute%int64(len(s.buckets))]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.total++
+	if status >= 500 {
+		b.errors++
+	}
+	if d > s.latencyThreshold {
+		b.slow++
+	}
+}
+
+// report summarizes the last window (capped at 24h), including the current
+// partial minute. With no traffic the SLO counts as met.
+func (s *sloTracker) report(window time.Duration) sloReport {
+	return s.reportAt(time.Now(), window)
+}
+
+func (s *sloTracker) reportAt(now time.Time, window time.Duration) sloReport {
+	window = min(window, sloRetention)
+	rep := sloReport{
+		Window:             window.String(),
+		AvailabilityTarget: s.availabilityTarget,
+		LatencyTarget:      s.latencyTarget,
+		LatencyThresholdMs: s.latencyThreshold.Milliseconds(),
+		Availability:       1,
+		LatencyCompliance:  1,
+	}
+	last := now.Unix() / 60
+	first := last - max(int64(window/time.Minute), 1)
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		if b.minute > first && b.minute <= last {
+			rep.Requests += b.total
+			rep.Errors += b.errors
+			rep.Slow += b.slow
+		}
+	}
+	s.mu.Unlock()
+
+	if rep.Requests > 0 {
+		rep.Availability = 1 - float64(rep.Errors)/float64(rep.Requests)
+		rep.LatencyCompliance = 1 - float64(rep.Slow)/float64(rep.Requests)
+	}
+	rep.ErrorBudgetRemaining = 1
+	if budget := 1 - s.availabilityTarget; budget > 0 {
+		rep.ErrorBudgetRemaining = 1 - (1-rep.Availability)/budget
+	}
+	rep.Met = rep.Availability >= s.availabilityTarget && rep.LatencyCompliance >= s.latencyTarget
+	return rep
+}
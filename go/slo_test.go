@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSLOReportWindow(t *testing.T) {
+	s := newSLOTracker(0.99, 0.9, 100*time.Millisecond)
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	// Outside a 10m window.
+	s.recordAt(now.Add(-20*time.Minute), 500, 0)
+	for i := 0; i < 98; i++ {
+		s.recordAt(now.Add(-time.Duration(i%5)*time.Minute), 200, 10*time.Millisecond)
+	}
+	s.recordAt(now, 503, 10*time.Millisecond)
+	s.recordAt(now, 200, time.Second)
+
+	rep := s.reportAt(now, 10*time.Minute)
+	if rep.Requests != 100 || rep.Errors != 1 || rep.Slow != 1 {
+		t.Fatalf("counts = %d/%d/%d, want 100/1/1", rep.Requests, rep.Errors, rep.Slow)
+	}
+	if rep.Availability != 0.99 || !rep.Met {
+		t.Errorf("availability %v met %v, want 0.99 met", rep.Availability, rep.Met)
+	}
+	if rep.ErrorBudgetRemaining > 1e-9 || rep.ErrorBudgetRemaining < -1e-9 {
+		t.Errorf("error budget remaining = %v, want 0", rep.ErrorBudgetRemaining)
+	}
+
+	if rep := s.reportAt(now, 30*time.Minute); rep.Errors != 2 || rep.Met {
+		t.Errorf("30m window: errors %d met %v, want 2 and not met", rep.Errors, rep.Met)
+	}
+}
+
+func TestSLOReportWithoutTraffic(t *testing.T) {
+	rep := newSLOTracker(0.999, 0.99, time.Second).report(time.Hour)
+	if rep.Requests != 0 || rep.Availability != 1 || !rep.Met {
+		t.Errorf("empty report = %+v", rep)
+	}
+}
+
+func TestSLOBucketsWrapAfterRetention(t *testing.T) {
+	s := newSLOTracker(0.99, 0.9, time.Second)
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	s.recordAt(now.Add(-sloRetention), 500, 0)
+	s.recordAt(now, 200, 0)
+	if rep := s.reportAt(now, sloRetention); rep.Requests != 1 || rep.Errors != 0 {
+		t.Errorf("a bucket from a full retention ago leaked into the report: %+v", rep)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Slow request logging. A request that takes longer than its route's
+// threshold is logged at WARN, so latency regressions show up in logs even
+// where nobody is watching metrics. SLOW_REQUEST_THRESHOLD (default 1s,
+// 0 disables) applies to every route; SLOW_REQUEST_THRESHOLDS
+// ("/api/evidence=5s,GET /api/kv/{key}=100ms", keyed by mux pattern)
+// overrides it per route. The record breaks the time down into the
+// downstream calls tracked for Server-Timing and the rest.
+var (
+	slowRequestThreshold  = getenvDuration("SLOW_REQUEST_THRESHOLD", time.Second)
+	slowRequestThresholds = mustParseSlowRequestThresholds(getenv("SLOW_REQUEST_THRESHOLDS", ""))
+)
+
+func mustParseSlowRequestThresholds(spec string) map[string]time.Duration {
+	t, err := parseBudgets(spec)
+	if err != nil {
+		invalidConfig("SLOW_REQUEST_THRESHOLDS", err)
+	}
+	return t
+}
+
+func slowThreshold(route string) time.Duration {
+	if d, ok := slowRequestThresholds[route]; ok {
+		return d
+	}
+	return slowRequestThreshold
+}
+
+// withSlowRequests times the rest of the chain. It installs the request's
+// timing collector itself so it can read the dependency calls afterwards;
+// withBudget reuses it.
+func withSlowRequests() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			threshold := slowThreshold(routeLabel(r))
+			if threshold <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rt := &requestTimings{}
+			cw := &rwCapture{ResponseWriter: w}
+			start := time.Now()
+			next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), timingsKey{}, rt)))
+			elapsed := time.Since(start)
+			if elapsed <= threshold {
+				return
+			}
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			deps, other := rt.breakdown(elapsed)
+			loggerFrom(r.Context()).WarnContext(r.Context(), "slow request",
+				"path", r.URL.Path,
+				"status", status,
+				"dur_ms", elapsed.Milliseconds(),
+				"threshold_ms", threshold.Milliseconds(),
+				"dependencies_ms", deps,
+				"other_ms", other.Milliseconds(),
+			)
+		})
+	}
+}
+
+// breakdown totals the time spent in each dependency, in milliseconds, and
+// returns what is left of total.
+func (rt *requestTimings) breakdown(total time.Duration) (map[string]int64, time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	byDep := make(map[string]time.Duration)
+	for _, c := range rt.calls {
+		byDep[c.name] += c.dur
+		total -= c.dur
+	}
+	out := make(map[string]int64, len(byDep))
+	for name, d := range byDep {
+		out[name] = d.Milliseconds()
+	}
+	return out, max(total, 0)
+}
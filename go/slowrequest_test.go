@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSlowRequests(t *testing.T) {
+	prevLogger, prevDefault, prevThresholds := logger, slog.Default(), slowRequestThresholds
+	t.Cleanup(func() { logger = prevLogger; slog.SetDefault(prevDefault); slowRequestThresholds = prevThresholds })
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	var buf strings.Builder
+	logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	slowRequestThresholds = map[string]time.Duration{"/slow": 20 * time.Millisecond, "/fast": time.Hour}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trackDependencyCall(r.Context(), "db", 15*time.Millisecond)
+		time.Sleep(30 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/slow", chain(h, withLogging(), withSlowRequests(), withBudget()))
+	mux.Handle("/fast", chain(h, withLogging(), withSlowRequests(), withBudget()))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if strings.Contains(buf.String(), "slow request") {
+		t.Errorf("request under its threshold was logged: %s", buf.String())
+	}
+	if !strings.HasPrefix(rr.Header().Get("Server-Timing"), "db;dur=15") {
+		t.Errorf("Server-Timing = %q, want the shared collector's calls", rr.Header().Get("Server-Timing"))
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	var rec struct {
+		Level       string           `json:"level"`
+		Msg         string           `json:"msg"`
+		Route       string           `json:"route"`
+		Status      int              `json:"status"`
+		ThresholdMs int64            `json:"threshold_ms"`
+		DepsMs      map[string]int64 `json:"dependencies_ms"`
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &rec); err != nil {
+		t.Fatalf("%q: %v", buf.String(), err)
+	}
+	if rec.Level != "WARN" || rec.Msg != "slow request" || rec.Route != "/slow" || rec.Status != http.StatusAccepted || rec.ThresholdMs != 20 || rec.DepsMs["db"] != 15 {
+		t.Errorf("record = %+v", rec)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// startupTracker latches once every registered initialization task has
+// completed. Unlike readiness it never regresses, which is exactly what a
+// Kubernetes startupProbe expects.
+type startupTracker struct {
+	mu      sync.Mutex
+	tasks   []string
+	pending map[string]bool
+	done    atomic.Bool
+}
+
+var startup = newStartupTracker("warmup")
+
+func newStartupTracker(tasks ...string) *startupTracker {
+	s := &startupTracker{tasks: tasks, pending: make(map[string]bool)}
+	for _, t := range tasks {
+		s.pending[t] = true
+	}
+	s.done.Store(len(tasks) == 0)
+	return s
+}
+
+// complete marks task as finished. Unknown or already completed tasks are
+// ignored.
+func (s *startupTracker) complete(task string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pending[task] {
+		return
+	}
+	delete(s.pending, task)
+	if len(s.pending) == 0 {
+		s.done.Store(true)
+		logger.Info("startup complete", "tasks", s.tasks)
+	}
+}
+
+func (s *startupTracker) started() bool { return s.done.Load() }
+
+func (s *startupTracker) results() []checkResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]checkResult, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		if s.pending[t] {
+			results = append(results, checkResult{Name: t, Status: "failed", Error: "pending"})
+			continue
+		}
+		results = append(results, checkResult{Name: t, Status: "ok"})
+	}
+	return results
+}
+
+func (s *startupTracker) pendingTasks() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, t := range s.tasks {
+		if s.pending[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func startupHandler(w http.ResponseWriter, r *http.Request) {
+	if startup.started() {
+		resp := probeResponse{Status: "started"}
+		if _, verbose := r.URL.Query()["verbose"]; verbose {
+			resp.Checks = startup.results()
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	writeJSON(w, http.StatusServiceUnavailable, probeResponse{Status: "starting", Checks: startup.results()})
+}
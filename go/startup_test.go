@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStartupTrackerNeverRegresses(t *testing.T) {
+	s := newStartupTracker("warmup", "cache")
+	s.complete("warmup")
+	if s.started() {
+		t.Fatal("tracker reported started with a task still pending")
+	}
+	s.complete("cache")
+	if !s.started() {
+		t.Fatal("tracker did not report started after all tasks completed")
+	}
+	s.complete("cache")
+	s.complete("unknown")
+	if !s.started() {
+		t.Error("tracker regressed after redundant completions")
+	}
+}
+
+func TestStartupHandler(t *testing.T) {
+	prev := startup
+	t.Cleanup(func() { startup = prev })
+	startup = newStartupTracker("warmup")
+
+	req, err := http.NewRequest("GET", "/startupz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(startupHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	startup.complete("warmup")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(startupHandler).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+}
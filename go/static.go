@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/fs"
+	"net/http"
+	"strconv"
+)
+
+// staticOther labels requests for paths that aren't embedded files, so
+// clients can't mint new label values.
+const staticOther = "other"
+
+// withStaticMetrics counts requests and bytes per embedded static file.
+// It expects paths relative to files, i.e. to sit inside StripPrefix.
+// Comparing 200s with 304s per file shows how well browser and proxy
+// caching works.
+func withStaticMetrics(files fs.FS) func(http.Handler) http.Handler {
+	known := map[string]bool{}
+	_ = fs.WalkDir(files, ".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			known[path] = true
+		}
+		return nil
+	})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Read the path first: http.FileServer rewrites r.URL.Path.
+			file := r.URL.Path
+			cw := &rwCapture{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+			if !known[file] {
+				file = staticOther
+			}
+			status := cw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			staticRequests.WithLabelValues(file, strconv.Itoa(status)).Inc()
+			staticBytes.WithLabelValues(file).Add(float64(cw.bytes))
+		})
+	}
+}
@@ -18,7 +18,9 @@ function renderInfo(data) {
     <div class="info-item"><div class="info-label">Build Time:</div><div>${data.buildTime || 'Not available'}</div></div>
     <div class="info-item"><div class="info-label">Uptime:</div><div id="uptime">${data.uptime}</div></div>
     <div class="info-item"><div class="info-label">Hostname:</div><div>${data.hostname}</div></div>
+    <div class="info-item"><div class="info-label">Replica:</div><div><span class="replica-badge" style="background:${data.replica.color}">${data.replica.id}</span> ${data.replica.colorName}</div></div>
   `;
+  document.documentElement.style.setProperty('--replica', data.replica.color);
 }
 
 async function refreshInfo() {
@@ -39,13 +41,36 @@ async function refreshHealth() {
     try { const r = await fetch(url, { cache: 'no-store' }); return r.ok; }
     catch { return false; }
   };
-  setText('live-status', (await tryFetch('/live')) ? 'OK' : 'FAIL');
-  setText('ready-status', (await tryFetch('/ready')) ? 'OK' : 'WAIT');
+  setText('live-status', (await tryFetch('/livez')) ? 'OK' : 'FAIL');
+  setText('ready-status', (await tryFetch('/readyz')) ? 'OK' : 'WAIT');
+}
+
+async function refreshChangelog() {
+  try {
+    const data = await fetchJSON('/api/changelog');
+    let summary = `Version ${data.current}`;
+    if (data.rollback) summary = `Rolled back from ${data.previous} to ${data.current}; reverted:`;
+    else if (data.previous) summary += ` (previously ${data.previous})`;
+    setText('changelog-summary', summary);
+
+    const list = document.getElementById('changelog');
+    list.replaceChildren();
+    for (const rel of data.shipped) {
+      for (const c of rel.changes) {
+        const li = document.createElement('li');
+        li.textContent = `${rel.version} · ${c.type}: ${c.text}`;
+        list.appendChild(li);
+      }
+    }
+  } catch (e) {
+    setText('changelog-summary', `Changelog unavailable: ${e.message}`);
+  }
 }
 
 document.addEventListener('DOMContentLoaded', async () => {
   await refreshInfo();
   await refreshHealth();
+  await refreshChangelog();
   // Light auto-refresh of uptime/health every 5s
   setInterval(refreshInfo, 5000);
   setInterval(refreshHealth, 5000);
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWithStaticMetrics(t *testing.T) {
+	files := fstest.MapFS{"app.js": {Data: []byte("console.log(1)"), ModTime: time.Unix(1700000000, 0)}}
+	h := http.StripPrefix("/static/", chain(http.FileServer(http.FS(files)), withStaticMetrics(files)))
+	get := func(path string, hdr map[string]string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range hdr {
+			r.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	ok := testutil.ToFloat64(staticRequests.WithLabelValues("app.js", "200"))
+	notModified := testutil.ToFloat64(staticRequests.WithLabelValues("app.js", "304"))
+	bytes := testutil.ToFloat64(staticBytes.WithLabelValues("app.js"))
+	missing := testutil.ToFloat64(staticRequests.WithLabelValues(staticOther, "404"))
+
+	get("/static/app.js", nil)
+	get("/static/app.js", map[string]string{"If-Modified-Since": time.Unix(1700000000, 0).UTC().Format(http.TimeFormat)})
+	get("/static/nope-123.js", nil)
+
+	if d := testutil.ToFloat64(staticRequests.WithLabelValues("app.js", "200")) - ok; d != 1 {
+		t.Errorf("200s grew by %v, want 1", d)
+	}
+	if d := testutil.ToFloat64(staticRequests.WithLabelValues("app.js", "304")) - notModified; d != 1 {
+		t.Errorf("304s grew by %v, want 1", d)
+	}
+	if d := testutil.ToFloat64(staticBytes.WithLabelValues("app.js")) - bytes; d != float64(len("console.log(1)")) {
+		t.Errorf("bytes grew by %v", d)
+	}
+	if d := testutil.ToFloat64(staticRequests.WithLabelValues(staticOther, "404")) - missing; d != 1 {
+		t.Errorf("unknown files should be labelled %q", staticOther)
+	}
+}
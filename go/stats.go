@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// GET /api/stats is a one-shot JSON snapshot for curl-based inspection
+// without a Prometheus stack: request totals, error rate and latency
+// quantiles across all routes since start, plus uptime, goroutines, open
+// connections and heap. Latency quantiles are estimated from the
+// http_request_duration_seconds buckets, so they are only as precise as
+// the bucket layout. Like /metrics it is subject to the debug access
+// policy.
+type statsSnapshot struct {
+	Uptime        string         `json:"uptime"`
+	UptimeSeconds float64        `json:"uptimeSeconds"`
+	Requests      uint64         `json:"requests"`
+	Errors        uint64         `json:"errors"`
+	ErrorRate     float64        `json:"errorRate"`
+	LatencyP50Ms  float64        `json:"latencyP50Ms"`
+	LatencyP95Ms  float64        `json:"latencyP95Ms"`
+	LatencyP99Ms  float64        `json:"latencyP99Ms"`
+	Goroutines    int            `json:"goroutines"`
+	Connections   map[string]int `json:"connections"`
+	Heap          heapStats      `json:"heap"`
+	GC            gcStats        `json:"gc"`
+	Build         buildDetail    `json:"build"`
+}
+
+type heapStats struct {
+	AllocBytes uint64 `json:"allocBytes"`
+	InuseBytes uint64 `json:"inuseBytes"`
+	SysBytes   uint64 `json:"sysBytes"`
+	Objects    uint64 `json:"objects"`
+}
+
+type gcStats struct {
+	Cycles       uint32  `json:"cycles"`
+	PauseTotalMs float64 `json:"pauseTotalMs"`
+}
+
+type buildDetail struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+func collectStats() (statsSnapshot, error) {
+	byRoute, err := requestLatencyByRoute()
+	if err != nil {
+		return statsSnapshot{}, err
+	}
+	total := &requestLatency{buckets: make(map[float64]uint64)}
+	for _, a := range byRoute {
+		total.add(a)
+	}
+
+	up := time.Since(startTime)
+	s := statsSnapshot{
+		Uptime:        up.Truncate(time.Second).String(),
+		UptimeSeconds: up.Seconds(),
+		Requests:      total.count,
+		Errors:        total.errors,
+		Goroutines:    runtime.NumGoroutine(),
+		Connections:   conns.open(),
+		Build:         buildDetail{Version: version, Commit: commit, GoVersion: runtime.Version()},
+	}
+	if total.count > 0 {
+		s.ErrorRate = float64(total.errors) / float64(total.count)
+		s.LatencyP50Ms = bucketQuantile(0.5, total.buckets, total.count) * 1000
+		s.LatencyP95Ms = bucketQuantile(0.95, total.buckets, total.count) * 1000
+		s.LatencyP99Ms = bucketQuantile(0.99, total.buckets, total.count) * 1000
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.Heap = heapStats{AllocBytes: ms.HeapAlloc, InuseBytes: ms.HeapInuse, SysBytes: ms.HeapSys, Objects: ms.HeapObjects}
+	s.GC = gcStats{Cycles: ms.NumGC, PauseTotalMs: float64(ms.PauseTotalNs) / 1e6}
+	return s, nil
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	s, err := collectStats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "gather metrics: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
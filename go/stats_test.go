@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatsHandler(t *testing.T) {
+	before, err := collectStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/stats-ok", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withMetrics()))
+	mux.Handle("/api/stats-fail", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}), withMetrics()))
+	for _, p := range []string{"/api/stats-ok", "/api/stats-ok", "/api/stats-fail"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	statsHandler(rr, httptest.NewRequest("GET", "/api/stats", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d", rr.Code)
+	}
+	var s statsSnapshot
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Requests - before.Requests; got != 3 {
+		t.Errorf("requests grew by %d, want 3", got)
+	}
+	if got := s.Errors - before.Errors; got != 1 {
+		t.Errorf("errors grew by %d, want 1", got)
+	}
+	if s.ErrorRate <= 0 || s.ErrorRate > 1 {
+		t.Errorf("errorRate = %v", s.ErrorRate)
+	}
+	if s.LatencyP50Ms > s.LatencyP95Ms || s.LatencyP95Ms > s.LatencyP99Ms {
+		t.Errorf("quantiles out of order: %v %v %v", s.LatencyP50Ms, s.LatencyP95Ms, s.LatencyP99Ms)
+	}
+	if s.Goroutines == 0 || s.Heap.AllocBytes == 0 || s.Uptime == "" {
+		t.Errorf("runtime fields missing: %+v", s)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsD export, for environments that run a Datadog or Telegraf agent
+// rather than scraping /metrics. Setting STATSD_ADDR (host:port) sends
+// request counters and timers over UDP in DogStatsD format: names are
+// prefixed with STATSD_PREFIX (default "app.") and carry STATSD_TAGS
+// ("env:prod,team:web") plus per-metric tags. Lines are batched into
+// packets and flushed every STATSD_FLUSH_INTERVAL; if the agent can't keep
+// up, metrics are dropped rather than slowing requests down. Prometheus
+// metrics are unaffected.
+var statsd *statsdClient
+
+const statsdMaxPacket = 1432 // fits a typical 1500-byte MTU
+
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+	lines  chan string
+}
+
+func startStatsDFromEnv() error {
+	addr := getenv("STATSD_ADDR", "")
+	if addr == "" {
+		return nil
+	}
+	flush := getenvDuration("STATSD_FLUSH_INTERVAL", time.Second)
+	if flush <= 0 {
+		return fmt.Errorf("STATSD_FLUSH_INTERVAL must be positive, got %s", flush)
+	}
+	c, err := newStatsDClient(addr, getenv("STATSD_PREFIX", "app."), getenv("STATSD_TAGS", ""))
+	if err != nil {
+		return err
+	}
+	go c.run(flush)
+	statsd = c
+	logger.Info("statsd export enabled", "addr", addr, "prefix", c.prefix)
+	return nil
+}
+
+func newStatsDClient(addr, prefix, tags string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &statsdClient{conn: conn, prefix: prefix, lines: make(chan string, 4096)}
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			c.tags = append(c.tags, statsdTag(t))
+		}
+	}
+	return c, nil
+}
+
+// statsdTag strips the characters that delimit DogStatsD fields and tags.
+func statsdTag(s string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(s)
+}
+
+// count sends a counter increment. A nil client is a no-op.
+func (c *statsdClient) count(name string, v int64, tags ...string) {
+	c.send(name, strconv.FormatInt(v, 10), "c", tags)
+}
+
+// timing sends a timer in milliseconds. A nil client is a no-op.
+func (c *statsdClient) timing(name string, d time.Duration, tags ...string) {
+	c.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64), "ms", tags)
+}
+
+func (c *statsdClient) send(name, value, kind string, tags []string) {
+	if c == nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(c.prefix + name + ":" + value + "|" + kind)
+	for i, t := range append(c.tags[:len(c.tags):len(c.tags)], tags...) {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(statsdTag(t))
+	}
+	select {
+	case c.lines <- b.String():
+	default:
+		statsdDropped.Inc()
+	}
+}
+
+// run batches queued lines into packets.
+func (c *statsdClient) run(every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	var buf []byte
+	flush := func() {
+		if len(buf) > 0 {
+			_, _ = c.conn.Write(buf)
+			buf = buf[:0]
+		}
+	}
+	for {
+		select {
+		case line := <-c.lines:
+			if len(buf) > 0 && len(buf)+1+len(line) > statsdMaxPacket {
+				flush()
+			}
+			if len(buf) > 0 {
+				buf = append(buf, '\n')
+			}
+			buf = append(buf, line...)
+		case <-t.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsDSendsTaggedLines(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	c, err := newStatsDClient(pc.LocalAddr().String(), "demo.", "env:test, team:web|x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go c.run(10 * time.Millisecond)
+	c.count("http.requests", 1, "route:/api/kv/{key}")
+	c.timing("http.request.duration", 1500*time.Microsecond, "route:/")
+
+	buf := make([]byte, statsdMaxPacket)
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var lines []string
+	for len(lines) < 2 {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	want := []string{
+		"demo.http.requests:1|c|#env:test,team:web_x,route:/api/kv/{key}",
+		"demo.http.request.duration:1.500|ms|#env:test,team:web_x,route:/",
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestStatsDNilClientIsNoop(t *testing.T) {
+	var c *statsdClient
+	c.count("x", 1)
+	c.timing("y", time.Second)
+}
+
+func TestStartStatsDRejectsNonPositiveFlushInterval(t *testing.T) {
+	t.Setenv("STATSD_ADDR", "127.0.0.1:8125")
+	t.Setenv("STATSD_FLUSH_INTERVAL", "0s")
+	if err := startStatsDFromEnv(); err == nil || !strings.Contains(err.Error(), "STATSD_FLUSH_INTERVAL") {
+		t.Errorf("expected a STATSD_FLUSH_INTERVAL error, got %v", err)
+	}
+}
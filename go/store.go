@@ -0,0 +1,533 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists the app's mutable state so it can outlive a restart. The
+// KV scratchpad and the ledger keep serving from memory, with their caps,
+// TTLs and memory-budget accounting, and write through to the Store; on
+// startup they are reloaded from it. STORE_BACKEND selects the backend:
+//
+//	memory  nothing survives a restart (the default)
+//	file    JSON files under STORE_DIR (default ./data)
+//	sql     tables in the DB_DSN database
+//
+// Handlers never see the Store, so backends can be swapped per demo
+// environment without touching them.
+//
+// Writes to the file and sql backends are queued and applied in order by a
+// background writer, so a slow or hung backend never holds up a request or
+// a health probe while kv or the ledger holds its lock. The queue holds
+// STORE_QUEUE_SIZE (default 1024) writes; when it is full, writes are
+// dropped and counted as failures. SQL writes time out after
+// STORE_WRITE_TIMEOUT (default 2s).
+type Store interface {
+	LoadKV() (map[string]kvItem, error)
+	PutKV(key string, it kvItem) error
+	DeleteKV(key string) error
+	// LoadLedger returns up to the newest limit entries, oldest first.
+	LoadLedger(limit int) ([]ledgerEntry, error)
+	AppendLedger(e ledgerEntry) error
+	Close() error
+}
+
+func openStore(backend string) (Store, error) {
+	switch backend {
+	case "", "memory":
+		return memoryStore{}, nil
+	case "file":
+		fs, err := openFileStore(getenv("STORE_DIR", "data"))
+		if err != nil {
+			return nil, err
+		}
+		return newAsyncStore(fs, getenvInt("STORE_QUEUE_SIZE", 1024)), nil
+	case "sql":
+		if appDB == nil {
+			return nil, errors.New("STORE_BACKEND=sql needs DB_DSN")
+		}
+		s, err := openSQLStore(appDB, appDBDriver, getenvDuration("STORE_WRITE_TIMEOUT", 2*time.Second))
+		if err != nil {
+			return nil, err
+		}
+		return newAsyncStore(s, getenvInt("STORE_QUEUE_SIZE", 1024)), nil
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q (want memory, file or sql)", backend)
+	}
+}
+
+// storeWrite logs and counts a failed write. The in-memory copy stays
+// authoritative, so a flaky backend costs durability, not availability.
+func storeWrite(op string, err error) {
+	if err != nil {
+		storeErrors.WithLabelValues(op).Inc()
+		logger.Warn("store write failed", "op", op, "err", err)
+	}
+}
+
+// asyncStore hands a backend's writes to a background writer through a
+// bounded queue. Loads go straight to the backend; they only happen at
+// startup, before any writes.
+type asyncStore struct {
+	Store
+	queue chan storeOp
+	done  chan struct{}
+
+	// mu guards closed against enqueue racing Close; writers such as
+	// abandoned timeout handlers can outlive the server.
+	mu     sync.RWMutex
+	closed bool
+}
+
+type storeOp struct {
+	name string
+	run  func() error
+}
+
+func newAsyncStore(s Store, size int) *asyncStore {
+	a := &asyncStore{Store: s, queue: make(chan storeOp, max(size, 1)), done: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+func (a *asyncStore) run() {
+	defer close(a.done)
+	for op := range a.queue {
+		storeWrite(op.name, op.run())
+	}
+}
+
+// enqueue queues a write without blocking. Errors are reported by the
+// writer, so the returned error is always nil. Writes after Close are
+// dropped and reported as failures.
+func (a *asyncStore) enqueue(name string, run func() error) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		storeWrite(name, errors.New("store closed, write dropped"))
+		return nil
+	}
+	select {
+	case a.queue <- storeOp{name, run}:
+	default:
+		storeWrite(name, errors.New("store write queue full, write dropped"))
+	}
+	return nil
+}
+
+func (a *asyncStore) PutKV(key string, it kvItem) error {
+	return a.enqueue("kv-put", func() error { return a.Store.PutKV(key, it) })
+}
+
+func (a *asyncStore) DeleteKV(key string) error {
+	return a.enqueue("kv-delete", func() error { return a.Store.DeleteKV(key) })
+}
+
+func (a *asyncStore) AppendLedger(e ledgerEntry) error {
+	return a.enqueue("ledger-append", func() error { return a.Store.AppendLedger(e) })
+}
+
+// Close applies the queued writes, then closes the backend.
+func (a *asyncStore) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	<-a.done
+	return a.Store.Close()
+}
+
+// memoryStore is the reference implementation: kv and the ledger already
+// hold everything in process, so it persists nothing and always starts
+// empty.
+type memoryStore struct{}
+
+func (memoryStore) LoadKV() (map[string]kvItem, error)    { return nil, nil }
+func (memoryStore) PutKV(string, kvItem) error            { return nil }
+func (memoryStore) DeleteKV(string) error                 { return nil }
+func (memoryStore) LoadLedger(int) ([]ledgerEntry, error) { return nil, nil }
+func (memoryStore) AppendLedger(ledgerEntry) error        { return nil }
+func (memoryStore) Close() error                          { return nil }
+
+// storedKV is the serialized form of a KV item.
+type storedKV struct {
+	Value       []byte    `json:"value"`
+	ContentType string    `json:"contentType,omitempty"`
+	Expires     time.Time `json:"expires"`
+}
+
+// fileStore keeps the KV scratchpad as a kv.json snapshot plus a
+// kv.journal.jsonl of changes since, and the ledger as an append-only
+// ledger.jsonl that is compacted to the retained entries on load. Each KV
+// change appends one journal line; the journal is folded into the
+// snapshot on load and whenever it outgrows the snapshot.
+type fileStore struct {
+	dir string
+
+	mu         sync.Mutex
+	kv         map[string]storedKV
+	journalLen int
+}
+
+// kvJournalEntry is one line of kv.journal.jsonl. Item is nil for deletes.
+type kvJournalEntry struct {
+	Key  string    `json:"key"`
+	Item *storedKV `json:"item,omitempty"`
+}
+
+// kvJournalMin is the journal length below which it is never compacted.
+const kvJournalMin = 1000
+
+func openFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir, kv: make(map[string]storedKV)}, nil
+}
+
+func (f *fileStore) LoadKV() (map[string]kvItem, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, err := os.ReadFile(filepath.Join(f.dir, "kv.json"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &f.kv); err != nil {
+			return nil, fmt.Errorf("parse kv.json: %w", err)
+		}
+	}
+	if err := f.replayJournalLocked(); err != nil {
+		return nil, err
+	}
+	if f.journalLen > 0 {
+		if err := f.compactKVLocked(); err != nil {
+			return nil, err
+		}
+	}
+	out := make(map[string]kvItem, len(f.kv))
+	for k, s := range f.kv {
+		out[k] = kvItem{value: s.Value, contentType: s.ContentType, expires: s.Expires}
+	}
+	return out, nil
+}
+
+func (f *fileStore) replayJournalLocked() error {
+	file, err := os.Open(filepath.Join(f.dir, "kv.journal.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var e kvJournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue // a torn final line from a crash
+		}
+		if e.Item != nil {
+			f.kv[e.Key] = *e.Item
+		} else {
+			delete(f.kv, e.Key)
+		}
+		f.journalLen++
+	}
+	return sc.Err()
+}
+
+func (f *fileStore) PutKV(key string, it kvItem) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := storedKV{Value: it.value, ContentType: it.contentType, Expires: it.expires}
+	f.kv[key] = s
+	return f.journalLocked(kvJournalEntry{Key: key, Item: &s})
+}
+
+func (f *fileStore) DeleteKV(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.kv[key]; !ok {
+		return nil
+	}
+	delete(f.kv, key)
+	return f.journalLocked(kvJournalEntry{Key: key})
+}
+
+// journalLocked appends e to the journal, compacting once the journal is
+// longer than twice the number of live keys.
+func (f *fileStore) journalLocked(e kvJournalEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := appendLine(filepath.Join(f.dir, "kv.journal.jsonl"), b); err != nil {
+		return err
+	}
+	f.journalLen++
+	if f.journalLen > max(kvJournalMin, 2*len(f.kv)) {
+		return f.compactKVLocked()
+	}
+	return nil
+}
+
+// compactKVLocked writes a fresh snapshot and empties the journal. A crash
+// in between only means the journal is replayed onto a snapshot that
+// already contains it, which gives the same result.
+func (f *fileStore) compactKVLocked() error {
+	b, err := json.Marshal(f.kv)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(f.dir, "kv.json"), b); err != nil {
+		return err
+	}
+	if err := os.Truncate(filepath.Join(f.dir, "kv.journal.jsonl"), 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	f.journalLen = 0
+	return nil
+}
+
+func (f *fileStore) LoadLedger(limit int) ([]ledgerEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := filepath.Join(f.dir, "ledger.jsonl")
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []ledgerEntry
+	var lines [][]byte
+	sc := bufio.NewScanner(file)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var e struct {
+			ledgerEntry
+			Details json.RawMessage `json:"details,omitempty"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue // a torn final line from a crash
+		}
+		if e.Details != nil {
+			e.ledgerEntry.Details = e.Details
+		}
+		entries = append(entries, e.ledgerEntry)
+		lines = append(lines, append([]byte(nil), sc.Bytes()...))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+		lines = lines[len(lines)-limit:]
+	}
+
+	var compacted []byte
+	for _, l := range lines {
+		compacted = append(append(compacted, l...), '\n')
+	}
+	return entries, writeFileAtomic(path, compacted)
+}
+
+func (f *fileStore) AppendLedger(e ledgerEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return appendLine(filepath.Join(f.dir, "ledger.jsonl"), b)
+}
+
+func (f *fileStore) Close() error { return nil }
+
+func appendLine(path string, b []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sqlStore keeps state in app_kv and app_ledger_entries tables, created on
+// open. Times are stored as Unix nanoseconds so MySQL DSNs work without
+// parseTime. Ledger rows are keyed by a database-generated seq, because
+// every replica and worker numbers its entries from its own counter; a
+// reloaded ledger takes its IDs from seq.
+type sqlStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+	timeout time.Duration
+}
+
+type sqlDialect struct {
+	name   string
+	schema []string
+	upsert string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"pgx": {
+		name: "pgx",
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS app_kv (k TEXT PRIMARY KEY, v BYTEA NOT NULL, content_type TEXT NOT NULL, expires BIGINT NOT NULL)`,
+			`CREATE TABLE IF NOT EXISTS app_ledger_entries (seq BIGSERIAL PRIMARY KEY, at BIGINT NOT NULL, kind TEXT NOT NULL, subject TEXT NOT NULL, message TEXT NOT NULL, details TEXT)`,
+		},
+		upsert: `INSERT INTO app_kv (k, v, content_type, expires) VALUES (?, ?, ?, ?)
+			ON CONFLICT (k) DO UPDATE SET v = EXCLUDED.v, content_type = EXCLUDED.content_type, expires = EXCLUDED.expires`,
+	},
+	"mysql": {
+		name: "mysql",
+		schema: []string{
+			`CREATE TABLE IF NOT EXISTS app_kv (k VARCHAR(256) PRIMARY KEY, v LONGBLOB NOT NULL, content_type VARCHAR(255) NOT NULL, expires BIGINT NOT NULL)`,
+			`CREATE TABLE IF NOT EXISTS app_ledger_entries (seq BIGINT AUTO_INCREMENT PRIMARY KEY, at BIGINT NOT NULL, kind VARCHAR(64) NOT NULL, subject VARCHAR(255) NOT NULL, message TEXT NOT NULL, details TEXT)`,
+		},
+		upsert: `INSERT INTO app_kv (k, v, content_type, expires) VALUES (?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE v = VALUES(v), content_type = VALUES(content_type), expires = VALUES(expires)`,
+	},
+}
+
+func openSQLStore(db *sql.DB, driver string, timeout time.Duration) (*sqlStore, error) {
+	d, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("no SQL store dialect for driver %q", driver)
+	}
+	s := &sqlStore{db: db, dialect: d, timeout: timeout}
+	for _, stmt := range d.schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("create store tables: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// q rewrites ? placeholders to $n for Postgres.
+func (s *sqlStore) q(query string) string {
+	if s.dialect.name != "pgx" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *sqlStore) LoadKV() (map[string]kvItem, error) {
+	rows, err := s.db.Query(s.q(`SELECT k, v, content_type, expires FROM app_kv WHERE expires > ?`), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]kvItem)
+	for rows.Next() {
+		var key string
+		var it kvItem
+		var expires int64
+		if err := rows.Scan(&key, &it.value, &it.contentType, &expires); err != nil {
+			return nil, err
+		}
+		it.expires = time.Unix(0, expires)
+		out[key] = it
+	}
+	return out, rows.Err()
+}
+
+// exec runs a write, giving up after the store's timeout.
+func (s *sqlStore) exec(query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, s.q(query), args...)
+	return err
+}
+
+func (s *sqlStore) PutKV(key string, it kvItem) error {
+	return s.exec(s.dialect.upsert, key, it.value, it.contentType, it.expires.UnixNano())
+}
+
+func (s *sqlStore) DeleteKV(key string) error {
+	return s.exec(`DELETE FROM app_kv WHERE k = ?`, key)
+}
+
+func (s *sqlStore) LoadLedger(limit int) ([]ledgerEntry, error) {
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+	rows, err := s.db.Query(s.q(`SELECT seq, at, kind, subject, message, details FROM app_ledger_entries ORDER BY seq DESC LIMIT ?`), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ledgerEntry
+	for rows.Next() {
+		var e ledgerEntry
+		var at int64
+		var details sql.NullString
+		if err := rows.Scan(&e.ID, &at, &e.Kind, &e.Subject, &e.Message, &details); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, at).UTC()
+		if details.Valid {
+			e.Details = json.RawMessage(details.String)
+		}
+		out = append(out, e)
+	}
+	slices.Reverse(out)
+	return out, rows.Err()
+}
+
+func (s *sqlStore) AppendLedger(e ledgerEntry) error {
+	var details sql.NullString
+	if e.Details != nil {
+		b, err := json.Marshal(e.Details)
+		if err != nil {
+			return err
+		}
+		details = sql.NullString{String: string(b), Valid: true}
+	}
+	return s.exec(`INSERT INTO app_ledger_entries (at, kind, subject, message, details) VALUES (?, ?, ?, ?, ?)`,
+		e.Time.UnixNano(), e.Kind, e.Subject, e.Message, details)
+}
+
+// Close is a no-op; the pool belongs to appDB.
+func (s *sqlStore) Close() error { return nil }
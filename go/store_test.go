@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFileStoreRestoresKV(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newKVStore(10, 64, time.Minute, time.Hour)
+	if err := s.attach(fs); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.put("keep", []byte("v1"), "text/plain", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.put("gone", []byte("v2"), "", 0); err != nil {
+		t.Fatal(err)
+	}
+	s.delete("gone")
+
+	// A fresh process pointed at the same directory sees the surviving key.
+	fs2, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := newKVStore(10, 64, time.Minute, time.Hour)
+	if err := s2.attach(fs2); err != nil {
+		t.Fatal(err)
+	}
+	it, ok := s2.get("keep")
+	if !ok || string(it.value) != "v1" || it.contentType != "text/plain" {
+		t.Errorf("restored keep = %+v, %v", it, ok)
+	}
+	if _, ok := s2.get("gone"); ok {
+		t.Error("deleted key came back after reload")
+	}
+}
+
+func TestFileStoreRestoresLedger(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newLedger(3)
+	if err := l.attach(fs); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		l.record("test", "", "entry", map[string]int{"i": i})
+	}
+
+	fs2, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2 := newLedger(3)
+	if err := l2.attach(fs2); err != nil {
+		t.Fatal(err)
+	}
+	got := l2.list("", 0)
+	if len(got) != 3 || got[0].ID != 3 || got[2].ID != 5 {
+		t.Fatalf("reloaded ledger = %+v, want entries 3-5", got)
+	}
+	if d, _ := json.Marshal(got[2].Details); string(d) != `{"i":4}` {
+		t.Errorf("details of last entry = %s", d)
+	}
+	if e := l2.record("test", "", "after restart", nil); e.ID != 6 {
+		t.Errorf("ID after reload = %d, want 6", e.ID)
+	}
+}
+
+func TestFileStoreCompactsKVJournal(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= kvJournalMin; i++ {
+		if err := fs.PutKV("k", kvItem{value: []byte(strconv.Itoa(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "kv.journal.jsonl")); err != nil || fi.Size() != 0 {
+		t.Fatalf("journal after %d writes: %v, %v; want it compacted", kvJournalMin+1, fi, err)
+	}
+	fs.PutKV("k", kvItem{value: []byte("last")})
+
+	fs2, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := fs2.LoadKV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || string(items["k"].value) != "last" {
+		t.Errorf("reloaded items = %+v, want k=last", items)
+	}
+}
+
+func TestAsyncStoreAppliesWritesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAsyncStore(fs, 16)
+	a.PutKV("k", kvItem{value: []byte("v1")})
+	a.PutKV("k", kvItem{value: []byte("v2")})
+	a.PutKV("gone", kvItem{value: []byte("x")})
+	a.DeleteKV("gone")
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fs2, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, err := fs2.LoadKV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || string(items["k"].value) != "v2" {
+		t.Errorf("items after close = %+v, want only k=v2", items)
+	}
+}
+
+func TestAsyncStoreDropsWritesAfterClose(t *testing.T) {
+	a := newAsyncStore(memoryStore{}, 4)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	before := testutil.ToFloat64(storeErrors.WithLabelValues("kv-put"))
+	a.PutKV("k", kvItem{value: []byte("v")}) // must not panic
+	if got := testutil.ToFloat64(storeErrors.WithLabelValues("kv-put")); got != before+1 {
+		t.Errorf("dropped write counted %v times, want 1", got-before)
+	}
+	if err := a.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestSQLStorePlaceholders(t *testing.T) {
+	pg := &sqlStore{dialect: sqlDialects["pgx"]}
+	if got := pg.q("DELETE FROM app_kv WHERE k = ? AND expires < ?"); got != "DELETE FROM app_kv WHERE k = $1 AND expires < $2" {
+		t.Errorf("pgx query = %q", got)
+	}
+	my := &sqlStore{dialect: sqlDialects["mysql"]}
+	if got := my.q("DELETE FROM app_kv WHERE k = ?"); got != "DELETE FROM app_kv WHERE k = ?" {
+		t.Errorf("mysql query = %q", got)
+	}
+}
+
+func TestOpenStoreRejectsUnknownBackend(t *testing.T) {
+	if _, err := openStore("etcd"); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+	if _, err := openStore("sql"); err == nil {
+		t.Error("expected an error for sql without DB_DSN")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+)
+
+// Worker mode: `app --workers N` starts a supervisor that re-executes the
+// binary N times. Each child (identified by WORKER_ID) binds the public port
+// with SO_REUSEPORT so the kernel spreads connections across them, and
+// exposes its own metrics on a loopback port the supervisor scrapes and
+// re-exports with a worker label.
+var (
+	workerID              = getenv("WORKER_ID", "")
+	workerMetricsBasePort = getenvInt("WORKER_METRICS_BASE_PORT", 9100)
+	supervisorAddr        = getenv("SUPERVISOR_ADDR", ":9090")
+)
+
+var (
+	supervisorRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "supervisor_worker_restarts_total",
+		Help: "Worker processes restarted after exiting unexpectedly.",
+	}, []string{"worker"})
+	supervisorWorkersUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "supervisor_workers_running",
+		Help: "Worker processes currently running.",
+	})
+)
+
+func workerMetricsAddr(id int) string {
+	return "127.0.0.1:" + strconv.Itoa(workerMetricsBasePort+id)
+}
+
+// runSupervisor starts n workers, restarts any that crash, and serves their
+// aggregated metrics on SUPERVISOR_ADDR until SIGINT/SIGTERM, which is
+// forwarded to every worker.
+func runSupervisor(n int) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(supervisorRestarts, supervisorWorkersUp)
+	urls := make([]string, n)
+	for i := range urls {
+		urls[i] = "http://" + workerMetricsAddr(i) + "/metrics"
+	}
+	gatherers := prometheus.Gatherers{reg, &workerGatherer{urls: urls, client: &http.Client{Timeout: 5 * time.Second}}}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", chain(promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}), withDebugAccess(debugAccess)))
+	metricsSrv := &http.Server{Addr: supervisorAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("supervisor metrics server failed", "err", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("supervisor starting", "workers", n, "metricsAddr", supervisorAddr)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			superviseWorker(ctx, exe, id)
+		}(i)
+	}
+	wg.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = metricsSrv.Shutdown(shutdownCtx)
+	logger.Info("supervisor stopped")
+	return nil
+}
+
+// superviseWorker keeps worker id running until ctx is cancelled, backing
+// off exponentially when it crash-loops.
+func superviseWorker(ctx context.Context, exe string, id int) {
+	label := strconv.Itoa(id)
+	backoff := time.Second
+	for {
+		cmd := exec.Command(exe, os.Args[1:]...)
+		cmd.Env = append(os.Environ(),
+			"WORKER_ID="+label,
+			"WORKER_METRICS_BASE_PORT="+strconv.Itoa(workerMetricsBasePort),
+		)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+		started := time.Now()
+		if err := cmd.Start(); err != nil {
+			logger.Error("worker failed to start", "worker", id, "err", err)
+		} else {
+			supervisorWorkersUp.Inc()
+			done := make(chan error, 1)
+			go func() { done <- cmd.Wait() }()
+			select {
+			case err := <-done:
+				supervisorWorkersUp.Dec()
+				logger.Error("worker exited", "worker", id, "pid", cmd.Process.Pid, "err", err)
+			case <-ctx.Done():
+				_ = cmd.Process.Signal(syscall.SIGTERM)
+				<-done
+				supervisorWorkersUp.Dec()
+				return
+			}
+		}
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, 30*time.Second)
+		supervisorRestarts.WithLabelValues(label).Inc()
+	}
+}
+
+// workerGatherer scrapes each worker's metrics endpoint and merges the
+// results, tagging every series with a worker label. Unreachable workers
+// are skipped and reported as a gather error.
+type workerGatherer struct {
+	urls   []string
+	client *http.Client
+}
+
+func (g *workerGatherer) Gather() ([]*dto.MetricFamily, error) {
+	merged := make(map[string]*dto.MetricFamily)
+	var errs prometheus.MultiError
+	for id, url := range g.urls {
+		families, err := g.scrape(url)
+		if err != nil {
+			errs.Append(fmt.Errorf("worker %d: %w", id, err))
+			continue
+		}
+		label := strconv.Itoa(id)
+		for name, mf := range families {
+			for _, m := range mf.Metric {
+				m.Label = append(m.Label, &dto.LabelPair{Name: proto.String("worker"), Value: proto.String(label)})
+			}
+			if existing, ok := merged[name]; ok {
+				existing.Metric = append(existing.Metric, mf.Metric...)
+				continue
+			}
+			merged[name] = mf
+		}
+	}
+
+	out := make([]*dto.MetricFamily, 0, len(merged))
+	for _, mf := range merged {
+		out = append(out, mf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GetName() < out[j].GetName() })
+	return out, errs.MaybeUnwrap()
+}
+
+func (g *workerGatherer) scrape(url string) (map[string]*dto.MetricFamily, error) {
+	resp, err := g.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var p expfmt.TextParser
+	return p.TextToMetricFamilies(resp.Body)
+}
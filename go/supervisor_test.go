@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWorkerGathererAddsWorkerLabel(t *testing.T) {
+	var urls []string
+	for i := 0; i < 2; i++ {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "# TYPE kv_keys gauge\nkv_keys %d\n", i+1)
+		}))
+		t.Cleanup(srv.Close)
+		urls = append(urls, srv.URL)
+	}
+	urls = append(urls, "http://127.0.0.1:1/metrics") // a crashed worker
+
+	g := &workerGatherer{urls: urls, client: http.DefaultClient}
+	families, err := g.Gather()
+	if err == nil {
+		t.Error("expected an error for the unreachable worker")
+	}
+	if len(families) != 1 || len(families[0].Metric) != 2 {
+		t.Fatalf("expected one family with two series, got %v", families)
+	}
+	for i, m := range families[0].Metric {
+		if got, want := m.GetLabel()[0].GetValue(), fmt.Sprint(i); got != want {
+			t.Errorf("series %d has worker label %q want %q", i, got, want)
+		}
+	}
+}
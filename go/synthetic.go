@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Synthetic signal. When SYNTHETIC_RPS is set (it is off by default, and
+// capped at 1000), a generator records that many simulated requests a
+// second into synthetic_requests_total and
+// synthetic_request_duration_seconds, with an error rate and lognormal
+// latency taken from the active profile, so verification and alerting
+// rules have a signal whose shape is known exactly. With "inject" set, the
+// profile also applies to real responses: non-probe, non-admin requests
+// are delayed and fail with 500 at the same rate.
+//
+// The profile starts from SYNTHETIC_ERROR_RATE, SYNTHETIC_LATENCY_P50_MS,
+// SYNTHETIC_LATENCY_P99_MS and SYNTHETIC_INJECT, and is changed with PUT
+// /api/admin/synthetic. A PUT with "rampOver" moves error rate and latency
+// linearly from their current values to the new ones over that duration,
+// so a gradual regression can be played out.
+var synthetic = newSyntheticState(syntheticProfile{
+	ErrorRate:    getenvFloat("SYNTHETIC_ERROR_RATE", 0),
+	LatencyP50Ms: getenvFloat("SYNTHETIC_LATENCY_P50_MS", 0),
+	LatencyP99Ms: getenvFloat("SYNTHETIC_LATENCY_P99_MS", 0),
+	Inject:       getenvBool("SYNTHETIC_INJECT", false),
+})
+
+const eventSynthetic eventType = "synthetic-profile"
+
+type syntheticProfile struct {
+	ErrorRate    float64 `json:"errorRate"`
+	LatencyP50Ms float64 `json:"latencyP50Ms"`
+	LatencyP99Ms float64 `json:"latencyP99Ms"`
+	Inject       bool    `json:"inject"`
+}
+
+func (p syntheticProfile) validate() error {
+	if p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("errorRate must be between 0 and 1")
+	}
+	if p.LatencyP50Ms < 0 || p.LatencyP99Ms < 0 {
+		return fmt.Errorf("latencies must be >= 0")
+	}
+	if p.LatencyP99Ms > 0 && p.LatencyP99Ms < p.LatencyP50Ms {
+		return fmt.Errorf("latencyP99Ms must be >= latencyP50Ms")
+	}
+	return nil
+}
+
+// latency draws one delay: lognormal through p50 and p99, or constant at
+// p50 when p99 is unset.
+func (p syntheticProfile) latency() time.Duration {
+	switch {
+	case p.LatencyP50Ms <= 0:
+		return 0
+	case p.LatencyP99Ms <= p.LatencyP50Ms:
+		return latencyDist{Type: "constant", Ms: p.LatencyP50Ms}.sample()
+	default:
+		return latencyDist{Type: "lognormal", P50Ms: p.LatencyP50Ms, P99Ms: p.LatencyP99Ms}.sample()
+	}
+}
+
+func (p syntheticProfile) fails() bool {
+	return p.ErrorRate > 0 && rand.Float64() < p.ErrorRate
+}
+
+type syntheticState struct {
+	mu        sync.Mutex
+	from, to  syntheticProfile
+	rampStart time.Time
+	rampEnd   time.Time
+}
+
+func newSyntheticState(p syntheticProfile) *syntheticState {
+	if err := p.validate(); err != nil {
+		logger.Error("ignoring synthetic profile from environment", "err", err)
+		p = syntheticProfile{}
+	}
+	return &syntheticState{from: p, to: p}
+}
+
+// current returns the effective profile at now, interpolating during a
+// ramp. Inject switches immediately.
+func (s *syntheticState) current(now time.Time) syntheticProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.at(now)
+}
+
+func (s *syntheticState) at(now time.Time) syntheticProfile {
+	if !now.Before(s.rampEnd) {
+		return s.to
+	}
+	f := float64(now.Sub(s.rampStart)) / float64(s.rampEnd.Sub(s.rampStart))
+	lerp := func(a, b float64) float64 { return a + (b-a)*f }
+	return syntheticProfile{
+		ErrorRate:    lerp(s.from.ErrorRate, s.to.ErrorRate),
+		LatencyP50Ms: lerp(s.from.LatencyP50Ms, s.to.LatencyP50Ms),
+		LatencyP99Ms: lerp(s.from.LatencyP99Ms, s.to.LatencyP99Ms),
+		Inject:       s.to.Inject,
+	}
+}
+
+// set moves towards target over ramp, starting from wherever the current
+// profile (or ramp) is now.
+func (s *syntheticState) set(target syntheticProfile, ramp time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.from = s.at(now)
+	s.to = target
+	s.rampStart = now
+	s.rampEnd = now.Add(ramp)
+}
+
+type syntheticStatus struct {
+	Current  syntheticProfile `json:"current"`
+	Target   syntheticProfile `json:"target"`
+	RampEnds *time.Time       `json:"rampEnds,omitempty"`
+}
+
+func (s *syntheticState) status(now time.Time) syntheticStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := syntheticStatus{Current: s.at(now), Target: s.to}
+	if now.Before(s.rampEnd) {
+		end := s.rampEnd.UTC()
+		st.RampEnds = &end
+	}
+	return st
+}
+
+// runSyntheticGenerator records rps simulated requests a second until the
+// process exits.
+func runSyntheticGenerator(rps int) {
+	t := time.NewTicker(time.Second / time.Duration(rps))
+	defer t.Stop()
+	for now := range t.C {
+		p := synthetic.current(now)
+		outcome := "success"
+		if p.fails() {
+			outcome = "error"
+		}
+		syntheticRequests.WithLabelValues(outcome).Inc()
+		syntheticDuration.Observe(p.latency().Seconds())
+		syntheticProfileGauge.WithLabelValues("error_rate").Set(p.ErrorRate)
+		syntheticProfileGauge.WithLabelValues("latency_p50_seconds").Set(p.LatencyP50Ms / 1000)
+		syntheticProfileGauge.WithLabelValues("latency_p99_seconds").Set(p.LatencyP99Ms / 1000)
+	}
+}
+
+// syntheticMaxRPS caps the generator; well below the rate at which
+// time.Second/rps would round down to a zero ticker interval.
+const syntheticMaxRPS = 1000
+
+// clampSyntheticRPS limits rps to syntheticMaxRPS, logging when it does.
+func clampSyntheticRPS(rps int) int {
+	if rps > syntheticMaxRPS {
+		logger.Warn("SYNTHETIC_RPS too high, capping it", "value", rps, "max", syntheticMaxRPS)
+		return syntheticMaxRPS
+	}
+	return rps
+}
+
+func startSyntheticFromEnv() {
+	if rps := clampSyntheticRPS(getenvInt("SYNTHETIC_RPS", 0)); rps > 0 {
+		go runSyntheticGenerator(rps)
+	}
+}
+
+// withSyntheticFaults applies the profile to real requests when inject is
+// on. Probes and the admin API are exempt so the profile can always be
+// turned off again.
+func withSyntheticFaults() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := synthetic.current(time.Now())
+			if !p.Inject || isProbePath(r.URL.Path) || strings.HasPrefix(r.URL.Path, "/api/admin/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if d := p.latency(); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-r.Context().Done():
+					t.Stop()
+					return
+				}
+			}
+			if p.fails() {
+				writeError(w, http.StatusInternalServerError, "synthetic error")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// syntheticHandler serves /api/admin/synthetic: GET reports the current and
+// target profile, PUT sets a new target (optionally with "rampOver": "5m")
+// and DELETE resets to a clean profile immediately.
+func syntheticHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, synthetic.status(time.Now()))
+
+	case http.MethodPut:
+		var req struct {
+			syntheticProfile
+			RampOver string `json:"rampOver"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := req.syntheticProfile.validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var ramp time.Duration
+		if req.RampOver != "" {
+			d, err := time.ParseDuration(req.RampOver)
+			if err != nil || d < 0 {
+				writeError(w, http.StatusBadRequest, "rampOver must be a non-negative duration")
+				return
+			}
+			ramp = d
+		}
+		synthetic.set(req.syntheticProfile, ramp, time.Now())
+		by := adminPrincipal(r.Context())
+		loggerFrom(r.Context()).Info("synthetic profile set", "errorRate", req.ErrorRate, "latencyP50Ms", req.LatencyP50Ms,
+			"latencyP99Ms", req.LatencyP99Ms, "inject", req.Inject, "rampOver", ramp.String(), "by", by)
+		events.publish(event{Type: eventSynthetic, Message: "synthetic profile set", Data: map[string]any{"target": req.syntheticProfile, "rampOver": ramp.String(), "by": by}})
+		writeJSON(w, http.StatusOK, synthetic.status(time.Now()))
+
+	case http.MethodDelete:
+		synthetic.set(syntheticProfile{}, 0, time.Now())
+		loggerFrom(r.Context()).Info("synthetic profile reset", "by", adminPrincipal(r.Context()))
+		events.publish(event{Type: eventSynthetic, Message: "synthetic profile reset", Data: map[string]any{"by": adminPrincipal(r.Context())}})
+		writeJSON(w, http.StatusOK, synthetic.status(time.Now()))
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withTestSynthetic(t *testing.T) {
+	t.Helper()
+	prev := synthetic
+	synthetic = newSyntheticState(syntheticProfile{})
+	t.Cleanup(func() { synthetic = prev })
+}
+
+func TestSyntheticRamp(t *testing.T) {
+	s := newSyntheticState(syntheticProfile{ErrorRate: 0.1, LatencyP50Ms: 10})
+	start := time.Now()
+	s.set(syntheticProfile{ErrorRate: 0.5, LatencyP50Ms: 50, LatencyP99Ms: 200}, 10*time.Second, start)
+
+	mid := s.current(start.Add(5 * time.Second))
+	if math.Abs(mid.ErrorRate-0.3) > 1e-9 || math.Abs(mid.LatencyP50Ms-30) > 1e-9 || math.Abs(mid.LatencyP99Ms-100) > 1e-9 {
+		t.Errorf("halfway = %+v", mid)
+	}
+	if end := s.current(start.Add(time.Minute)); end.ErrorRate != 0.5 || end.LatencyP99Ms != 200 {
+		t.Errorf("after ramp = %+v", end)
+	}
+
+	// Retargeting mid-ramp starts from where the ramp had got to.
+	s.set(syntheticProfile{}, 10*time.Second, start.Add(5*time.Second))
+	if p := s.current(start.Add(5 * time.Second)); math.Abs(p.ErrorRate-0.3) > 1e-9 {
+		t.Errorf("retarget start = %+v", p)
+	}
+}
+
+func TestSyntheticProfileValidate(t *testing.T) {
+	for _, p := range []syntheticProfile{
+		{ErrorRate: 1.5},
+		{ErrorRate: -0.1},
+		{LatencyP50Ms: 100, LatencyP99Ms: 50},
+	} {
+		if p.validate() == nil {
+			t.Errorf("%+v: expected a validation error", p)
+		}
+	}
+}
+
+func TestClampSyntheticRPS(t *testing.T) {
+	for in, want := range map[int]int{0: 0, 10: 10, syntheticMaxRPS: syntheticMaxRPS, 2_000_000_000: syntheticMaxRPS} {
+		if got := clampSyntheticRPS(in); got != want {
+			t.Errorf("clampSyntheticRPS(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestSyntheticHandler(t *testing.T) {
+	withTestSynthetic(t)
+	withTestLedger(t, 10)
+
+	rr := httptest.NewRecorder()
+	body := `{"errorRate":0.25,"latencyP50Ms":20,"latencyP99Ms":80,"inject":true,"rampOver":"1m"}`
+	syntheticHandler(rr, httptest.NewRequest(http.MethodPut, "/api/admin/synthetic", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rr.Code, rr.Body)
+	}
+	var st syntheticStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Target.ErrorRate != 0.25 || !st.Target.Inject || st.RampEnds == nil {
+		t.Errorf("status = %+v", st)
+	}
+	if st.Current.ErrorRate >= 0.25 {
+		t.Errorf("current error rate %v should still be ramping", st.Current.ErrorRate)
+	}
+	if got := ledger.list(string(eventSynthetic), 0); len(got) != 1 {
+		t.Errorf("ledger has %d synthetic entries, want 1", len(got))
+	}
+
+	rr = httptest.NewRecorder()
+	syntheticHandler(rr, httptest.NewRequest(http.MethodPut, "/api/admin/synthetic", strings.NewReader(`{"errorRate":2}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid PUT status = %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	syntheticHandler(rr, httptest.NewRequest(http.MethodDelete, "/api/admin/synthetic", nil))
+	if p := synthetic.current(time.Now()); p != (syntheticProfile{}) {
+		t.Errorf("after DELETE profile = %+v", p)
+	}
+}
+
+func TestWithSyntheticFaults(t *testing.T) {
+	withTestSynthetic(t)
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withSyntheticFaults())
+	serve := func(path string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+
+	synthetic.set(syntheticProfile{ErrorRate: 1}, 0, time.Now())
+	if code := serve("/api/info"); code != http.StatusOK {
+		t.Errorf("without inject status = %d, want 200", code)
+	}
+	synthetic.set(syntheticProfile{ErrorRate: 1, Inject: true}, 0, time.Now())
+	if code := serve("/api/info"); code != http.StatusInternalServerError {
+		t.Errorf("with inject status = %d, want 500", code)
+	}
+	for _, path := range []string{"/healthz", "/api/admin/synthetic"} {
+		if code := serve(path); code != http.StatusOK {
+			t.Errorf("%s status = %d, want 200 (exempt)", path, code)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request timeouts. REQUEST_TIMEOUT (default 0, no timeout) bounds how long
+// any route may take; REQUEST_TIMEOUTS ("/api/evidence=30s,GET
+// /api/kv/{key}=200ms", keyed by mux pattern) overrides it per route, with 0
+// exempting a route. The handler runs with a context deadline, so
+// downstream calls made with the request context are cancelled when it
+// passes. If the handler hasn't started its response by then, the client
+// gets 504 with a JSON body straight away and the timeout is counted in
+// request_timeouts_total; anything the handler writes afterwards is
+// discarded. A response that has already started (a stream, say) is left to
+// finish with its context cancelled. This replaces the empty response a
+// server-wide WriteTimeout would produce.
+var (
+	requestTimeout  = getenvDuration("REQUEST_TIMEOUT", 0)
+	requestTimeouts = mustParseRequestTimeouts(getenv("REQUEST_TIMEOUTS", ""))
+)
+
+func mustParseRequestTimeouts(spec string) map[string]time.Duration {
+	t, err := parseRequestTimeouts(spec)
+	if err != nil {
+		invalidConfig("REQUEST_TIMEOUTS", err)
+	}
+	return t
+}
+
+// parseRequestTimeouts is parseBudgets, but allows 0 to exempt a route.
+func parseRequestTimeouts(spec string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		// Split on the last '=' in case a pattern contains one.
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid request timeout %q, want pattern=duration", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(pair[i+1:]))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid request timeout %q, want pattern=duration", pair)
+		}
+		out[strings.TrimSpace(pair[:i])] = d
+	}
+	return out, nil
+}
+
+func routeTimeout(route string) time.Duration {
+	if d, ok := requestTimeouts[route]; ok {
+		return d
+	}
+	return requestTimeout
+}
+
+func withTimeout() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeLabel(r)
+			timeout := routeTimeout(route)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{w: w, h: w.Header().Clone(), ctx: ctx}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+			select {
+			case p := <-panicked:
+				// Re-panic on the serving goroutine, where withRecovery can
+				// handle it.
+				panic(p)
+			case <-done:
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+			if tw.wroteHeader || ctx.Err() == nil {
+				// The handler finished in time, or its response is under way
+				// and it is left to finish it.
+				tw.mu.Unlock()
+				select {
+				case p := <-panicked:
+					panic(p)
+				case <-done:
+				}
+				return
+			}
+			tw.timedOut = true
+			tw.mu.Unlock()
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// The client went away; there's no one to answer.
+				return
+			}
+			requestTimeoutsTotal.WithLabelValues(route).Inc()
+			loggerFrom(r.Context()).Warn("request timed out", "timeout", timeout.String())
+			writeError(w, http.StatusGatewayTimeout, "request timed out after "+timeout.String())
+		})
+	}
+}
+
+// timeoutWriter passes a handler's response through until the request
+// times out, then discards it. A response can't start once the deadline has
+// passed, and the handler gets a header map of its own, so it never races
+// the 504.
+type timeoutWriter struct {
+	w   http.ResponseWriter
+	h   http.Header
+	ctx context.Context
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.h }
+
+// expired reports whether the handler may no longer write. tw.mu must be
+// held.
+func (tw *timeoutWriter) expired() bool {
+	return tw.timedOut || !tw.wroteHeader && tw.ctx.Err() != nil
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	dst := tw.w.Header()
+	clear(dst)
+	maps.Copy(dst, tw.h)
+	tw.w.WriteHeader(code)
+	if code >= 200 {
+		tw.wroteHeader = true
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	http.NewResponseController(tw.w).Flush()
+}
+
+// Hijack hands over the connection for WebSocket upgrades; a hijacked
+// request no longer times out.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.expired() {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	maps.Copy(tw.w.Header(), tw.h)
+	return http.NewResponseController(tw.w).Hijack()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseRequestTimeouts(t *testing.T) {
+	got, err := parseRequestTimeouts("/api/evidence=30s, GET /api/kv/{key} = 200ms,/events=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["/api/evidence"] != 30*time.Second || got["GET /api/kv/{key}"] != 200*time.Millisecond {
+		t.Errorf("parsed %v", got)
+	}
+	if d, ok := got["/events"]; !ok || d != 0 {
+		t.Errorf("exempt route parsed as %v, %v", d, ok)
+	}
+	for _, bad := range []string{"/api/info", "/api/info=soon", "/api/info=-1s"} {
+		if _, err := parseRequestTimeouts(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func withRequestTimeouts(t *testing.T, def time.Duration, routes map[string]time.Duration) {
+	t.Helper()
+	prevDef, prevRoutes := requestTimeout, requestTimeouts
+	requestTimeout, requestTimeouts = def, routes
+	t.Cleanup(func() { requestTimeout, requestTimeouts = prevDef, prevRoutes })
+}
+
+func TestTimeoutReturns504AndCancels(t *testing.T) {
+	withRequestTimeouts(t, time.Hour, map[string]time.Duration{"/api/stuck": 20 * time.Millisecond})
+
+	cancelled := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/api/stuck", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+		if _, err := w.Write([]byte("too late")); err != http.ErrHandlerTimeout {
+			t.Errorf("late write: err %v, want ErrHandlerTimeout", err)
+		}
+	}), withRequestID(), withTimeout()))
+
+	before := testutil.ToFloat64(requestTimeoutsTotal.WithLabelValues("/api/stuck"))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stuck", nil))
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("status %d, want 504", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"error":"request timed out after 20ms"`) || !strings.Contains(rr.Body.String(), "requestId") {
+		t.Errorf("body %s", rr.Body)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not cancelled")
+	}
+	if got := testutil.ToFloat64(requestTimeoutsTotal.WithLabelValues("/api/stuck")); got != before+1 {
+		t.Errorf("request_timeouts_total grew by %v, want 1", got-before)
+	}
+}
+
+func TestTimeoutPassesFastAndStartedResponses(t *testing.T) {
+	withRequestTimeouts(t, 20*time.Millisecond, map[string]time.Duration{"/api/exempt": 0})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/fast", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "yes")
+		writeJSON(w, http.StatusCreated, map[string]string{"ok": "true"})
+	}), withTimeout()))
+	mux.Handle("/api/stream", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first "))
+		<-r.Context().Done()
+	}), withTimeout()))
+	mux.Handle("/api/exempt", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("exempt route got a deadline")
+		}
+	}), withTimeout()))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/fast", nil))
+	if rr.Code != http.StatusCreated || rr.Header().Get("X-Handler") != "yes" {
+		t.Errorf("fast route: status %d headers %v", rr.Code, rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stream", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "first " {
+		t.Errorf("started response: status %d body %q", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/api/exempt", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("exempt route: status %d", rr.Code)
+	}
+}
+
+func TestTimeoutRepanicsOnServingGoroutine(t *testing.T) {
+	withRequestTimeouts(t, time.Second, nil)
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), withTimeout())
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recovered %v, want boom", p)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/panic", nil))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TLS is enabled when TLS_CERT_FILE and TLS_KEY_FILE are set. TLS_SNI_CONFIG
+// optionally points at a JSON file that serves a different certificate and
+// extra response headers per SNI hostname, e.g.
+//
+//	{"hosts": {
+//	  "blue.example.com": {"cert": "/certs/blue.crt", "key": "/certs/blue.key",
+//	                       "headers": {"X-Cluster": "blue"}},
+//	  "*.green.example.com": {"cert": "/certs/green.crt", "key": "/certs/green.key"}
+//	}}
+//
+// Clients that send no or an unknown server name get the default certificate.
+var (
+	tlsCertFile  = getenv("TLS_CERT_FILE", "")
+	tlsKeyFile   = getenv("TLS_KEY_FILE", "")
+	tlsSNIConfig = getenv("TLS_SNI_CONFIG", "")
+
+	// sni is nil unless TLS is enabled.
+	sni *sniRouter
+)
+
+type sniHostConfig struct {
+	Cert    string            `json:"cert"`
+	Key     string            `json:"key"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type sniHost struct {
+	cert    *tls.Certificate
+	headers map[string]string
+}
+
+// sniRouter picks a certificate and per-host behavior from the SNI server
+// name: exact matches first, then a single-label wildcard like *.example.com.
+type sniRouter struct {
+	def   *tls.Certificate
+	hosts map[string]*sniHost
+}
+
+func tlsEnabled() bool { return tlsCertFile != "" && tlsKeyFile != "" }
+
+func loadSNIRouter(certFile, keyFile, configPath string) (*sniRouter, error) {
+	def, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load default certificate: %w", err)
+	}
+	r := &sniRouter{def: &def, hosts: make(map[string]*sniHost)}
+	if configPath == "" {
+		return r, nil
+	}
+
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var cfg struct {
+		Hosts map[string]sniHostConfig `json:"hosts"`
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", configPath, err)
+	}
+	for name, hc := range cfg.Hosts {
+		h := &sniHost{headers: hc.Headers}
+		if hc.Cert != "" || hc.Key != "" {
+			c, err := tls.LoadX509KeyPair(hc.Cert, hc.Key)
+			if err != nil {
+				return nil, fmt.Errorf("host %q: %w", name, err)
+			}
+			h.cert = &c
+		}
+		r.hosts[strings.ToLower(name)] = h
+	}
+	return r, nil
+}
+
+func (s *sniRouter) lookup(serverName string) *sniHost {
+	name := strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if h, ok := s.hosts[name]; ok {
+		return h
+	}
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		if h, ok := s.hosts["*."+rest]; ok {
+			return h
+		}
+	}
+	return nil
+}
+
+func (s *sniRouter) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if h := s.lookup(hello.ServerName); h != nil && h.cert != nil {
+		return h.cert, nil
+	}
+	if s.def == nil {
+		return nil, errors.New("no certificate configured")
+	}
+	return s.def, nil
+}
+
+func (s *sniRouter) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.getCertificate,
+	}
+}
+
+// withSNI echoes the negotiated server name and applies the per-host
+// headers configured for it.
+func withSNI() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sni != nil && r.TLS != nil && r.TLS.ServerName != "" {
+				w.Header().Set("X-TLS-Server-Name", r.TLS.ServerName)
+				if h := sni.lookup(r.TLS.ServerName); h != nil {
+					for k, v := range h.headers {
+						w.Header().Set(k, v)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
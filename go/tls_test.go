@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for cn into dir and returns
+// the cert and key paths.
+func writeTestCert(t *testing.T, dir, cn string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, cn+".crt"), filepath.Join(dir, cn+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestSNIRouterSelectsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	defCert, defKey := writeTestCert(t, dir, "default.local", time.Now().Add(time.Hour))
+	blueCert, blueKey := writeTestCert(t, dir, "blue.example.com", time.Now().Add(time.Hour))
+	config := filepath.Join(dir, "sni.json")
+	body := `{"hosts": {"*.example.com": {"cert": "` + blueCert + `", "key": "` + blueKey + `", "headers": {"X-Cluster": "blue"}}}}`
+	if err := os.WriteFile(config, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := loadSNIRouter(defCert, defKey, config)
+	if err != nil {
+		t.Fatalf("loadSNIRouter returned error: %v", err)
+	}
+	cn := func(serverName string) string {
+		c, err := r.getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	if got := cn("Blue.Example.com"); got != "blue.example.com" {
+		t.Errorf("wildcard host got certificate %q want %q", got, "blue.example.com")
+	}
+	if got := cn("other.test"); got != "default.local" {
+		t.Errorf("unknown host got certificate %q want %q", got, "default.local")
+	}
+	if h := r.lookup("green.example.com"); h == nil || h.headers["X-Cluster"] != "blue" {
+		t.Errorf("expected per-host headers for wildcard match, got %+v", h)
+	}
+}
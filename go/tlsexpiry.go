@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Certificate expiry check. The "tls-expiry" readiness check covers the
+// certificates this server presents (when TLS is enabled) plus any peers
+// listed in TLS_EXPIRY_PEERS as comma-separated host:port. Certificates
+// expiring within TLS_EXPIRY_WARN_DAYS (default 14) are reported as
+// degraded. An expired local certificate fails the check; an expired peer
+// only degrades it, since another service's certificate is no reason to
+// take this pod out of rotation. Peers are dialed in the background every
+// TLS_EXPIRY_PEER_INTERVAL (default 5m) and probes read the cached result.
+type certExpiryCheck struct {
+	local map[string]*x509.Certificate
+	peers []string
+	warn  time.Duration
+	dial  func(ctx context.Context, addr string) (*x509.Certificate, error)
+
+	mu        sync.Mutex
+	last      []certExpiry
+	peerCerts map[string]peerCert
+}
+
+// peerCert is the outcome of the last dial to a peer.
+type peerCert struct {
+	cert *x509.Certificate
+	err  error
+}
+
+type certExpiry struct {
+	Name     string  `json:"name"`
+	Source   string  `json:"source"` // local or peer
+	Subject  string  `json:"subject,omitempty"`
+	NotAfter string  `json:"notAfter,omitempty"`
+	DaysLeft float64 `json:"daysLeft"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// certificates returns the leaf of every certificate the router serves,
+// keyed by "default" or the configured host name.
+func (s *sniRouter) certificates() (map[string]*x509.Certificate, error) {
+	out := make(map[string]*x509.Certificate)
+	add := func(name string, c *tls.Certificate) error {
+		if c == nil || len(c.Certificate) == 0 {
+			return nil
+		}
+		leaf := c.Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(c.Certificate[0]); err != nil {
+				return fmt.Errorf("certificate %q: %w", name, err)
+			}
+		}
+		out[name] = leaf
+		return nil
+	}
+	if err := add("default", s.def); err != nil {
+		return nil, err
+	}
+	for name, h := range s.hosts {
+		if err := add(name, h.cert); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func registerCertExpiryCheck(router *sniRouter, peerSpec string) error {
+	c := &certExpiryCheck{
+		warn: time.Duration(getenvInt("TLS_EXPIRY_WARN_DAYS", 14)) * 24 * time.Hour,
+		dial: peerCertificate,
+	}
+	if router != nil {
+		local, err := router.certificates()
+		if err != nil {
+			return err
+		}
+		c.local = local
+	}
+	for _, p := range strings.Split(peerSpec, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(p); err != nil {
+			return fmt.Errorf("TLS_EXPIRY_PEERS entry %q: %w", p, err)
+		}
+		c.peers = append(c.peers, p)
+	}
+	interval := getenvDuration("TLS_EXPIRY_PEER_INTERVAL", 5*time.Minute)
+	if interval <= 0 {
+		return fmt.Errorf("TLS_EXPIRY_PEER_INTERVAL must be positive, got %s", interval)
+	}
+	if len(c.local) == 0 && len(c.peers) == 0 {
+		return nil
+	}
+	health.registerDetailed("tls-expiry", probeReady, c.check, c.details)
+	if len(c.peers) > 0 {
+		go c.watchPeers(interval)
+	}
+	return nil
+}
+
+// watchPeers refreshes the peer certificates now and then on every tick.
+func (c *certExpiryCheck) watchPeers(interval time.Duration) {
+	c.refreshPeers(context.Background())
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		c.refreshPeers(context.Background())
+	}
+}
+
+// refreshPeers dials every peer concurrently and caches what each presented.
+func (c *certExpiryCheck) refreshPeers(ctx context.Context) {
+	certs := make(map[string]peerCert, len(c.peers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, addr := range c.peers {
+		wg.Go(func() {
+			dctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			cert, err := c.dial(dctx, addr)
+			mu.Lock()
+			certs[addr] = peerCert{cert, err}
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	c.mu.Lock()
+	c.peerCerts = certs
+	c.mu.Unlock()
+}
+
+// peerCertificate connects to addr and returns the leaf certificate it
+// presents. Verification is skipped on purpose: an expired or otherwise
+// invalid certificate is exactly what this check needs to be able to see.
+func peerCertificate(ctx context.Context, addr string) (*x509.Certificate, error) {
+	host, _, _ := net.SplitHostPort(addr)
+	d := tls.Dialer{Config: &tls.Config{ServerName: host, InsecureSkipVerify: true}}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("no peer certificate")
+	}
+	return certs[0], nil
+}
+
+func (c *certExpiryCheck) check(context.Context) error {
+	now := time.Now()
+	var results []certExpiry
+	var expired, peerExpired, expiring, unreachable []string
+
+	observe := func(name, source string, cert *x509.Certificate) {
+		left := cert.NotAfter.Sub(now)
+		results = append(results, certExpiry{
+			Name:     name,
+			Source:   source,
+			Subject:  cert.Subject.String(),
+			NotAfter: cert.NotAfter.UTC().Format(time.RFC3339),
+			DaysLeft: float64(left.Round(time.Hour)) / float64(24*time.Hour),
+		})
+		tlsCertExpiry.WithLabelValues(name, source).Set(float64(cert.NotAfter.Unix()))
+		switch {
+		case left <= 0 && source == "peer":
+			peerExpired = append(peerExpired, name)
+		case left <= 0:
+			expired = append(expired, name)
+		case left < c.warn:
+			expiring = append(expiring, fmt.Sprintf("%s in %.0fd", name, left.Hours()/24))
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(c.local)) {
+		observe(name, "local", c.local[name])
+	}
+	c.mu.Lock()
+	peerCerts := c.peerCerts
+	c.mu.Unlock()
+	for _, addr := range c.peers {
+		p, ok := peerCerts[addr]
+		switch {
+		case !ok:
+			// Not dialed yet.
+		case p.err != nil:
+			results = append(results, certExpiry{Name: addr, Source: "peer", Error: p.err.Error()})
+			unreachable = append(unreachable, addr)
+		default:
+			observe(addr, "peer", p.cert)
+		}
+	}
+
+	c.mu.Lock()
+	c.last = results
+	c.mu.Unlock()
+
+	switch {
+	case len(expired) > 0:
+		return fmt.Errorf("expired: %s", strings.Join(expired, ", "))
+	case len(peerExpired) > 0 || len(expiring) > 0 || len(unreachable) > 0:
+		var parts []string
+		if len(peerExpired) > 0 {
+			parts = append(parts, "expired: "+strings.Join(peerExpired, ", "))
+		}
+		if len(expiring) > 0 {
+			parts = append(parts, "expiring: "+strings.Join(expiring, ", "))
+		}
+		if len(unreachable) > 0 {
+			parts = append(parts, "unreachable: "+strings.Join(unreachable, ", "))
+		}
+		return degraded(errors.New(strings.Join(parts, "; ")))
+	}
+	return nil
+}
+
+func (c *certExpiryCheck) details() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
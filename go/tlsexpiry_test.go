@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCertExpiryCheck(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "default.local", time.Now().Add(90*24*time.Hour))
+	r, err := loadSNIRouter(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := r.certificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &certExpiryCheck{local: local, warn: 14 * 24 * time.Hour}
+	if err := c.check(context.Background()); err != nil {
+		t.Errorf("unexpected error for a certificate valid for 90 days: %v", err)
+	}
+
+	c.warn = 100 * 24 * time.Hour
+	if err := c.check(context.Background()); !errors.As(err, new(degradedError)) {
+		t.Errorf("expected a degraded warning inside the warn window, got %v", err)
+	}
+
+	c.local["default"].NotAfter = time.Now().Add(-time.Minute)
+	if err := c.check(context.Background()); err == nil || errors.As(err, new(degradedError)) {
+		t.Errorf("expected an expired certificate to fail the check, got %v", err)
+	}
+}
+
+func TestCertExpiryCheckPeers(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "peer.local", time.Now().Add(2*24*time.Hour))
+	r, err := loadSNIRouter(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := r.certificates()
+
+	c := &certExpiryCheck{
+		peers: []string{"ok:443", "down:443"},
+		warn:  24 * time.Hour,
+		dial: func(_ context.Context, addr string) (*x509.Certificate, error) {
+			if addr == "down:443" {
+				return nil, errors.New("connection refused")
+			}
+			return leaf["default"], nil
+		},
+	}
+	if err := c.check(context.Background()); err != nil {
+		t.Errorf("peers not dialed yet should not affect the check, got %v", err)
+	}
+	c.refreshPeers(context.Background())
+	err = c.check(context.Background())
+	if !errors.As(err, new(degradedError)) {
+		t.Errorf("expected an unreachable peer to degrade the check, got %v", err)
+	}
+	results := c.details().([]certExpiry)
+	if len(results) != 2 || results[0].DaysLeft < 1.9 || results[1].Error == "" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	leaf["default"].NotAfter = time.Now().Add(-time.Minute)
+	if err := c.check(context.Background()); !errors.As(err, new(degradedError)) {
+		t.Errorf("expected an expired peer to only degrade the check, got %v", err)
+	}
+}
+
+func TestRegisterCertExpiryCheckRejectsNonPositiveInterval(t *testing.T) {
+	t.Setenv("TLS_EXPIRY_PEER_INTERVAL", "0s")
+	if err := registerCertExpiryCheck(nil, "peer.local:443"); err == nil {
+		t.Error("expected an error for TLS_EXPIRY_PEER_INTERVAL=0s")
+	}
+}
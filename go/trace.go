@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceContext is the part of a W3C traceparent header the app uses.
+type traceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// parseTraceparent parses a version-00 traceparent header
+// ("00-<32 hex trace id>-<16 hex span id>-<2 hex flags>"). All-zero IDs and
+// malformed headers are rejected.
+func parseTraceparent(h string) (traceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h), "-")
+	if len(parts) < 4 || parts[0] == "00" && len(parts) != 4 {
+		return traceContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if version == "ff" || !isLowerHex(version, 2) || !isLowerHex(traceID, 32) || !isLowerHex(spanID, 16) || !isLowerHex(flags, 2) {
+		return traceContext{}, false
+	}
+	if traceID == strings.Repeat("0", 32) || spanID == strings.Repeat("0", 16) {
+		return traceContext{}, false
+	}
+	f, _ := hex.DecodeString(flags)
+	return traceContext{TraceID: traceID, SpanID: spanID, Sampled: f[0]&1 == 1}, true
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestTrace returns the request's trace context: its server span when
+// tracing is on, otherwise the traceparent it arrived with.
+func requestTrace(r *http.Request) (traceContext, bool) {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		return traceContext{TraceID: sc.TraceID().String(), SpanID: sc.SpanID().String(), Sampled: sc.IsSampled()}, true
+	}
+	return parseTraceparent(r.Header.Get("traceparent"))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing. Every routed request gets an otelhttp server span named
+// "<method> <route>" carrying the route, status code and peer address. An
+// incoming W3C traceparent/tracestate is continued, and the trace ID is
+// returned in X-Trace-Id so a curl request can be matched to backend traces
+// and logs. Spans are created even when nothing exports them, so every
+// response has a trace ID. TRACE_EXPORTER=otlp|zipkin|stdout|none picks
+// where they go (see spanProcessor); by default they are exported over
+// OTLP/gRPC when OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set, with the same resource as
+// metrics export. Sampling is configured as described in sampling.go.
+// Probes and /metrics are not traced.
+//
+// otelhttp's own metrics are disabled: otelmetrics.go already records the
+// same semantic-convention instruments.
+func withTracing() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(attribute.String("http.route", routeLabel(r)))
+			if sc := span.SpanContext(); sc.HasTraceID() {
+				w.Header().Set("X-Trace-Id", sc.TraceID().String())
+			}
+			next.ServeHTTP(w, r)
+		})
+		return otelhttp.NewHandler(tagged, "http.server",
+			otelhttp.WithMeterProvider(metricnoop.NewMeterProvider()),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + routeLabel(r)
+			}),
+			otelhttp.WithFilter(func(r *http.Request) bool { return !isProbePath(r.URL.Path) }),
+		)
+	}
+}
+
+// traceExporterKind picks the span exporter: TRACE_EXPORTER, falling back
+// to the standard OTEL_TRACES_EXPORTER, and otherwise OTLP if an OTLP
+// endpoint is configured.
+func traceExporterKind() string {
+	if v := getenv("TRACE_EXPORTER", getenv("OTEL_TRACES_EXPORTER", "")); v != "" {
+		return v
+	}
+	if getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "") != "" || getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") != "" {
+		return "otlp"
+	}
+	return "none"
+}
+
+// spanProcessor returns the processor that sends spans to the chosen
+// exporter, or nil for "none":
+//
+//	otlp:   OTLP/gRPC, configured by the standard OTEL_EXPORTER_OTLP_*
+//	        variables. Jaeger ingests OTLP natively, so use this for Jaeger.
+//	zipkin: Zipkin v2 JSON to ZIPKIN_ENDPOINT
+//	        (default http://localhost:9411/api/v2/spans).
+//	stdout: pretty-printed JSON on stdout as each span ends, for local
+//	        debugging.
+func spanProcessor(ctx context.Context, kind string) (sdktrace.SpanProcessor, error) {
+	switch kind {
+	case "none":
+		return nil, nil
+	case "otlp":
+		exp, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.NewBatchSpanProcessor(exp), nil
+	case "zipkin":
+		exp, err := zipkin.New(getenv("ZIPKIN_ENDPOINT", "http://localhost:9411/api/v2/spans"))
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.NewBatchSpanProcessor(exp), nil
+	case "stdout":
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.NewSimpleSpanProcessor(exp), nil
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q, want otlp, zipkin, stdout or none", kind)
+}
+
+// startOTelTracing installs a tracer provider, exporting spans if
+// configured, and the W3C trace context and baggage propagators. It returns
+// a function that flushes and stops the provider.
+func startOTelTracing(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	res, err := otelResource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	sampler, err := traceSampler()
+	if err != nil {
+		return nil, err
+	}
+	samplerDesc := "default"
+	if sampler != nil {
+		opts = append(opts, sdktrace.WithSampler(sampler))
+		samplerDesc = sampler.Description()
+	}
+	kind := traceExporterKind()
+	sp, err := spanProcessor(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	if sp != nil {
+		if _, ok := sampler.(recordUnsampled); ok {
+			sp = keepErrors{sp}
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(sp))
+		logger.Info("trace export enabled", "exporter", kind, "sampler", samplerDesc)
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// tracedTransport records a client span for each outbound call and injects
+// the trace context into it.
+type tracedTransport struct {
+	*otelhttp.Transport
+	base http.RoundTripper
+}
+
+func newTracedTransport(base http.RoundTripper) tracedTransport {
+	return tracedTransport{
+		Transport: otelhttp.NewTransport(base, otelhttp.WithMeterProvider(metricnoop.NewMeterProvider())),
+		base:      base,
+	}
+}
+
+// CloseIdleConnections lets outboundClient.CloseIdleConnections reach the
+// wrapped transport; otelhttp.Transport doesn't forward it.
+func (t tracedTransport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
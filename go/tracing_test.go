@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func withTestTracer(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return rec
+}
+
+func TestWithTracing(t *testing.T) {
+	rec := withTestTracer(t)
+	var seen traceContext
+	mux := http.NewServeMux()
+	mux.Handle("/api/things/{id}", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = requestTrace(r)
+		w.WriteHeader(http.StatusTeapot)
+	}), withTracing()))
+	mux.Handle("/healthz", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withTracing()))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/things/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=abc")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1 (probes are not traced)", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "GET /api/things/{id}" {
+		t.Errorf("span name = %q", s.Name())
+	}
+	if s.Parent().SpanID().String() != "00f067aa0ba902b7" || s.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("span did not continue the incoming trace: parent %s trace %s", s.Parent().SpanID(), s.SpanContext().TraceID())
+	}
+	if got := s.SpanContext().TraceState().Get("vendor"); got != "abc" {
+		t.Errorf("tracestate vendor = %q, want it carried over", got)
+	}
+	if got := rr.Header().Get("X-Trace-Id"); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("X-Trace-Id = %q", got)
+	}
+	attrs := map[string]string{}
+	for _, kv := range s.Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["http.route"] != "/api/things/{id}" || attrs["http.response.status_code"] != "418" {
+		t.Errorf("attributes = %v", attrs)
+	}
+	if seen.SpanID != s.SpanContext().SpanID().String() || !seen.Sampled {
+		t.Errorf("requestTrace = %+v, want the server span", seen)
+	}
+}
+
+func TestStartOTelTracingWithoutExporter(t *testing.T) {
+	withTestTracer(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	shutdown, err := startOTelTracing(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(t.Context())
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), withTracing())
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/info", nil))
+	if id := rr.Header().Get("X-Trace-Id"); !isLowerHex(id, 32) {
+		t.Errorf("X-Trace-Id = %q, want a fresh trace ID", id)
+	}
+}
+
+func TestTraceExporterSelection(t *testing.T) {
+	t.Setenv("TRACE_EXPORTER", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if got := traceExporterKind(); got != "none" {
+		t.Errorf("no config: kind = %q, want none", got)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4317")
+	if got := traceExporterKind(); got != "otlp" {
+		t.Errorf("OTLP endpoint: kind = %q, want otlp", got)
+	}
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	if got := traceExporterKind(); got != "none" {
+		t.Errorf("OTEL_TRACES_EXPORTER=none: kind = %q", got)
+	}
+	t.Setenv("TRACE_EXPORTER", "stdout")
+	if got := traceExporterKind(); got != "stdout" {
+		t.Errorf("TRACE_EXPORTER wins: kind = %q", got)
+	}
+
+	for _, kind := range []string{"stdout", "zipkin"} {
+		if sp, err := spanProcessor(t.Context(), kind); err != nil || sp == nil {
+			t.Errorf("%s: got %v, %v", kind, sp, err)
+		}
+	}
+	if sp, err := spanProcessor(t.Context(), "none"); err != nil || sp != nil {
+		t.Errorf("none: got %v, %v; want no exporter", sp, err)
+	}
+	if _, err := spanProcessor(t.Context(), "jaeger-thrift"); err == nil {
+		t.Error("unknown exporter: want an error")
+	}
+}
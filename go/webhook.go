@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Inbound webhooks are signed by the sender with an HMAC-SHA256 of the raw
+// body under WEBHOOK_SECRET, sent as "X-Webhook-Signature: sha256=<hex>".
+// The secret rotates like the others (see rotateSecretHandler): during the
+// grace window deliveries signed with either value are accepted, so the
+// sender can switch over without dropping any. Without WEBHOOK_SECRET the
+// endpoint is disabled rather than left unauthenticated.
+var webhookSecret = newRotatingSecret(getenv("WEBHOOK_SECRET", ""))
+
+const eventWebhook eventType = "webhook"
+
+func init() {
+	secrets["webhook-secret"] = webhookSecret
+}
+
+// verifyWebhookSignature reports whether sig is a valid signature of body
+// under any currently accepted webhook secret.
+func verifyWebhookSignature(body []byte, sig string) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || !strings.HasPrefix(sig, "sha256=") {
+		return false
+	}
+	for _, key := range webhookSecret.accepted() {
+		if key == "" {
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(body)
+		if hmac.Equal(got, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookHandler accepts a signed delivery and records it on the event bus.
+func webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if webhookSecret.value() == "" {
+		writeError(w, http.StatusForbidden, "webhooks disabled; set WEBHOOK_SECRET to enable them")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if !verifyWebhookSignature(body, r.Header.Get("X-Webhook-Signature")) {
+		writeError(w, http.StatusUnauthorized, "invalid webhook signature")
+		return
+	}
+	events.publish(event{Type: eventWebhook, Subject: r.Header.Get("X-Webhook-Event"), Message: "webhook received", Data: map[string]any{"bytes": len(body)}})
+	w.WriteHeader(http.StatusAccepted)
+}
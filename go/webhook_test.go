@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signWebhook(body, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhookSignatureAcrossRotation(t *testing.T) {
+	prev := webhookSecret.value()
+	t.Cleanup(func() { webhookSecret.rotate(prev, 0) })
+	webhookSecret.rotate("old", 0)
+	webhookSecret.rotate("new", time.Minute)
+
+	body := []byte(`{"ping":true}`)
+	for _, key := range []string{"old", "new"} {
+		if !verifyWebhookSignature(body, signWebhook(string(body), key)) {
+			t.Errorf("expected a signature under %q to be accepted during the grace window", key)
+		}
+	}
+	if verifyWebhookSignature(body, signWebhook(string(body), "other")) {
+		t.Error("expected a signature under an unknown secret to be rejected")
+	}
+	if verifyWebhookSignature(body, strings.TrimPrefix(signWebhook(string(body), "new"), "sha256=")) {
+		t.Error("expected a signature without the sha256= prefix to be rejected")
+	}
+
+	webhookSecret.rotate("newer", 0)
+	if verifyWebhookSignature(body, signWebhook(string(body), "new")) {
+		t.Error("expected the previous secret to be rejected once the grace window is over")
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	withTestLedger(t, 10)
+	prev := webhookSecret.value()
+	t.Cleanup(func() { webhookSecret.rotate(prev, 0) })
+
+	post := func(body, sig string) int {
+		req := httptest.NewRequest("POST", "/api/webhooks", strings.NewReader(body))
+		req.Header.Set("X-Webhook-Signature", sig)
+		req.Header.Set("X-Webhook-Event", "push")
+		rr := httptest.NewRecorder()
+		webhookHandler(rr, req)
+		return rr.Code
+	}
+
+	webhookSecret.rotate("", 0)
+	if code := post("{}", signWebhook("{}", "")); code != http.StatusForbidden {
+		t.Errorf("without WEBHOOK_SECRET: got %v want %v", code, http.StatusForbidden)
+	}
+
+	webhookSecret.rotate("s3cret", 0)
+	if code := post("{}", signWebhook("{}", "wrong")); code != http.StatusUnauthorized {
+		t.Errorf("bad signature: got %v want %v", code, http.StatusUnauthorized)
+	}
+	if code := post("{}", signWebhook("{}", "s3cret")); code != http.StatusAccepted {
+		t.Errorf("good signature: got %v want %v", code, http.StatusAccepted)
+	}
+	if got := ledger.list(string(eventWebhook), 0); len(got) != 1 || got[0].Subject != "push" {
+		t.Errorf("expected one webhook event in the ledger, got %+v", got)
+	}
+}